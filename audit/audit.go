@@ -0,0 +1,80 @@
+// Package audit provides a structured record of the allow and deny decisions made by the authorisation
+// middleware and permissions checker, along with sinks to write those records to.
+package audit
+
+import (
+	"context"
+	"time"
+)
+
+// Outcome is the result of an authorisation decision
+type Outcome string
+
+// Decision outcomes
+const (
+	OutcomeAllow Outcome = "allow"
+	OutcomeDeny  Outcome = "deny"
+)
+
+// Reasons recorded by the permissions checker. Decisions made by the authorisation middleware
+// before the permissions are checked use the middleware error code as the reason, e.g. 'token_expired'.
+const (
+	ReasonPolicyMatched          = "policy_matched"
+	ReasonNoMatchingPolicy       = "no_matching_policy"
+	ReasonPermissionNotFound     = "permission_not_found"
+	ReasonNoPermissionsRequested = "no_permissions_requested"
+	ReasonPermissionsUnavailable = "permissions_unavailable"
+	// ReasonPermissionGranted is used by the middleware when a custom permissions checker does not provide a reason
+	ReasonPermissionGranted = "permission_granted"
+)
+
+// Decision is the record of a single authorisation decision. Decisions recorded by the middleware include
+// the enforcement mode - a deny outcome in 'shadow' mode is a request that would have been rejected.
+type Decision struct {
+	Timestamp   time.Time         `json:"timestamp"`
+	RequestID   string            `json:"request_id,omitempty"`
+	UserID      string            `json:"user_id,omitempty"`
+	Groups      []string          `json:"groups,omitempty"`
+	TokenType   string            `json:"token_type,omitempty"`
+	Permissions []string          `json:"permissions"`
+	Mode        string            `json:"mode,omitempty"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	PolicyIDs   []string          `json:"policy_ids,omitempty"`
+	Outcome     Outcome           `json:"outcome"`
+	Reason      string            `json:"reason"`
+}
+
+// Sink records authorisation decisions. Implementations must be safe for concurrent use, and are
+// responsible for handling their own errors. A sink that also implements io.Closer is closed when the
+// middleware it is registered with is closed.
+type Sink interface {
+	Record(ctx context.Context, decision Decision)
+}
+
+// SinkFunc is an adapter allowing an ordinary function to be used as a Sink
+type SinkFunc func(ctx context.Context, decision Decision)
+
+// Record calls f(ctx, decision)
+func (f SinkFunc) Record(ctx context.Context, decision Decision) {
+	f(ctx, decision)
+}
+
+type contextKey string
+
+const decisionContextKey = contextKey("audit-decision")
+
+// NewContextWithDecision returns a copy of the given context holding a decision that is being built by the caller.
+// The permissions checker adds the outcome of its check to a decision found in the context, rather than recording
+// a separate decision, so that each request results in a single record.
+func NewContextWithDecision(ctx context.Context, decision *Decision) context.Context {
+	return context.WithValue(ctx, decisionContextKey, decision)
+}
+
+// DecisionFromContext returns the decision stored in the given context, if there is one
+func DecisionFromContext(ctx context.Context) (*Decision, bool) {
+	decision, ok := ctx.Value(decisionContextKey).(*Decision)
+	if !ok || decision == nil {
+		return nil, false
+	}
+	return decision, true
+}
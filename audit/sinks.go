@@ -0,0 +1,195 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand/v2"
+	"os"
+	"sync"
+
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// defaultMemorySinkCapacity is the number of decisions held by a MemorySink created with a non-positive capacity
+const defaultMemorySinkCapacity = 100
+
+// LogSink records decisions as log.go events
+type LogSink struct{}
+
+// NewLogSink creates a new instance of LogSink
+func NewLogSink() *LogSink {
+	return &LogSink{}
+}
+
+// Record writes the decision as an 'authorisation decision' log event
+func (s *LogSink) Record(ctx context.Context, decision Decision) {
+	log.Info(ctx, "authorisation decision", log.Data{
+		"timestamp":   decision.Timestamp,
+		"request_id":  decision.RequestID,
+		"user_id":     decision.UserID,
+		"groups":      decision.Groups,
+		"token_type":  decision.TokenType,
+		"permissions": decision.Permissions,
+		"mode":        decision.Mode,
+		"attributes":  decision.Attributes,
+		"policy_ids":  decision.PolicyIDs,
+		"outcome":     decision.Outcome,
+		"reason":      decision.Reason,
+	})
+}
+
+// MemorySink holds the most recent decisions in a fixed size ring buffer. It is intended for use in tests.
+type MemorySink struct {
+	mutex     sync.Mutex
+	decisions []Decision
+	next      int
+	full      bool
+}
+
+// NewMemorySink creates a new instance of MemorySink holding up to capacity decisions
+func NewMemorySink(capacity int) *MemorySink {
+	if capacity <= 0 {
+		capacity = defaultMemorySinkCapacity
+	}
+	return &MemorySink{
+		decisions: make([]Decision, capacity),
+	}
+}
+
+// Record adds the decision to the buffer, replacing the oldest decision if the buffer is full
+func (s *MemorySink) Record(_ context.Context, decision Decision) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.decisions[s.next] = decision
+	s.next = (s.next + 1) % len(s.decisions)
+	if s.next == 0 {
+		s.full = true
+	}
+}
+
+// Decisions returns the decisions held in the buffer, oldest first
+func (s *MemorySink) Decisions() []Decision {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.full {
+		return append([]Decision{}, s.decisions[:s.next]...)
+	}
+	return append(append([]Decision{}, s.decisions[s.next:]...), s.decisions[:s.next]...)
+}
+
+// Reset removes all decisions from the buffer
+func (s *MemorySink) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.decisions = make([]Decision, len(s.decisions))
+	s.next = 0
+	s.full = false
+}
+
+// FileSink appends decisions to a file as JSON lines
+type FileSink struct {
+	mutex   sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+// NewFileSink creates a new instance of FileSink, creating the file at the given path if it does not exist
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{
+		file:    file,
+		encoder: json.NewEncoder(file),
+	}, nil
+}
+
+// Record appends the decision to the file as a single line of JSON
+func (s *FileSink) Record(ctx context.Context, decision Decision) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.encoder.Encode(decision); err != nil {
+		log.Error(ctx, "failed to write authorisation decision to audit file", err, log.Data{"path": s.file.Name()})
+	}
+}
+
+// Close closes the underlying file
+func (s *FileSink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.file.Close()
+}
+
+// SamplingSink records a sample of allow decisions, and every deny decision, to the next sink.
+// This prevents the audit record of high traffic services being flooded with routine allow decisions.
+type SamplingSink struct {
+	next      Sink
+	allowRate float64
+}
+
+// NewSamplingSink creates a new instance of SamplingSink, recording the given proportion of allow decisions,
+// between 0 (none) and 1 (all).
+func NewSamplingSink(next Sink, allowRate float64) *SamplingSink {
+	return &SamplingSink{
+		next:      next,
+		allowRate: allowRate,
+	}
+}
+
+// Record passes the decision to the next sink, unless it is an allow decision that has not been sampled
+func (s *SamplingSink) Record(ctx context.Context, decision Decision) {
+	if decision.Outcome == OutcomeAllow && s.allowRate < 1 {
+		if s.allowRate <= 0 || rand.Float64() >= s.allowRate {
+			return
+		}
+	}
+	s.next.Record(ctx, decision)
+}
+
+// Close closes the next sink, if it can be closed
+func (s *SamplingSink) Close() error {
+	return Close(s.next)
+}
+
+// MultiSink records decisions to each of a list of sinks
+type MultiSink struct {
+	sinks []Sink
+}
+
+// NewMultiSink creates a new instance of MultiSink
+func NewMultiSink(sinks ...Sink) *MultiSink {
+	return &MultiSink{
+		sinks: sinks,
+	}
+}
+
+// Record passes the decision to each sink in turn
+func (s *MultiSink) Record(ctx context.Context, decision Decision) {
+	for _, sink := range s.sinks {
+		sink.Record(ctx, decision)
+	}
+}
+
+// Close closes each of the sinks that can be closed
+func (s *MultiSink) Close() error {
+	var errs []error
+	for _, sink := range s.sinks {
+		errs = append(errs, Close(sink))
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes the given sink, if it implements io.Closer
+func Close(sink Sink) error {
+	if closer, ok := sink.(interface{ Close() error }); ok {
+		return closer.Close()
+	}
+	return nil
+}
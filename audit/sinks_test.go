@@ -0,0 +1,171 @@
+package audit_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-authorisation/v2/audit"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var (
+	allowDecision = audit.Decision{
+		Timestamp:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		RequestID:   "request-123",
+		UserID:      "bilbo",
+		Groups:      []string{"publisher"},
+		TokenType:   "jwt",
+		Permissions: []string{"datasets:read"},
+		PolicyIDs:   []string{"policy1"},
+		Outcome:     audit.OutcomeAllow,
+		Reason:      audit.ReasonPolicyMatched,
+	}
+	denyDecision = audit.Decision{
+		Permissions: []string{"datasets:edit"},
+		Outcome:     audit.OutcomeDeny,
+		Reason:      audit.ReasonNoMatchingPolicy,
+	}
+)
+
+func TestMemorySink(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Given a memory sink with a capacity of 2", t, func() {
+		sink := audit.NewMemorySink(2)
+
+		Convey("When fewer decisions than the capacity are recorded", func() {
+			sink.Record(ctx, allowDecision)
+
+			Convey("Then the decisions are returned", func() {
+				So(sink.Decisions(), ShouldResemble, []audit.Decision{allowDecision})
+			})
+		})
+
+		Convey("When more decisions than the capacity are recorded", func() {
+			first := audit.Decision{Reason: "first"}
+			sink.Record(ctx, first)
+			sink.Record(ctx, allowDecision)
+			sink.Record(ctx, denyDecision)
+
+			Convey("Then the oldest decision is replaced and the rest are returned oldest first", func() {
+				So(sink.Decisions(), ShouldResemble, []audit.Decision{allowDecision, denyDecision})
+			})
+		})
+
+		Convey("When the sink is reset", func() {
+			sink.Record(ctx, allowDecision)
+			sink.Reset()
+
+			Convey("Then no decisions are returned", func() {
+				So(sink.Decisions(), ShouldBeEmpty)
+			})
+		})
+	})
+}
+
+func TestFileSink(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Given a file sink", t, func() {
+		path := filepath.Join(t.TempDir(), "audit.jsonl")
+		sink, err := audit.NewFileSink(path)
+		So(err, ShouldBeNil)
+
+		Convey("When decisions are recorded and the sink is closed", func() {
+			sink.Record(ctx, allowDecision)
+			sink.Record(ctx, denyDecision)
+			So(sink.Close(), ShouldBeNil)
+
+			Convey("Then each decision is written to the file as a line of JSON", func() {
+				file, err := os.Open(path)
+				So(err, ShouldBeNil)
+				defer file.Close()
+
+				var decisions []audit.Decision
+				scanner := bufio.NewScanner(file)
+				for scanner.Scan() {
+					var decision audit.Decision
+					So(json.Unmarshal(scanner.Bytes(), &decision), ShouldBeNil)
+					decisions = append(decisions, decision)
+				}
+				So(decisions, ShouldResemble, []audit.Decision{allowDecision, denyDecision})
+			})
+		})
+	})
+
+	Convey("Given a path in a directory that does not exist", t, func() {
+		path := filepath.Join(t.TempDir(), "missing", "audit.jsonl")
+
+		Convey("When a file sink is created", func() {
+			_, err := audit.NewFileSink(path)
+
+			Convey("Then an error is returned", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestSamplingSink(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Given a sampling sink that records no allow decisions", t, func() {
+		next := audit.NewMemorySink(10)
+		sink := audit.NewSamplingSink(next, 0)
+
+		Convey("When allow and deny decisions are recorded", func() {
+			sink.Record(ctx, allowDecision)
+			sink.Record(ctx, denyDecision)
+
+			Convey("Then only the deny decision is passed to the next sink", func() {
+				So(next.Decisions(), ShouldResemble, []audit.Decision{denyDecision})
+			})
+		})
+	})
+
+	Convey("Given a sampling sink that records all allow decisions", t, func() {
+		next := audit.NewMemorySink(10)
+		sink := audit.NewSamplingSink(next, 1)
+
+		Convey("When allow and deny decisions are recorded", func() {
+			sink.Record(ctx, allowDecision)
+			sink.Record(ctx, denyDecision)
+
+			Convey("Then both decisions are passed to the next sink", func() {
+				So(next.Decisions(), ShouldResemble, []audit.Decision{allowDecision, denyDecision})
+			})
+		})
+	})
+}
+
+func TestMultiSink(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Given a multi sink with a memory sink and a file sink", t, func() {
+		memorySink := audit.NewMemorySink(10)
+		fileSink, err := audit.NewFileSink(filepath.Join(t.TempDir(), "audit.jsonl"))
+		So(err, ShouldBeNil)
+		sink := audit.NewMultiSink(memorySink, fileSink)
+
+		Convey("When a decision is recorded", func() {
+			sink.Record(ctx, denyDecision)
+
+			Convey("Then it is passed to each sink", func() {
+				So(memorySink.Decisions(), ShouldResemble, []audit.Decision{denyDecision})
+			})
+		})
+
+		Convey("When the multi sink is closed", func() {
+			So(audit.Close(sink), ShouldBeNil)
+
+			Convey("Then the file sink is closed", func() {
+				So(fileSink.Close(), ShouldNotBeNil)
+			})
+		})
+	})
+}
@@ -0,0 +1,60 @@
+package authorisation
+
+import (
+	"net/http"
+	"strings"
+)
+
+// WWWAuthenticateHeader is the name of the header used to send RFC 6750 bearer token challenges
+const WWWAuthenticateHeader = "WWW-Authenticate"
+
+// RFC 6750 bearer token error codes
+const (
+	bearerErrorInvalidToken      = "invalid_token"
+	bearerErrorInsufficientScope = "insufficient_scope"
+)
+
+// bearerChallenge builds RFC 6750 WWW-Authenticate challenges for rejected requests
+type bearerChallenge struct {
+	realm                   string
+	includeErrorDescription bool
+}
+
+// header returns the WWW-Authenticate header value for the given error, or an empty string if
+// a challenge is not appropriate (e.g. for server errors, or for an invalid Zebedee service token, which is rejected
+// with a 403 rather than the 401 RFC 6750 section 3.1 requires for the invalid_token error)
+func (c bearerChallenge) header(authErr *Error) string {
+	var bearerError string
+	switch {
+	case authErr.Code == ErrorCodeMissingToken:
+		// RFC 6750 section 3.1 - no error code is included if the request lacks any authentication information
+	case authErr.Code == ErrorCodePermissionDenied:
+		bearerError = bearerErrorInsufficientScope
+	case authErr.Status == http.StatusUnauthorized:
+		bearerError = bearerErrorInvalidToken
+	default:
+		return ""
+	}
+
+	var params []string
+	if c.realm != "" {
+		params = append(params, authParam("realm", c.realm))
+	}
+	if bearerError != "" {
+		params = append(params, authParam("error", bearerError))
+		if c.includeErrorDescription && authErr.Detail != "" {
+			params = append(params, authParam("error_description", authErr.Detail))
+		}
+	}
+
+	if len(params) == 0 {
+		return "Bearer"
+	}
+	return "Bearer " + strings.Join(params, ", ")
+}
+
+// authParam formats a quoted auth-param, escaping any characters not allowed in a quoted-string
+func authParam(name, value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value)
+	return name + `="` + value + `"`
+}
@@ -0,0 +1,114 @@
+package authorisation_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ONSdigital/dp-authorisation/v2/authorisation"
+	"github.com/ONSdigital/dp-authorisation/v2/authorisation/mock"
+	"github.com/ONSdigital/dp-authorisation/v2/authorisationtest"
+	"github.com/ONSdigital/dp-authorisation/v2/jwt"
+	dprequest "github.com/ONSdigital/dp-net/v3/request"
+	permsdk "github.com/ONSdigital/dp-permissions-api/sdk"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMiddleware_WWWAuthenticateChallenge(t *testing.T) {
+	Convey("Given a middleware instance configured with a realm and error descriptions", t, func() {
+		parseErr := error(nil)
+		mockJWTParser := &mock.JWTParserMock{
+			ParseFunc: func(tokenString string) (*permsdk.EntityData, error) {
+				return dummyEntityData, parseErr
+			},
+		}
+		permissionsErr := error(nil)
+		permissionsChecker := &mock.PermissionsCheckerMock{
+			HasPermissionFunc: func(ctx context.Context, entityData permsdk.EntityData, permission string, attributes map[string]string) (bool, error) {
+				return false, permissionsErr
+			},
+		}
+		middleware := authorisation.NewMiddlewareFromDependencies(mockJWTParser, permissionsChecker, zebedeeIdentity, identityClient,
+			authorisation.WithChallenge("dp-api", true))
+		response := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, testURL, http.NoBody)
+		request.Header.Set("Authorization", authorisationtest.AdminJWTToken)
+
+		Convey("When a request without an access token is rejected", func() {
+			request.Header.Del("Authorization")
+			middleware.Require(permission, (&mockHandler{}).ServeHTTP)(response, request)
+
+			Convey("Then the challenge does not include an error", func() {
+				So(response.Header().Get(authorisation.WWWAuthenticateHeader), ShouldEqual, `Bearer realm="dp-api"`)
+			})
+		})
+
+		Convey("When a request with an expired token is rejected", func() {
+			parseErr = jwt.ErrTokenExpired
+			middleware.Require(permission, (&mockHandler{}).ServeHTTP)(response, request)
+
+			Convey("Then the challenge includes the invalid_token error and description", func() {
+				So(response.Code, ShouldEqual, http.StatusUnauthorized)
+				So(response.Header().Get(authorisation.WWWAuthenticateHeader), ShouldEqual,
+					`Bearer realm="dp-api", error="invalid_token", error_description="the access token has expired"`)
+			})
+		})
+
+		Convey("When a request without the required permission is rejected", func() {
+			middleware.Require(permission, (&mockHandler{}).ServeHTTP)(response, request)
+
+			Convey("Then the challenge includes the insufficient_scope error", func() {
+				So(response.Code, ShouldEqual, http.StatusForbidden)
+				So(response.Header().Get(authorisation.WWWAuthenticateHeader), ShouldEqual,
+					`Bearer realm="dp-api", error="insufficient_scope", error_description="the caller does not have the required permission"`)
+			})
+		})
+
+		Convey("When a request with an invalid Zebedee service token is rejected", func() {
+			middleware := authorisation.NewMiddlewareFromDependencies(mockJWTParser, permissionsChecker, &mock.ZebedeeClientMock{
+				CheckTokenIdentityFunc: func(ctx context.Context, token string) (*dprequest.IdentityResponse, error) {
+					return nil, errors.New("unauthorised")
+				},
+			}, identityClient, authorisation.WithChallenge("dp-api", true))
+			request.Header.Set("Authorization", authorisationtest.ZebedeeServiceToken)
+			middleware.Require(permission, (&mockHandler{}).ServeHTTP)(response, request)
+
+			Convey("Then no challenge is sent with the 403", func() {
+				So(response.Code, ShouldEqual, http.StatusForbidden)
+				So(response.Header().Get(authorisation.WWWAuthenticateHeader), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When a request is rejected due to a server error", func() {
+			permissionsErr = permsdk.ErrNotCached
+			middleware.Require(permission, (&mockHandler{}).ServeHTTP)(response, request)
+
+			Convey("Then no challenge is sent", func() {
+				So(response.Code, ShouldEqual, http.StatusInternalServerError)
+				So(response.Header().Get(authorisation.WWWAuthenticateHeader), ShouldBeEmpty)
+			})
+		})
+	})
+
+	Convey("Given a middleware instance with the default challenge configuration", t, func() {
+		mockJWTParser := &mock.JWTParserMock{
+			ParseFunc: func(tokenString string) (*permsdk.EntityData, error) {
+				return nil, jwt.ErrInvalidSignature
+			},
+		}
+		middleware := authorisation.NewMiddlewareFromDependencies(mockJWTParser, &mock.PermissionsCheckerMock{}, zebedeeIdentity, identityClient)
+		response := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, testURL, http.NoBody)
+		request.Header.Set("Authorization", authorisationtest.AdminJWTToken)
+
+		Convey("When a request with an invalid token is rejected", func() {
+			middleware.Require(permission, (&mockHandler{}).ServeHTTP)(response, request)
+
+			Convey("Then the challenge includes the error without a realm or description", func() {
+				So(response.Header().Get(authorisation.WWWAuthenticateHeader), ShouldEqual, `Bearer error="invalid_token"`)
+			})
+		})
+	})
+}
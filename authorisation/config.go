@@ -4,25 +4,78 @@ import "time"
 
 // Config contains the required configuration / environment variables for the typical authorisation setup
 type Config struct {
-	Enabled                        bool              `envconfig:"AUTHORISATION_ENABLED"`
-	JWTVerificationPublicKeys      map[string]string `envconfig:"JWT_VERIFICATION_PUBLIC_KEYS" json:"-"`
-	PermissionsAPIURL              string            `envconfig:"PERMISSIONS_API_URL"`
-	PermissionsCacheUpdateInterval time.Duration     `envconfig:"PERMISSIONS_CACHE_UPDATE_INTERVAL"`
-	PermissionsMaxCacheTime        time.Duration     `envconfig:"PERMISSIONS_MAX_CACHE_TIME"`
-	ZebedeeURL                     string            `envconfig:"ZEBEDEE_URL"`
-	IdentityWebKeySetURL           string            `envconfig:"IDENTITY_WEB_KEY_SET_URL"`
-	IdentityClientMaxRetries       int               `envconfig:"AUTHORISATION_IDENTITY_CLIENT_MAX_RETRIES"`
+	Enabled                         bool              `envconfig:"AUTHORISATION_ENABLED"`
+	Mode                            string            `envconfig:"AUTHORISATION_MODE"`
+	PermissionModes                 []string          `envconfig:"AUTHORISATION_PERMISSION_MODES"`
+	JWTVerificationPublicKeys       map[string]string `envconfig:"JWT_VERIFICATION_PUBLIC_KEYS" json:"-"`
+	PermissionsAPIURL               string            `envconfig:"PERMISSIONS_API_URL"`
+	PermissionsCacheUpdateInterval  time.Duration     `envconfig:"PERMISSIONS_CACHE_UPDATE_INTERVAL"`
+	PermissionsMaxCacheTime         time.Duration     `envconfig:"PERMISSIONS_MAX_CACHE_TIME"`
+	ZebedeeURL                      string            `envconfig:"ZEBEDEE_URL"`
+	ZebedeeIdentityCacheTTL         time.Duration     `envconfig:"AUTHORISATION_ZEBEDEE_IDENTITY_CACHE_TTL"`
+	ZebedeeIdentityCacheNegativeTTL time.Duration     `envconfig:"AUTHORISATION_ZEBEDEE_IDENTITY_CACHE_NEGATIVE_TTL"`
+	ZebedeeIdentityCacheMaxEntries  int               `envconfig:"AUTHORISATION_ZEBEDEE_IDENTITY_CACHE_MAX_ENTRIES"`
+	ZebedeeUserTokenSources         []string          `envconfig:"AUTHORISATION_ZEBEDEE_USER_TOKEN_SOURCES"`
+	ZebedeeUserTokenFallback        bool              `envconfig:"AUTHORISATION_ZEBEDEE_USER_TOKEN_FALLBACK"`
+	ZebedeeTokenActions             []string          `envconfig:"AUTHORISATION_ZEBEDEE_TOKEN_ACTIONS"`
+	IdentityWebKeySetURL            string            `envconfig:"IDENTITY_WEB_KEY_SET_URL"`
+	JWKSURL                         string            `envconfig:"AUTHORISATION_JWKS_URL"`
+	JWTIssuers                      []string          `envconfig:"AUTHORISATION_JWT_ISSUERS"`
+	JWTClientIDs                    []string          `envconfig:"AUTHORISATION_JWT_CLIENT_IDS"`
+	JWTTokenUse                     string            `envconfig:"AUTHORISATION_JWT_TOKEN_USE"`
+	JWTLeeway                       time.Duration     `envconfig:"AUTHORISATION_JWT_LEEWAY"`
+	JWTMaxLifetime                  time.Duration     `envconfig:"AUTHORISATION_JWT_MAX_LIFETIME"`
+	JWTUserIDClaim                  string            `envconfig:"AUTHORISATION_JWT_USER_ID_CLAIM"`
+	JWTGroupsClaim                  string            `envconfig:"AUTHORISATION_JWT_GROUPS_CLAIM"`
+	JWTGroupPrefix                  string            `envconfig:"AUTHORISATION_JWT_GROUP_PREFIX"`
+	JWTGroupsOptional               bool              `envconfig:"AUTHORISATION_JWT_GROUPS_OPTIONAL"`
+	JWTVerificationCacheTTL         time.Duration     `envconfig:"AUTHORISATION_JWT_VERIFICATION_CACHE_TTL"`
+	JWTVerificationCacheMaxEntries  int               `envconfig:"AUTHORISATION_JWT_VERIFICATION_CACHE_MAX_ENTRIES"`
+	JWTKeyRefreshMinInterval        time.Duration     `envconfig:"AUTHORISATION_JWT_KEY_REFRESH_MIN_INTERVAL"`
+	JWTKeyRefreshInterval           time.Duration     `envconfig:"AUTHORISATION_JWT_KEY_REFRESH_INTERVAL"`
+	JWTKeyMaxAge                    time.Duration     `envconfig:"AUTHORISATION_JWT_KEY_MAX_AGE"`
+	JWTKeySnapshotPath              string            `envconfig:"AUTHORISATION_JWT_KEY_SNAPSHOT_PATH"`
+	JWTKeySnapshotMaxAge            time.Duration     `envconfig:"AUTHORISATION_JWT_KEY_SNAPSHOT_MAX_AGE"`
+	IdentityClientMaxRetries        int               `envconfig:"AUTHORISATION_IDENTITY_CLIENT_MAX_RETRIES"`
+	ChallengeRealm                  string            `envconfig:"AUTHORISATION_CHALLENGE_REALM"`
+	ChallengeErrorDescriptions      bool              `envconfig:"AUTHORISATION_CHALLENGE_ERROR_DESCRIPTIONS"`
+	TokenSources                    []string          `envconfig:"AUTHORISATION_TOKEN_SOURCES"`
+	AuditLogEnabled                 bool              `envconfig:"AUTHORISATION_AUDIT_LOG_ENABLED"`
+	AuditFilePath                   string            `envconfig:"AUTHORISATION_AUDIT_FILE_PATH"`
+	AuditAllowSampleRate            float64           `envconfig:"AUTHORISATION_AUDIT_ALLOW_SAMPLE_RATE"`
 }
 
 // NewDefaultConfig populates the config struct with default values suitable for local development.
 func NewDefaultConfig() *Config {
 	return &Config{
-		JWTVerificationPublicKeys:      map[string]string{"NeKb65194Jo=": "MIICIjANBgkqhkiG9w0BAQEFAAOCAg8AMIICCgKCAgEA0TpTemKodQNChMNj1f/NF19nMAbjKbwRENSKujO5iwXLIt0hCjh5dz4egKQo7KEr2ex3qdy50LWKD871gRfAgDoRD5/1kUUVqII5K09IDCVY/EohukrI+Uep/Z5ymPNPXXD1yJvBx/YmmuMGUAT5UKHKBCP+FcoAxYAKcaKhtL0iyVjhtD0Y4V8gcQnQq3bOYhF4FEHoHBNh23AKcJM1VvNVtSHViMuTOzsFLHAgy2lLsRLnxtXovEovAiTay+Sn1FuDOq2gswl2Uujh1GO8kfkXE1gNRn/l7RUYIRrql8kROHMSYvPBAIqYhGSWOG3JX1oFlI1erYaeIPI4l4Qj/P+YSnrRx0di3vy6ZDAnhs8kdZP81F+3rFrNUNIOVFBRKscMnvOH4HO4f9PpXynde5xTlVvqdgXVlWkxGgQk0d323ka8fPY1xsmxV99idmmgmfglPOeLxuOkFxfXJSpbP/kn9AEyKBcF2BImfc12uvdSn46zZ1f/8nvzQ9naruwEtho4t6cIb7A+5KxVAILCQHvm3xIxfxMy5RFIeR7T3KhW2URDtiGMKuEE44EQwtxXxnMUdmvBUyHg2iQ54ELD4uVVVkGZkT5cTIf8iwfWI808B+CE5T8I3YrK7DiaVkJqTWX9LqWqetwHQxY48iTN+nPguHQ6dkZwmxuWBEuQ9eECAwEAAQ=="},
-		PermissionsAPIURL:              "http://localhost:25400",
-		ZebedeeURL:                     "http://localhost:8082",
-		IdentityWebKeySetURL:           "http://localhost:25600",
-		PermissionsCacheUpdateInterval: time.Minute * 1,
-		PermissionsMaxCacheTime:        time.Minute * 5,
-		IdentityClientMaxRetries:       2,
+		JWTVerificationPublicKeys:       map[string]string{"NeKb65194Jo=": "MIICIjANBgkqhkiG9w0BAQEFAAOCAg8AMIICCgKCAgEA0TpTemKodQNChMNj1f/NF19nMAbjKbwRENSKujO5iwXLIt0hCjh5dz4egKQo7KEr2ex3qdy50LWKD871gRfAgDoRD5/1kUUVqII5K09IDCVY/EohukrI+Uep/Z5ymPNPXXD1yJvBx/YmmuMGUAT5UKHKBCP+FcoAxYAKcaKhtL0iyVjhtD0Y4V8gcQnQq3bOYhF4FEHoHBNh23AKcJM1VvNVtSHViMuTOzsFLHAgy2lLsRLnxtXovEovAiTay+Sn1FuDOq2gswl2Uujh1GO8kfkXE1gNRn/l7RUYIRrql8kROHMSYvPBAIqYhGSWOG3JX1oFlI1erYaeIPI4l4Qj/P+YSnrRx0di3vy6ZDAnhs8kdZP81F+3rFrNUNIOVFBRKscMnvOH4HO4f9PpXynde5xTlVvqdgXVlWkxGgQk0d323ka8fPY1xsmxV99idmmgmfglPOeLxuOkFxfXJSpbP/kn9AEyKBcF2BImfc12uvdSn46zZ1f/8nvzQ9naruwEtho4t6cIb7A+5KxVAILCQHvm3xIxfxMy5RFIeR7T3KhW2URDtiGMKuEE44EQwtxXxnMUdmvBUyHg2iQ54ELD4uVVVkGZkT5cTIf8iwfWI808B+CE5T8I3YrK7DiaVkJqTWX9LqWqetwHQxY48iTN+nPguHQ6dkZwmxuWBEuQ9eECAwEAAQ=="},
+		PermissionsAPIURL:               "http://localhost:25400",
+		ZebedeeURL:                      "http://localhost:8082",
+		IdentityWebKeySetURL:            "http://localhost:25600",
+		PermissionsCacheUpdateInterval:  time.Minute * 1,
+		PermissionsMaxCacheTime:         time.Minute * 5,
+		IdentityClientMaxRetries:        2,
+		JWTLeeway:                       30 * time.Second,
+		JWTVerificationCacheMaxEntries:  1000,
+		JWTKeyRefreshMinInterval:        time.Minute,
+		JWTKeyRefreshInterval:           15 * time.Minute,
+		JWTKeySnapshotMaxAge:            24 * time.Hour,
+		ZebedeeIdentityCacheTTL:         time.Minute,
+		ZebedeeIdentityCacheNegativeTTL: 5 * time.Second,
+		ZebedeeIdentityCacheMaxEntries:  1000,
+		ChallengeRealm:                  "dp-api",
+		ChallengeErrorDescriptions:      true,
+		AuditAllowSampleRate:            1,
 	}
 }
+
+// EffectiveMode returns the configured Mode. If no mode is configured, it is derived from the Enabled flag.
+func (c *Config) EffectiveMode() (Mode, error) {
+	if c.Mode == "" {
+		if c.Enabled {
+			return ModeEnforce, nil
+		}
+		return ModeDisabled, nil
+	}
+	return ParseMode(c.Mode)
+}
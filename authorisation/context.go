@@ -0,0 +1,108 @@
+package authorisation
+
+import (
+	"context"
+
+	"github.com/ONSdigital/dp-authorisation/v2/jwt"
+	"github.com/ONSdigital/dp-authorisation/v2/permissions"
+	permsdk "github.com/ONSdigital/dp-permissions-api/sdk"
+)
+
+// TokenType identifies the kind of token that was used to authenticate a request
+type TokenType string
+
+const (
+	// TokenTypeNone is used when the request was not authenticated, e.g. when authorisation is disabled
+	TokenTypeNone TokenType = "none"
+	// TokenTypeJWT is used for requests authenticated with a JWT access token
+	TokenTypeJWT TokenType = "jwt"
+	// TokenTypeZebedeeService is used for requests authenticated with an old world Zebedee service token
+	TokenTypeZebedeeService TokenType = "zebedee_service"
+	// TokenTypeZebedeeUser is used for requests authenticated with an old world Zebedee (Florence) user token
+	TokenTypeZebedeeUser TokenType = "zebedee_user"
+)
+
+// contextKey is an unexported type for the keys used to store authorisation values in a context,
+// preventing collisions with keys defined in other packages.
+type contextKey string
+
+const entityContextKey = contextKey("authorisation-entity")
+
+// Entity represents the authenticated caller of a request, as resolved by the authorisation middleware.
+type Entity struct {
+	EntityData permsdk.EntityData
+	TokenType  TokenType
+	Claims     jwt.Claims
+
+	// serviceID is only set for an authenticated service, so that it cannot be derived from a user ID
+	serviceID string
+}
+
+// NewServiceEntity returns the entity for a service authenticated using a token of the given type. Only a
+// TokenVerifier that has authenticated the service should create one, as the service is granted the permissions of
+// its 'services/<service ID>' entity.
+func NewServiceEntity(serviceID string, tokenType TokenType) *Entity {
+	return &Entity{
+		EntityData: permissions.NewServiceEntityData(serviceID),
+		TokenType:  tokenType,
+		serviceID:  serviceID,
+	}
+}
+
+// UserID returns the ID of the authenticated user, or the entity ID of the authenticated service, e.g. 'services/<service ID>'
+func (e *Entity) UserID() string {
+	return e.EntityData.UserID
+}
+
+// ServiceID returns the ID of the authenticated service, and false if the caller is not a service
+func (e *Entity) ServiceID() (string, bool) {
+	return e.serviceID, e.serviceID != ""
+}
+
+// newCheckContext returns the context used to check the entity's permissions, which identifies the caller to the
+// permissions checker if it is a service
+func (e *Entity) newCheckContext(ctx context.Context) context.Context {
+	if serviceID, ok := e.ServiceID(); ok {
+		return permissions.NewContextWithService(ctx, serviceID)
+	}
+	return ctx
+}
+
+// Groups returns the list of groups the authenticated user belongs to
+func (e *Entity) Groups() []string {
+	return e.EntityData.Groups
+}
+
+// NewContextWithEntity returns a copy of the given context that holds the given entity
+func NewContextWithEntity(ctx context.Context, entity *Entity) context.Context {
+	return context.WithValue(ctx, entityContextKey, entity)
+}
+
+// EntityFromContext returns the entity stored in the given context by the authorisation middleware, if there is one
+func EntityFromContext(ctx context.Context) (*Entity, bool) {
+	entity, ok := ctx.Value(entityContextKey).(*Entity)
+	if !ok || entity == nil {
+		return nil, false
+	}
+	return entity, true
+}
+
+// EntityDataFromContext returns the EntityData (user ID and groups) of the entity stored in the given context.
+// An empty EntityData value is returned if there is no entity in the context.
+func EntityDataFromContext(ctx context.Context) permsdk.EntityData {
+	entity, ok := EntityFromContext(ctx)
+	if !ok {
+		return permsdk.EntityData{}
+	}
+	return entity.EntityData
+}
+
+// ClaimsFromContext returns the verified claims of the JWT used to authenticate the request, e.g. to read the user's
+// email or when the token expires. False is returned if the request was not authenticated using a JWT.
+func ClaimsFromContext(ctx context.Context) (jwt.Claims, bool) {
+	entity, ok := EntityFromContext(ctx)
+	if !ok || entity.TokenType != TokenTypeJWT || entity.Claims == nil {
+		return nil, false
+	}
+	return entity.Claims, true
+}
@@ -0,0 +1,119 @@
+package authorisation_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ONSdigital/dp-authorisation/v2/authorisation"
+	"github.com/ONSdigital/dp-authorisation/v2/jwt"
+	permsdk "github.com/ONSdigital/dp-permissions-api/sdk"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEntityFromContext(t *testing.T) {
+	Convey("Given a context that contains an entity", t, func() {
+		entity := &authorisation.Entity{
+			EntityData: permsdk.EntityData{UserID: "fred", Groups: []string{"admin"}},
+			TokenType:  authorisation.TokenTypeJWT,
+		}
+		ctx := authorisation.NewContextWithEntity(context.Background(), entity)
+
+		Convey("When EntityFromContext is called", func() {
+			actual, ok := authorisation.EntityFromContext(ctx)
+
+			Convey("Then the entity is returned", func() {
+				So(ok, ShouldBeTrue)
+				So(actual, ShouldEqual, entity)
+				So(actual.UserID(), ShouldEqual, "fred")
+				So(actual.Groups(), ShouldResemble, []string{"admin"})
+			})
+		})
+
+		Convey("When EntityDataFromContext is called", func() {
+			entityData := authorisation.EntityDataFromContext(ctx)
+
+			Convey("Then the entity data is returned", func() {
+				So(entityData, ShouldResemble, entity.EntityData)
+			})
+		})
+	})
+
+	Convey("Given a context without an entity", t, func() {
+		ctx := context.Background()
+
+		Convey("When EntityFromContext is called", func() {
+			actual, ok := authorisation.EntityFromContext(ctx)
+
+			Convey("Then no entity is returned", func() {
+				So(ok, ShouldBeFalse)
+				So(actual, ShouldBeNil)
+			})
+		})
+
+		Convey("When EntityDataFromContext is called", func() {
+			entityData := authorisation.EntityDataFromContext(ctx)
+
+			Convey("Then empty entity data is returned", func() {
+				So(entityData, ShouldResemble, permsdk.EntityData{})
+			})
+		})
+	})
+}
+
+func TestClaimsFromContext(t *testing.T) {
+	Convey("Given a context that contains an entity authenticated using a JWT", t, func() {
+		entity := &authorisation.Entity{
+			EntityData: permsdk.EntityData{UserID: "fred"},
+			TokenType:  authorisation.TokenTypeJWT,
+			Claims:     jwt.Claims{"email": "fred@ons.gov.uk", "exp": json.Number("1700000000")},
+		}
+		ctx := authorisation.NewContextWithEntity(context.Background(), entity)
+
+		Convey("When ClaimsFromContext is called", func() {
+			claims, ok := authorisation.ClaimsFromContext(ctx)
+
+			Convey("Then the claims are returned", func() {
+				So(ok, ShouldBeTrue)
+				So(claims.Email(), ShouldEqual, "fred@ons.gov.uk")
+				expiresAt, ok := claims.ExpiresAt()
+				So(ok, ShouldBeTrue)
+				So(expiresAt.Unix(), ShouldEqual, 1700000000)
+			})
+		})
+	})
+
+	Convey("Given a context that contains an entity authenticated using a Zebedee token", t, func() {
+		entity := authorisation.NewServiceEntity("dp-dataset-api", authorisation.TokenTypeZebedeeService)
+		ctx := authorisation.NewContextWithEntity(context.Background(), entity)
+
+		Convey("When ClaimsFromContext is called", func() {
+			_, ok := authorisation.ClaimsFromContext(ctx)
+
+			Convey("Then no claims are returned", func() {
+				So(ok, ShouldBeFalse)
+			})
+		})
+	})
+}
+
+func TestNoopMiddleware_Require_Entity(t *testing.T) {
+	Convey("Given a noop middleware wrapping a handler", t, func() {
+		mockHandler := &mockHandler{calls: 0}
+		middlewareFunc := authorisation.NewNoopMiddleware().Require(permission, mockHandler.ServeHTTP)
+
+		Convey("When the middleware function is called", func() {
+			middlewareFunc(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, testURL, http.NoBody))
+
+			Convey("Then an unauthenticated entity is available from the request context", func() {
+				So(mockHandler.calls, ShouldEqual, 1)
+				entity, ok := authorisation.EntityFromContext(mockHandler.request.Context())
+				So(ok, ShouldBeTrue)
+				So(entity.TokenType, ShouldEqual, authorisation.TokenTypeNone)
+				So(entity.UserID(), ShouldBeEmpty)
+			})
+		})
+	})
+}
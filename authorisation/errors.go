@@ -0,0 +1,202 @@
+package authorisation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/ONSdigital/dp-authorisation/v2/jwt"
+	permsdk "github.com/ONSdigital/dp-permissions-api/sdk"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem details responses
+const ProblemContentType = "application/problem+json"
+
+// ErrorCode is a stable, machine readable code describing why the middleware rejected a request
+type ErrorCode string
+
+// Error codes returned by the authorisation middleware
+const (
+	ErrorCodeMissingToken           ErrorCode = "missing_token"
+	ErrorCodeTokenExpired           ErrorCode = "token_expired"
+	ErrorCodeTokenNotYetValid       ErrorCode = "token_not_yet_valid"
+	ErrorCodeTokenMalformed         ErrorCode = "token_malformed"
+	ErrorCodeInvalidSignature       ErrorCode = "invalid_signature"
+	ErrorCodeUnsupportedAlgorithm   ErrorCode = "unsupported_algorithm"
+	ErrorCodeUnknownSigningKey      ErrorCode = "unknown_signing_key"
+	ErrorCodeInvalidClaims          ErrorCode = "invalid_claims"
+	ErrorCodeInvalidToken           ErrorCode = "invalid_token"
+	ErrorCodeUnsupportedToken       ErrorCode = "unsupported_token"
+	ErrorCodeSigningKeysUnavailable ErrorCode = "signing_keys_unavailable"
+	ErrorCodeInvalidServiceToken    ErrorCode = "invalid_service_token"
+	ErrorCodeInvalidUserToken       ErrorCode = "invalid_user_token"
+	ErrorCodeTokenDeprecated        ErrorCode = "token_deprecated"
+	ErrorCodeAttributesUnavailable  ErrorCode = "attributes_unavailable"
+	ErrorCodePermissionsUnavailable ErrorCode = "permissions_unavailable"
+	ErrorCodePermissionCheckFailed  ErrorCode = "permission_check_failed"
+	ErrorCodePermissionDenied       ErrorCode = "permission_denied"
+)
+
+// errorDetails contains the response status and human readable description for each error code
+var errorDetails = map[ErrorCode]struct {
+	status int
+	detail string
+}{
+	ErrorCodeMissingToken:           {http.StatusUnauthorized, "the request does not contain an access token"},
+	ErrorCodeTokenExpired:           {http.StatusUnauthorized, "the access token has expired"},
+	ErrorCodeTokenNotYetValid:       {http.StatusUnauthorized, "the access token is not valid yet"},
+	ErrorCodeTokenMalformed:         {http.StatusUnauthorized, "the access token is malformed"},
+	ErrorCodeInvalidSignature:       {http.StatusUnauthorized, "the access token signature is invalid"},
+	ErrorCodeUnsupportedAlgorithm:   {http.StatusUnauthorized, "the access token is signed with an unsupported algorithm"},
+	ErrorCodeUnknownSigningKey:      {http.StatusUnauthorized, "the access token is signed with an unknown key"},
+	ErrorCodeInvalidClaims:          {http.StatusUnauthorized, "the access token does not contain the required claims"},
+	ErrorCodeInvalidToken:           {http.StatusUnauthorized, "the access token is invalid"},
+	ErrorCodeUnsupportedToken:       {http.StatusUnauthorized, "the access token is not of a supported type"},
+	ErrorCodeSigningKeysUnavailable: {http.StatusInternalServerError, "the keys required to verify access tokens are unavailable"},
+	ErrorCodeInvalidServiceToken:    {http.StatusForbidden, "the service token is invalid"},
+	ErrorCodeInvalidUserToken:       {http.StatusUnauthorized, "the user token is invalid"},
+	ErrorCodeTokenDeprecated:        {http.StatusUnauthorized, "zebedee tokens are no longer accepted, use a JWT access token"},
+	ErrorCodeAttributesUnavailable:  {http.StatusInternalServerError, "the request attributes required for authorisation could not be read"},
+	ErrorCodePermissionsUnavailable: {http.StatusInternalServerError, "permissions data is currently unavailable"},
+	ErrorCodePermissionCheckFailed:  {http.StatusInternalServerError, "the permissions check failed"},
+	ErrorCodePermissionDenied:       {http.StatusForbidden, "the caller does not have the required permission"},
+}
+
+// Error describes why the authorisation middleware rejected a request. The underlying cause in Err
+// is for logging purposes and is not written to the response.
+type Error struct {
+	Status int
+	Code   ErrorCode
+	Detail string
+	Err    error
+}
+
+// NewError creates an Error for the given code, wrapping the underlying cause if there is one.
+func NewError(code ErrorCode, cause error) *Error {
+	details, ok := errorDetails[code]
+	if !ok {
+		details = errorDetails[ErrorCodePermissionCheckFailed]
+	}
+	return &Error{
+		Status: details.status,
+		Code:   code,
+		Detail: details.detail,
+		Err:    cause,
+	}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return string(e.Code) + ": " + e.Err.Error()
+	}
+	return string(e.Code)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ErrorResponder writes the response for a request that has been rejected by the authorisation middleware.
+// Implement this interface to render errors using a service specific error envelope.
+type ErrorResponder interface {
+	RespondError(w http.ResponseWriter, req *http.Request, authErr *Error)
+}
+
+// ErrorResponderFunc is an adapter allowing an ordinary function to be used as an ErrorResponder
+type ErrorResponderFunc func(w http.ResponseWriter, req *http.Request, authErr *Error)
+
+// RespondError calls f(w, req, authErr)
+func (f ErrorResponderFunc) RespondError(w http.ResponseWriter, req *http.Request, authErr *Error) {
+	f(w, req, authErr)
+}
+
+// Problem is an RFC 7807 problem details response body, with the error code as an extension member
+type Problem struct {
+	Type     string    `json:"type"`
+	Title    string    `json:"title"`
+	Status   int       `json:"status"`
+	Detail   string    `json:"detail,omitempty"`
+	Instance string    `json:"instance,omitempty"`
+	Code     ErrorCode `json:"code"`
+}
+
+// ProblemResponder is the default ErrorResponder, writing application/problem+json response bodies.
+//   - TypeBaseURI - if set, the problem type is the base URI followed by the error code, otherwise it is 'about:blank'
+type ProblemResponder struct {
+	TypeBaseURI string
+}
+
+// NewProblemResponder creates a new instance of ProblemResponder
+func NewProblemResponder() *ProblemResponder {
+	return &ProblemResponder{}
+}
+
+// RespondError writes the given error as an RFC 7807 problem details response
+func (p *ProblemResponder) RespondError(w http.ResponseWriter, req *http.Request, authErr *Error) {
+	problem := Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(authErr.Status),
+		Status: authErr.Status,
+		Detail: authErr.Detail,
+		Code:   authErr.Code,
+	}
+	if p.TypeBaseURI != "" {
+		problem.Type = p.TypeBaseURI + string(authErr.Code)
+	}
+	ctx := context.Background()
+	if req != nil {
+		ctx = req.Context()
+		if req.URL != nil {
+			problem.Instance = req.URL.Path
+		}
+	}
+
+	body, err := json.Marshal(problem)
+	if err != nil {
+		log.Error(ctx, "failed to marshal authorisation problem response", err)
+		w.WriteHeader(authErr.Status)
+		return
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(authErr.Status)
+	if _, err := w.Write(body); err != nil {
+		log.Error(ctx, "failed to write authorisation problem response", err)
+	}
+}
+
+// newJWTError maps errors from the jwt package to an authorisation Error
+func newJWTError(err error) *Error {
+	switch {
+	case errors.Is(err, jwt.ErrPublickeysEmpty):
+		return NewError(ErrorCodeSigningKeysUnavailable, err)
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return NewError(ErrorCodeTokenExpired, err)
+	case errors.Is(err, jwt.ErrTokenNotYetValid), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		return NewError(ErrorCodeTokenNotYetValid, err)
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return NewError(ErrorCodeTokenMalformed, err)
+	case errors.Is(err, jwt.ErrInvalidSignature):
+		return NewError(ErrorCodeInvalidSignature, err)
+	case errors.Is(err, jwt.ErrTokenUnsupportedEncryption), errors.Is(err, jwt.ErrAlgorithmNotAllowed):
+		return NewError(ErrorCodeUnsupportedAlgorithm, err)
+	case errors.Is(err, jwt.ErrJWTKeySet):
+		return NewError(ErrorCodeUnknownSigningKey, err)
+	case errors.Is(err, jwt.ErrNoUserID), errors.Is(err, jwt.ErrNoGroups), errors.Is(err, jwt.ErrFailedToParseClaims),
+		errors.Is(err, jwt.ErrInvalidIssuer), errors.Is(err, jwt.ErrInvalidAudience), errors.Is(err, jwt.ErrInvalidTokenUse),
+		errors.Is(err, jwt.ErrTokenLifetimeTooLong):
+		return NewError(ErrorCodeInvalidClaims, err)
+	default:
+		return NewError(ErrorCodeInvalidToken, err)
+	}
+}
+
+// newPermissionsError maps errors from the permissions checker to an authorisation Error
+func newPermissionsError(err error) *Error {
+	if errors.Is(err, permsdk.ErrNotCached) {
+		return NewError(ErrorCodePermissionsUnavailable, err)
+	}
+	return NewError(ErrorCodePermissionCheckFailed, err)
+}
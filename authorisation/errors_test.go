@@ -0,0 +1,182 @@
+package authorisation_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ONSdigital/dp-authorisation/v2/authorisation"
+	"github.com/ONSdigital/dp-authorisation/v2/authorisation/mock"
+	"github.com/ONSdigital/dp-authorisation/v2/authorisationtest"
+	"github.com/ONSdigital/dp-authorisation/v2/jwt"
+	permsdk "github.com/ONSdigital/dp-permissions-api/sdk"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestProblemResponder_RespondError(t *testing.T) {
+	Convey("Given a problem responder", t, func() {
+		responder := authorisation.NewProblemResponder()
+		response := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, testURL+"/datasets", http.NoBody)
+
+		Convey("When RespondError is called", func() {
+			responder.RespondError(response, request, authorisation.NewError(authorisation.ErrorCodeTokenExpired, jwt.ErrTokenExpired))
+
+			Convey("Then the response has the expected status and content type", func() {
+				So(response.Code, ShouldEqual, http.StatusUnauthorized)
+				So(response.Header().Get("Content-Type"), ShouldEqual, authorisation.ProblemContentType)
+			})
+
+			Convey("Then the response body is the expected problem", func() {
+				var problem authorisation.Problem
+				So(json.Unmarshal(response.Body.Bytes(), &problem), ShouldBeNil)
+				So(problem, ShouldResemble, authorisation.Problem{
+					Type:     "about:blank",
+					Title:    "Unauthorized",
+					Status:   http.StatusUnauthorized,
+					Detail:   "the access token has expired",
+					Instance: "/datasets",
+					Code:     authorisation.ErrorCodeTokenExpired,
+				})
+			})
+		})
+
+		Convey("When RespondError is called with a type base URI set", func() {
+			responder.TypeBaseURI = "https://developer.ons.gov.uk/problems/"
+			responder.RespondError(response, request, authorisation.NewError(authorisation.ErrorCodePermissionDenied, nil))
+
+			Convey("Then the problem type is derived from the error code", func() {
+				var problem authorisation.Problem
+				So(json.Unmarshal(response.Body.Bytes(), &problem), ShouldBeNil)
+				So(problem.Type, ShouldEqual, "https://developer.ons.gov.uk/problems/permission_denied")
+				So(problem.Status, ShouldEqual, http.StatusForbidden)
+			})
+		})
+
+		Convey("When RespondError is called without a request and the response cannot be written", func() {
+			writer := &failingResponseWriter{ResponseRecorder: response}
+			respond := func() {
+				responder.RespondError(writer, nil, authorisation.NewError(authorisation.ErrorCodePermissionDenied, nil))
+			}
+
+			Convey("Then the write failure is logged without panicking", func() {
+				So(respond, ShouldNotPanic)
+				So(response.Code, ShouldEqual, http.StatusForbidden)
+			})
+		})
+	})
+}
+
+// failingResponseWriter is a response writer whose body cannot be written
+type failingResponseWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w *failingResponseWriter) Write([]byte) (int, error) {
+	return 0, errors.New("connection closed")
+}
+
+func TestMiddleware_Require_ErrorCodes(t *testing.T) {
+	Convey("Given a middleware instance", t, func() {
+		parseErr := error(nil)
+		mockJWTParser := &mock.JWTParserMock{
+			ParseFunc: func(tokenString string) (*permsdk.EntityData, error) {
+				if parseErr != nil {
+					return nil, parseErr
+				}
+				return dummyEntityData, nil
+			},
+		}
+		permissionsErr := error(nil)
+		permissionsChecker := &mock.PermissionsCheckerMock{
+			HasPermissionFunc: func(ctx context.Context, entityData permsdk.EntityData, permission string, attributes map[string]string) (bool, error) {
+				return false, permissionsErr
+			},
+		}
+		middleware := authorisation.NewMiddlewareFromDependencies(mockJWTParser, permissionsChecker, zebedeeIdentity, identityClient)
+		response := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, testURL, http.NoBody)
+		request.Header.Set("Authorization", authorisationtest.AdminJWTToken)
+
+		problem := func() authorisation.Problem {
+			var p authorisation.Problem
+			So(json.Unmarshal(response.Body.Bytes(), &p), ShouldBeNil)
+			return p
+		}
+
+		tests := []struct {
+			parseErr       error
+			permissionsErr error
+			status         int
+			code           authorisation.ErrorCode
+		}{
+			{jwt.ErrTokenExpired, nil, http.StatusUnauthorized, authorisation.ErrorCodeTokenExpired},
+			{jwt.ErrTokenUsedBeforeIssued, nil, http.StatusUnauthorized, authorisation.ErrorCodeTokenNotYetValid},
+			{jwt.ErrInvalidSignature, nil, http.StatusUnauthorized, authorisation.ErrorCodeInvalidSignature},
+			{jwt.ErrJWTKeySet, nil, http.StatusUnauthorized, authorisation.ErrorCodeUnknownSigningKey},
+			{jwt.ErrAlgorithmNotAllowed, nil, http.StatusUnauthorized, authorisation.ErrorCodeUnsupportedAlgorithm},
+			{jwt.ErrInvalidAudience, nil, http.StatusUnauthorized, authorisation.ErrorCodeInvalidClaims},
+			{jwt.ErrPublickeysEmpty, nil, http.StatusInternalServerError, authorisation.ErrorCodeSigningKeysUnavailable},
+			{nil, permsdk.ErrNotCached, http.StatusInternalServerError, authorisation.ErrorCodePermissionsUnavailable},
+			{nil, errors.New("unexpected"), http.StatusInternalServerError, authorisation.ErrorCodePermissionCheckFailed},
+			{nil, nil, http.StatusForbidden, authorisation.ErrorCodePermissionDenied},
+		}
+
+		for _, tc := range tests {
+			Convey("When the request is rejected with the "+string(tc.code)+" error code", func() {
+				parseErr = tc.parseErr
+				permissionsErr = tc.permissionsErr
+				middleware.Require(permission, (&mockHandler{}).ServeHTTP)(response, request)
+
+				Convey("Then the expected problem response is written", func() {
+					So(response.Code, ShouldEqual, tc.status)
+					So(problem().Code, ShouldEqual, tc.code)
+				})
+			})
+		}
+
+		Convey("When a request without an access token is rejected", func() {
+			request.Header.Del("Authorization")
+			middleware.Require(permission, (&mockHandler{}).ServeHTTP)(response, request)
+
+			Convey("Then the missing token error code is returned", func() {
+				So(response.Code, ShouldEqual, http.StatusUnauthorized)
+				So(problem().Code, ShouldEqual, authorisation.ErrorCodeMissingToken)
+			})
+		})
+	})
+}
+
+func TestMiddleware_WithErrorResponder(t *testing.T) {
+	Convey("Given a middleware instance with a custom error responder", t, func() {
+		var responded *authorisation.Error
+		responder := authorisation.ErrorResponderFunc(func(w http.ResponseWriter, req *http.Request, authErr *authorisation.Error) {
+			responded = authErr
+			w.WriteHeader(http.StatusTeapot)
+		})
+		mockJWTParser := &mock.JWTParserMock{
+			ParseFunc: func(tokenString string) (*permsdk.EntityData, error) {
+				return nil, jwt.ErrTokenExpired
+			},
+		}
+		middleware := authorisation.NewMiddlewareFromDependencies(mockJWTParser, &mock.PermissionsCheckerMock{}, zebedeeIdentity, identityClient,
+			authorisation.WithErrorResponder(responder))
+		response := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, testURL, http.NoBody)
+		request.Header.Set("Authorization", authorisationtest.AdminJWTToken)
+
+		Convey("When the request is rejected", func() {
+			middleware.Require(permission, (&mockHandler{}).ServeHTTP)(response, request)
+
+			Convey("Then the custom error responder writes the response", func() {
+				So(response.Code, ShouldEqual, http.StatusTeapot)
+				So(responded.Code, ShouldEqual, authorisation.ErrorCodeTokenExpired)
+				So(responded.Status, ShouldEqual, http.StatusUnauthorized)
+				So(errors.Is(responded, jwt.ErrTokenExpired), ShouldBeTrue)
+			})
+		})
+	})
+}
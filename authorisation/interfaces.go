@@ -4,24 +4,32 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/ONSdigital/dp-authorisation/v2/jwt"
 	health "github.com/ONSdigital/dp-healthcheck/healthcheck"
 	dprequest "github.com/ONSdigital/dp-net/v3/request"
 	permsdk "github.com/ONSdigital/dp-permissions-api/sdk"
 )
 
 //go:generate moq -out mock/jwt_parser.go -pkg mock . JWTParser
+//go:generate moq -out mock/jwt_claims_parser.go -pkg mock . JWTClaimsParser
 //go:generate moq -out mock/permissions_checker.go -pkg mock . PermissionsChecker
 //go:generate moq -out mock/middleware.go -pkg mock . Middleware
 //go:generate moq -out mock/zebedeeclient.go -pkg mock . ZebedeeClient
+//go:generate moq -out mock/token_verifier.go -pkg mock . TokenVerifier
 
 // Middleware represents the high level interface for authorisation middleware
 type Middleware interface {
 	Require(permission string, handlerFunc http.HandlerFunc) http.HandlerFunc
 	RequireWithAttributes(permission string, handlerFunc http.HandlerFunc, getAttributes GetAttributesFromRequest) http.HandlerFunc
+	RequireAny(permissions []string, handlerFunc http.HandlerFunc) http.HandlerFunc
+	RequireAnyWithAttributes(permissions []string, handlerFunc http.HandlerFunc, getAttributes GetAttributesFromRequest) http.HandlerFunc
+	RequireAll(permissions []string, handlerFunc http.HandlerFunc) http.HandlerFunc
+	RequireAllWithAttributes(permissions []string, handlerFunc http.HandlerFunc, getAttributes GetAttributesFromRequest) http.HandlerFunc
 	Close(ctx context.Context) error
 	Parse(token string) (*permsdk.EntityData, error)
 	HealthCheck(ctx context.Context, state *health.CheckState) error
 	IdentityHealthCheck(ctx context.Context, state *health.CheckState) error
+	TokenUsageReport() []TokenUsage
 }
 
 // JWTParser takes a raw JWT token string, verifying it and extracting the required entity data.
@@ -29,6 +37,13 @@ type JWTParser interface {
 	Parse(tokenString string) (*permsdk.EntityData, error)
 }
 
+// JWTClaimsParser is a JWTParser that also returns all the verified claims of the token. The claims are only
+// available to handlers, see ClaimsFromContext, if the parser used by the middleware implements it.
+type JWTClaimsParser interface {
+	JWTParser
+	ParseWithClaims(tokenString string) (*jwt.ParseResult, error)
+}
+
 // PermissionsChecker checks if the given entity data matches the given permission
 type PermissionsChecker interface {
 	HasPermission(ctx context.Context,
@@ -36,11 +51,29 @@ type PermissionsChecker interface {
 		permission string,
 		attributes map[string]string,
 	) (bool, error)
+	HasAnyPermission(ctx context.Context,
+		entityData permsdk.EntityData,
+		permissions []string,
+		attributes map[string]string,
+	) (bool, error)
+	HasAllPermissions(ctx context.Context,
+		entityData permsdk.EntityData,
+		permissions []string,
+		attributes map[string]string,
+	) (bool, error)
 	Close(ctx context.Context) error
 	HealthCheck(ctx context.Context, state *health.CheckState) error
 }
 
-// ZebedeeClient validates old world token
+// ZebedeeClient validates old world service and Florence user tokens
 type ZebedeeClient interface {
 	CheckTokenIdentity(ctx context.Context, token string) (*dprequest.IdentityResponse, error)
+	CheckUserTokenIdentity(ctx context.Context, token string) (*dprequest.IdentityResponse, error)
+}
+
+// TokenVerifier verifies access tokens of a particular type, resolving the entity that the token identifies.
+// The middleware uses the first of its registered verifiers that can handle the token.
+type TokenVerifier interface {
+	CanHandle(token Token) bool
+	Verify(ctx context.Context, token Token) (*Entity, error)
 }
@@ -6,16 +6,22 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/ONSdigital/dp-api-clients-go/v2/headers"
+	"github.com/ONSdigital/dp-authorisation/v2/audit"
 	"github.com/ONSdigital/dp-authorisation/v2/identityclient"
+	"github.com/ONSdigital/dp-authorisation/v2/internal/tracing"
 	"github.com/ONSdigital/dp-authorisation/v2/jwt"
+	"github.com/ONSdigital/dp-authorisation/v2/metrics"
 	"github.com/ONSdigital/dp-authorisation/v2/permissions"
-	"github.com/ONSdigital/dp-authorisation/v2/zebedeeclient"
 	health "github.com/ONSdigital/dp-healthcheck/healthcheck"
 	"github.com/ONSdigital/dp-net/v3/request"
 	permsdk "github.com/ONSdigital/dp-permissions-api/sdk"
 	"github.com/ONSdigital/log.go/v2/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -23,12 +29,37 @@ const (
 	IdentityClientError      = "identity client cannot be nil"
 )
 
+// span attribute keys. The token itself is never added to a span.
+const (
+	attributePermissions = "authorisation.permissions"
+	attributeTokenSource = "authorisation.token_source"
+	attributeTokenType   = "authorisation.token_type"
+	attributeOutcome     = "authorisation.outcome"
+	attributeReason      = "authorisation.reason"
+	attributeMode        = "authorisation.mode"
+)
+
 // PermissionCheckMiddleware is used to wrap HTTP handlers with JWT token based authorisation
 type PermissionCheckMiddleware struct {
 	jwtParser          JWTParser
 	permissionsChecker PermissionsChecker
 	zebedeeClient      ZebedeeClient
 	IdentityClient     *identityclient.IdentityClient
+	errorResponder     ErrorResponder
+	challenge          bearerChallenge
+	tokenExtractor     TokenExtractor
+	tokenVerifiers     []TokenVerifier
+	auditSink          audit.Sink
+	metrics            *metrics.Metrics
+	tracerProvider     trace.TracerProvider
+	mode               Mode
+	permissionModes    map[string]Mode
+
+	zebedeeUserTokenSources  []string
+	zebedeeUserTokenFallback bool
+	zebedeeTokenActions      map[string]ZebedeeTokenAction
+	routeFunc                RouteFunc
+	tokenUsage               *tokenUsageTracker
 }
 
 // GetAttributesFromRequest defines the func that retrieves and returns attributes from the request. Used by
@@ -36,23 +67,39 @@ type PermissionCheckMiddleware struct {
 // implementation that meets your requirements.
 type GetAttributesFromRequest func(req *http.Request) (attributes map[string]string, err error)
 
-// NewFeatureFlaggedMiddleware returns a different Middleware implementation depending on the configured feature flag value
+// NewFeatureFlaggedMiddleware returns a different Middleware implementation depending on the configured feature flag value or Mode
 // Use this constructor when first adding authorisation as middleware so that it can be toggled off if required.
-func NewFeatureFlaggedMiddleware(ctx context.Context, config *Config, jwtRSAPublicKeys map[string]string) (Middleware, error) {
-	if config.Enabled {
-		return NewMiddlewareFromConfig(ctx, config, jwtRSAPublicKeys)
+func NewFeatureFlaggedMiddleware(ctx context.Context, config *Config, jwtRSAPublicKeys map[string]string, opts ...Option) (Middleware, error) {
+	mode, err := config.EffectiveMode()
+	if err != nil {
+		return nil, err
+	}
+	if mode == ModeDisabled && len(config.PermissionModes) == 0 {
+		return NewNoopMiddleware(), nil
 	}
-	return NewNoopMiddleware(), nil
+	return NewMiddlewareFromConfig(ctx, config, jwtRSAPublicKeys, append([]Option{WithMode(mode)}, opts...)...)
 }
 
 // NewMiddlewareFromDependencies creates a new instance of PermissionCheckMiddleware, using injected dependencies
-func NewMiddlewareFromDependencies(jwtParser JWTParser, permissionsChecker PermissionsChecker, zebedeeClient ZebedeeClient, identityClient *identityclient.IdentityClient) *PermissionCheckMiddleware {
-	return &PermissionCheckMiddleware{
+func NewMiddlewareFromDependencies(jwtParser JWTParser, permissionsChecker PermissionsChecker, zebedeeClient ZebedeeClient, identityClient *identityclient.IdentityClient, opts ...Option) *PermissionCheckMiddleware {
+	m := &PermissionCheckMiddleware{
 		jwtParser:          jwtParser,
 		permissionsChecker: permissionsChecker,
 		zebedeeClient:      zebedeeClient,
 		IdentityClient:     identityClient,
+		errorResponder:     NewProblemResponder(),
+		tokenExtractor:     NewAuthorizationHeaderExtractor(),
+		mode:               ModeEnforce,
+		routeFunc:          DefaultRoute,
+		tokenUsage:         newTokenUsageTracker(),
 	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if m.tokenVerifiers == nil {
+		m.tokenVerifiers = defaultTokenVerifiers(m)
+	}
+	return m
 }
 
 // NewMiddlewareFromConfig creates a new instance of PermissionCheckMiddleware, instantiating the required dependencies from
@@ -60,24 +107,62 @@ func NewMiddlewareFromDependencies(jwtParser JWTParser, permissionsChecker Permi
 //
 // This constructor uses default dependencies - the Cognito specific JWT parser, caching permissions checker and JWT RSA public signing keys (optional)
 // If different dependencies are required, use the NewMiddlewareFromDependencies constructor.
-func NewMiddlewareFromConfig(ctx context.Context, config *Config, jwtRSAPublicKeys map[string]string) (*PermissionCheckMiddleware, error) {
+func NewMiddlewareFromConfig(ctx context.Context, config *Config, jwtRSAPublicKeys map[string]string, opts ...Option) (middleware *PermissionCheckMiddleware, err error) {
+	// options given explicitly take precedence over those derived from config
+	configOpts, err := configOptions(config)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(configOpts, opts...)
+	resolved := resolveOptions(opts)
+
+	// the audit sink is created before any dependency that starts a go routine, so that nothing is left running if
+	// the audit file cannot be opened. It is closed along with the middleware, or here if the middleware is not created.
+	auditSink, err := newAuditSinkFromConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	if auditSink != nil {
+		opts = append([]Option{WithAuditSink(auditSink)}, opts...)
+		defer func() {
+			if middleware == nil {
+				if closeErr := audit.Close(auditSink); closeErr != nil {
+					log.Error(ctx, "failed to close audit sink", closeErr)
+				}
+			}
+		}()
+	}
+
 	// identity client retrieves jwt keys from identity service
-	identityClient, err := identityclient.NewIdentityClient(config.IdentityWebKeySetURL, config.IdentityClientMaxRetries)
+	identityClient, err := newIdentityClientFromConfig(config)
 	if err != nil {
 		return nil, err
 	}
+	identityClient.Metrics = resolved.metrics
+	identityClient.TracerProvider = resolved.tracerProvider
 
 	// get the JWT verification keys - from identity service initially
 	if jwtRSAPublicKeys != nil {
+		// keys given explicitly are not replaced by those from the identity service
 		identityClient.JWTKeys = jwtRSAPublicKeys
-	} else {
-		err = identityClient.GetJWTVerificationKeys(ctx)
-		if err != nil {
-			return nil, err
+		identityClient.KeyRefreshMinInterval = 0
+	} else if fetchErr := identityClient.GetJWTVerificationKeys(ctx); fetchErr != nil {
+		// the keys saved when they were last fetched are used if there is a recent enough snapshot, and the key
+		// refresher fetches the keys once the identity service is available. Without either, there would be no keys.
+		log.Error(ctx, "failed to get jwt verification keys", fetchErr)
+		var snapshotLoaded bool
+		if config.JWTKeySnapshotPath != "" {
+			if err = identityClient.LoadKeySnapshot(ctx); err != nil {
+				log.Error(ctx, "failed to load jwt verification keys snapshot", err, log.Data{"path": config.JWTKeySnapshotPath})
+			}
+			snapshotLoaded = err == nil
+		}
+		if !snapshotLoaded && config.JWTKeyRefreshInterval <= 0 {
+			return nil, fetchErr
 		}
 	}
 
-	jwtParser, err := NewCognitoRSAParser(identityClient.JWTKeys)
+	jwtParser, err := identityClient.NewParser()
 	if err != nil {
 		return nil, err
 	}
@@ -88,11 +173,18 @@ func NewMiddlewareFromConfig(ctx context.Context, config *Config, jwtRSAPublicKe
 		config.PermissionsAPIURL,
 		config.PermissionsCacheUpdateInterval,
 		config.PermissionsMaxCacheTime,
+		permissions.WithMetrics(resolved.metrics),
+		permissions.WithTracerProvider(resolved.tracerProvider),
 	)
 
-	zebedeeClient := zebedeeclient.NewZebedeeClient(config.ZebedeeURL)
+	zebedeeClient := newZebedeeClientFromConfig(config, resolved)
 
-	return NewMiddlewareFromDependencies(jwtParser, permissionsChecker, zebedeeClient, identityClient), nil
+	// the key refresher is stopped along with the middleware
+	if jwtRSAPublicKeys == nil && config.JWTKeyRefreshInterval > 0 {
+		identityClient.StartKeyRefresher(ctx, config.JWTKeyRefreshInterval, identityclient.WithMaxKeyAge(config.JWTKeyMaxAge))
+	}
+
+	return NewMiddlewareFromDependencies(jwtParser, permissionsChecker, zebedeeClient, identityClient, opts...), nil
 }
 
 // NewCognitoRSAParser returns a CognitoRSAParser with correct RSA Public Signing Keys set
@@ -102,79 +194,275 @@ func NewCognitoRSAParser(identityClientKeys map[string]string) (*jwt.CognitoRSAP
 
 // RequireWithAttributes wraps an existing handler, only allowing it to be called if the request is
 // authorised against the given permission. Includes any attributes returned by getAttributes in the permission check.
+// The authenticated Entity is stored in the request context passed to the handler, see EntityFromContext.
 func (m PermissionCheckMiddleware) RequireWithAttributes(permission string, handlerFunc http.HandlerFunc, getAttributes GetAttributesFromRequest) http.HandlerFunc {
-	return func(w http.ResponseWriter, req *http.Request) {
-		ctx := req.Context()
-		logData := log.Data{
-			"url":        req.URL.String(),
-			"permission": permission,
-		}
+	check := func(ctx context.Context, entityData permsdk.EntityData, attributes map[string]string) (bool, error) {
+		return m.permissionsChecker.HasPermission(ctx, entityData, permission, attributes)
+	}
+	return m.require([]string{permission}, log.Data{"permission": permission}, check, handlerFunc, getAttributes)
+}
 
-		authToken := req.Header.Get("Authorization")
-		if authToken == "" {
-			log.Info(ctx, "authorisation failed: no authorisation header in request", logData)
-			w.WriteHeader(http.StatusUnauthorized)
-			return
+// RequireAnyWithAttributes wraps an existing handler, only allowing it to be called if the request is
+// authorised against at least one of the given permissions. Includes any attributes returned by getAttributes in the permission check.
+func (m PermissionCheckMiddleware) RequireAnyWithAttributes(permissions []string, handlerFunc http.HandlerFunc, getAttributes GetAttributesFromRequest) http.HandlerFunc {
+	check := func(ctx context.Context, entityData permsdk.EntityData, attributes map[string]string) (bool, error) {
+		return m.permissionsChecker.HasAnyPermission(ctx, entityData, permissions, attributes)
+	}
+	return m.require(permissions, log.Data{"any_permissions": permissions}, check, handlerFunc, getAttributes)
+}
+
+// RequireAllWithAttributes wraps an existing handler, only allowing it to be called if the request is
+// authorised against all of the given permissions. Includes any attributes returned by getAttributes in the permission check.
+func (m PermissionCheckMiddleware) RequireAllWithAttributes(permissions []string, handlerFunc http.HandlerFunc, getAttributes GetAttributesFromRequest) http.HandlerFunc {
+	check := func(ctx context.Context, entityData permsdk.EntityData, attributes map[string]string) (bool, error) {
+		return m.permissionsChecker.HasAllPermissions(ctx, entityData, permissions, attributes)
+	}
+	return m.require(permissions, log.Data{"all_permissions": permissions}, check, handlerFunc, getAttributes)
+}
+
+// RequireAny wraps an existing handler, only allowing it to be called if the request is authorised against
+// at least one of the given permissions. Calls method RequireAnyWithAttributes() with the collection ID attribute.
+func (m PermissionCheckMiddleware) RequireAny(permissions []string, handlerFunc http.HandlerFunc) http.HandlerFunc {
+	return m.RequireAnyWithAttributes(permissions, handlerFunc, GetCollectionIDAttribute)
+}
+
+// RequireAll wraps an existing handler, only allowing it to be called if the request is authorised against
+// all of the given permissions. Calls method RequireAllWithAttributes() with the collection ID attribute.
+func (m PermissionCheckMiddleware) RequireAll(permissions []string, handlerFunc http.HandlerFunc) http.HandlerFunc {
+	return m.RequireAllWithAttributes(permissions, handlerFunc, GetCollectionIDAttribute)
+}
+
+// permissionCheck determines whether the given entity data is authorised, typically by calling the permissions checker
+type permissionCheck func(ctx context.Context, entityData permsdk.EntityData, attributes map[string]string) (bool, error)
+
+// require authenticates the request and wraps the handler with the given permission check. The permission
+// details in logData are included in every log event for the request.
+func (m PermissionCheckMiddleware) require(permissions []string, permissionLogData log.Data, check permissionCheck, handlerFunc http.HandlerFunc, getAttributes GetAttributesFromRequest) http.HandlerFunc {
+	mode := m.modeFor(permissions)
+	if mode == ModeDisabled {
+		return func(w http.ResponseWriter, req *http.Request) {
+			handlerFunc(w, withNoopEntity(req))
 		}
+	}
 
-		authToken = strings.TrimPrefix(authToken, "Bearer ")
-
-		// process the token accordingly
-		var (
-			entityData              = &permsdk.EntityData{}
-			zebedeeIdentityResponse = &request.IdentityResponse{}
-			err                     error
-		)
-		if strings.Contains(authToken, ".") {
-			entityData, err = m.jwtParser.Parse(authToken)
-			if err != nil {
-				if errors.Is(err, jwt.ErrPublickeysEmpty) {
-					log.Error(ctx, "no public keys", err)
-					w.WriteHeader(http.StatusInternalServerError)
-				} else {
-					logData["message"] = err.Error()
-					log.Error(ctx, "authorisation failed: unable to parse jwt", err, logData)
-					w.WriteHeader(http.StatusUnauthorized)
-				}
+	return func(w http.ResponseWriter, req *http.Request) {
+		entity, authErr := m.authorise(req, mode, permissions, permissionLogData, check, getAttributes)
+		if authErr != nil {
+			if mode != ModeShadow {
+				m.respondError(w, req, authErr)
 				return
 			}
-		} else {
-			zebedeeIdentityResponse, err = m.zebedeeClient.CheckTokenIdentity(ctx, authToken)
-			if err != nil {
-				logData["message"] = err.Error()
-				log.Error(ctx, "authorisation failed: service token issue", err, logData)
-				w.WriteHeader(http.StatusForbidden)
-				return
+
+			log.Warn(req.Context(), "authorisation shadow mode: request would have been rejected", log.Data{
+				"url":         req.URL.String(),
+				"permissions": permissions,
+				"code":        authErr.Code,
+				"status":      authErr.Status,
+			})
+			if entity == nil {
+				entity = &Entity{TokenType: TokenTypeNone}
 			}
-			// extract user name and proceed
-			entityData.UserID = zebedeeIdentityResponse.Identifier
 		}
 
-		var attributes map[string]string
-		if getAttributes != nil {
-			attributes, err = getAttributes(req)
-			if err != nil {
-				log.Error(ctx, "authorisation failed: request attributes retrieval error", err, logData)
-				w.WriteHeader(http.StatusInternalServerError)
-				return
-			}
+		handlerFunc(w, req.WithContext(NewContextWithEntity(req.Context(), entity)))
+	}
+}
+
+// authorise authenticates the request and checks its permissions, recording the decision.
+// The returned error describes why the request was rejected. The entity is returned along with
+// the error if the token was verified, so that it can be used in shadow mode.
+func (m PermissionCheckMiddleware) authorise(req *http.Request, mode Mode, permissions []string, permissionLogData log.Data, check permissionCheck, getAttributes GetAttributesFromRequest) (*Entity, *Error) {
+	tracer := tracing.Tracer(m.tracerProvider)
+	ctx, span := tracer.Start(req.Context(), "authorisation.Require",
+		trace.WithAttributes(
+			attribute.StringSlice(attributePermissions, permissions),
+			attribute.String(attributeMode, string(mode)),
+		))
+	defer span.End()
+
+	logData := log.Data{
+		"url": req.URL.String(),
+	}
+	for key, value := range permissionLogData {
+		logData[key] = value
+	}
+	decision := &audit.Decision{
+		RequestID:   request.GetRequestId(ctx),
+		Permissions: permissions,
+		Mode:        string(mode),
+	}
+
+	tokenExtractor := m.tokenExtractor
+	if tokenExtractor == nil {
+		tokenExtractor = NewAuthorizationHeaderExtractor()
+	}
+	token, ok := tokenExtractor.ExtractToken(req)
+	if !ok {
+		log.Info(ctx, "authorisation failed: no access token in request", logData)
+		return nil, m.reject(ctx, decision, NewError(ErrorCodeMissingToken, nil))
+	}
+	logData["token_source"] = token.Source
+
+	entity, authErr := m.verifyToken(ctx, token)
+	if authErr != nil {
+		logData["message"] = authErr.Error()
+		log.Error(ctx, "authorisation failed: unable to verify token", authErr, logData)
+		return nil, m.reject(ctx, decision, authErr)
+	}
+	logData["token_type"] = entity.TokenType
+	span.SetAttributes(attribute.String(attributeTokenType, string(entity.TokenType)))
+	decision.UserID = entity.UserID()
+	decision.Groups = entity.Groups()
+	decision.TokenType = string(entity.TokenType)
+
+	m.recordTokenUsage(req, entity, permissions)
+	if isZebedeeToken(entity.TokenType) {
+		logData["caller"] = entity.UserID()
+		switch m.zebedeeTokenActionFor(permissions) {
+		case ZebedeeTokenWarn:
+			log.Warn(ctx, "authorisation: request authenticated using a deprecated zebedee token", logData)
+		case ZebedeeTokenReject:
+			log.Info(ctx, "authorisation failed: zebedee tokens are not accepted for the permission", logData)
+			return entity, m.reject(ctx, decision, NewError(ErrorCodeTokenDeprecated, nil))
 		}
+	}
 
-		hasPermission, err := m.permissionsChecker.HasPermission(req.Context(), *entityData, permission, attributes)
+	var attributes map[string]string
+	if getAttributes != nil {
+		_, attributesSpan := tracer.Start(ctx, "authorisation.GetAttributes")
+		var err error
+		attributes, err = getAttributes(req)
+		tracing.EndSpan(attributesSpan, err)
 		if err != nil {
-			log.Error(ctx, "authorisation failed: permissions lookup error", err, logData)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+			log.Error(ctx, "authorisation failed: request attributes retrieval error", err, logData)
+			return entity, m.reject(ctx, decision, NewError(ErrorCodeAttributesUnavailable, err))
 		}
+	}
+	decision.Attributes = attributes
+
+	// the permissions checker adds the matched policy and reason to the decision held in the context, which are used
+	// by the metrics whether or not the decision is audited
+	checkCtx, checkSpan := tracer.Start(ctx, "authorisation.HasPermission")
+	checkCtx = audit.NewContextWithDecision(checkCtx, decision)
+	hasPermission, err := check(entity.newCheckContext(checkCtx), entity.EntityData, attributes)
+	tracing.EndSpan(checkSpan, err)
+	if err != nil {
+		log.Error(ctx, "authorisation failed: permissions lookup error", err, logData)
+		return entity, m.reject(ctx, decision, newPermissionsError(err))
+	}
+
+	if !hasPermission {
+		log.Info(ctx, "authorisation failed: request has no permission", logData)
+		if decision.Reason == "" {
+			decision.Reason = string(ErrorCodePermissionDenied)
+		}
+		return entity, m.reject(ctx, decision, NewError(ErrorCodePermissionDenied, nil))
+	}
+
+	if decision.Reason == "" {
+		decision.Reason = audit.ReasonPermissionGranted
+	}
+	m.recordDecision(ctx, decision, audit.OutcomeAllow)
+	return entity, nil
+}
+
+// recordTokenUsage counts the authenticated request in the token usage report and metrics
+func (m PermissionCheckMiddleware) recordTokenUsage(req *http.Request, entity *Entity, permissions []string) {
+	var route string
+	if m.tokenUsage != nil {
+		routeFunc := m.routeFunc
+		if routeFunc == nil {
+			routeFunc = DefaultRoute
+		}
+		route = m.tokenUsage.record(entity, strings.Join(permissions, ","), routeFunc(req))
+	}
+	m.metrics.RecordTokenUsage(string(entity.TokenType), usageCaller(entity), permissions, route)
+}
+
+// TokenUsageReport returns the number of authenticated requests made by each caller, for each type of token,
+// permission and route, since the middleware was created. Use this to find the callers still using Zebedee tokens.
+func (m PermissionCheckMiddleware) TokenUsageReport() []TokenUsage {
+	if m.tokenUsage == nil {
+		return nil
+	}
+	return m.tokenUsage.report()
+}
 
-		if !hasPermission {
-			log.Info(ctx, "authorisation failed: request has no permission", logData)
-			w.WriteHeader(http.StatusForbidden)
+// verifyToken verifies the token using the first registered TokenVerifier that can handle it,
+// returning the entity it identifies
+func (m PermissionCheckMiddleware) verifyToken(ctx context.Context, token Token) (entity *Entity, authErr *Error) {
+	ctx, span := tracing.Tracer(m.tracerProvider).Start(ctx, "authorisation.VerifyToken",
+		trace.WithAttributes(attribute.String(attributeTokenSource, token.Source)))
+	defer func() {
+		if authErr != nil {
+			tracing.EndSpan(span, authErr)
 			return
 		}
+		span.SetAttributes(attribute.String(attributeTokenType, string(entity.TokenType)))
+		span.End()
+	}()
 
-		handlerFunc(w, req)
+	for _, verifier := range m.tokenVerifiers {
+		if !verifier.CanHandle(token) {
+			continue
+		}
+
+		entity, err := verifier.Verify(ctx, token)
+		if err != nil {
+			var authErr *Error
+			if errors.As(err, &authErr) {
+				return nil, authErr
+			}
+			return nil, NewError(ErrorCodeInvalidToken, err)
+		}
+		if entity == nil {
+			return nil, NewError(ErrorCodeInvalidToken, errors.New("token verifier returned no entity"))
+		}
+		return entity, nil
 	}
+
+	return nil, NewError(ErrorCodeUnsupportedToken, nil)
+}
+
+// reject records the decision to reject the request, returning the error describing why.
+// The error code is used as the reason for the decision unless a more specific reason has been recorded.
+func (m PermissionCheckMiddleware) reject(ctx context.Context, decision *audit.Decision, authErr *Error) *Error {
+	if decision.Reason == "" {
+		decision.Reason = string(authErr.Code)
+	}
+	if authErr.Status >= http.StatusInternalServerError {
+		trace.SpanFromContext(ctx).SetStatus(codes.Error, authErr.Error())
+	}
+	m.recordDecision(ctx, decision, audit.OutcomeDeny)
+	return authErr
+}
+
+// recordDecision counts the decision, adds it to the current span and records it to the audit sink, if one is configured
+func (m PermissionCheckMiddleware) recordDecision(ctx context.Context, decision *audit.Decision, outcome audit.Outcome) {
+	m.metrics.RecordDecision(decision.Permissions, decision.Mode, string(outcome), decision.Reason)
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String(attributeOutcome, string(outcome)),
+		attribute.String(attributeReason, decision.Reason),
+	)
+	if m.auditSink == nil {
+		return
+	}
+	decision.Timestamp = time.Now().UTC()
+	decision.Outcome = outcome
+	m.auditSink.Record(ctx, *decision)
+}
+
+// respondError writes the response for a rejected request using the configured ErrorResponder,
+// including a WWW-Authenticate challenge where appropriate
+func (m PermissionCheckMiddleware) respondError(w http.ResponseWriter, req *http.Request, authErr *Error) {
+	if challenge := m.challenge.header(authErr); challenge != "" {
+		w.Header().Set(WWWAuthenticateHeader, challenge)
+	}
+	if m.errorResponder == nil {
+		NewProblemResponder().RespondError(w, req, authErr)
+		return
+	}
+	m.errorResponder.RespondError(w, req, authErr)
 }
 
 // Require wraps an existing handler, only allowing it to be called if the request is
@@ -183,9 +471,17 @@ func (m PermissionCheckMiddleware) Require(permission string, handlerFunc http.H
 	return m.RequireWithAttributes(permission, handlerFunc, GetCollectionIDAttribute)
 }
 
-// Close resources used by the middleware.
+// Close resources used by the middleware, including the audit sink if it can be closed and the identity client's
+// key refresher.
 func (m PermissionCheckMiddleware) Close(ctx context.Context) error {
-	return m.permissionsChecker.Close(ctx)
+	err := m.permissionsChecker.Close(ctx)
+	if m.IdentityClient != nil {
+		err = errors.Join(err, m.IdentityClient.Close(ctx))
+	}
+	if m.auditSink != nil {
+		err = errors.Join(err, audit.Close(m.auditSink))
+	}
+	return err
 }
 
 // HealthCheck updates the health status of the permissions checker
@@ -5,27 +5,36 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/ONSdigital/dp-authorisation/v2/audit"
 	"github.com/ONSdigital/dp-authorisation/v2/authorisation"
 	"github.com/ONSdigital/dp-authorisation/v2/authorisation/mock"
 	"github.com/ONSdigital/dp-authorisation/v2/authorisationtest"
 	"github.com/ONSdigital/dp-authorisation/v2/identityclient"
 	identityClientMock "github.com/ONSdigital/dp-authorisation/v2/identityclient/mock"
 	"github.com/ONSdigital/dp-authorisation/v2/jwt"
+	"github.com/ONSdigital/dp-authorisation/v2/metrics"
+	"github.com/ONSdigital/dp-authorisation/v2/permissions"
+	permissionsMock "github.com/ONSdigital/dp-authorisation/v2/permissions/mock"
 	dprequest "github.com/ONSdigital/dp-net/v3/request"
 	permsdk "github.com/ONSdigital/dp-permissions-api/sdk"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	. "github.com/smartystreets/goconvey/convey"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 var (
 	dummyEntityData             = &permsdk.EntityData{UserID: "fred"}
 	dummyAttributesData         = &map[string]string{"collection_id": "some-collection_id-uuid"}
 	permission                  = "dataset.read"
-	dummyServiveTokenEntityData = &permsdk.EntityData{UserID: "bilbo.baggins@bilbo-baggins.io"}
+	dummyServiveTokenEntityData = &permsdk.EntityData{UserID: "services/bilbo.baggins@bilbo-baggins.io"}
 	zebedeeIdentity             = &mock.ZebedeeClientMock{
 		CheckTokenIdentityFunc: func(ctx context.Context, token string) (*dprequest.IdentityResponse, error) {
 			return &dprequest.IdentityResponse{
@@ -45,11 +54,13 @@ var (
 )
 
 type mockHandler struct {
-	calls int
+	calls   int
+	request *http.Request
 }
 
-func (m *mockHandler) ServeHTTP(_ http.ResponseWriter, _ *http.Request) {
+func (m *mockHandler) ServeHTTP(_ http.ResponseWriter, req *http.Request) {
 	m.calls++
+	m.request = req
 }
 
 type mockAttributes struct {
@@ -75,6 +86,32 @@ var identityClient = &identityclient.IdentityClient{
 
 var NewCognitoRSAParserTest, _ = jwt.NewCognitoRSAParser(testJWTPublicKeyAPIMapx1)
 
+func TestNewMiddlewareFromConfig_AuditFileError(t *testing.T) {
+	Convey("Given a config with an audit file that cannot be opened", t, func() {
+		var requests int
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		config := authorisation.NewDefaultConfig()
+		config.IdentityWebKeySetURL = server.URL
+		config.PermissionsAPIURL = server.URL
+		config.AuditFilePath = filepath.Join(t.TempDir(), "missing", "audit.log")
+
+		Convey("When the middleware is created", func() {
+			middleware, err := authorisation.NewMiddlewareFromConfig(context.Background(), config, nil)
+
+			Convey("Then an error is returned before any dependency is started", func() {
+				So(err, ShouldNotBeNil)
+				So(middleware, ShouldBeNil)
+				So(requests, ShouldEqual, 0)
+			})
+		})
+	})
+}
+
 func TestMiddleware_RequireWithAttributes(t *testing.T) {
 	Convey("Given a request with a valid JWT token and collection_id header that have the required permissions", t, func() {
 		response := httptest.NewRecorder()
@@ -116,6 +153,14 @@ func TestMiddleware_RequireWithAttributes(t *testing.T) {
 				So(mockHandler.calls, ShouldEqual, 1)
 			})
 
+			Convey("Then the authenticated entity is available from the request context", func() {
+				entity, ok := authorisation.EntityFromContext(mockHandler.request.Context())
+				So(ok, ShouldBeTrue)
+				So(entity.EntityData, ShouldResemble, *dummyEntityData)
+				So(entity.TokenType, ShouldEqual, authorisation.TokenTypeJWT)
+				So(entity.Claims, ShouldBeNil)
+			})
+
 			Convey("Then the response code should be 200", func() {
 				So(response.Code, ShouldEqual, http.StatusOK)
 			})
@@ -311,6 +356,84 @@ func TestMiddleware_Require_PermissionDenied(t *testing.T) {
 	})
 }
 
+func TestMiddleware_RequireAny(t *testing.T) {
+	Convey("Given a request with a valid JWT token that has one of the required permissions", t, func() {
+		response := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, testURL, http.NoBody)
+		request.Header.Set("Authorization", authorisationtest.AdminJWTToken)
+		mockHandler := &mockHandler{calls: 0}
+		mockJWTParser := newMockJWTParser()
+		permissionsList := []string{"datasets:edit", "datasets:publish"}
+
+		permissionsChecker := &mock.PermissionsCheckerMock{
+			HasAnyPermissionFunc: func(ctx context.Context, entityData permsdk.EntityData, permissions []string, attributes map[string]string) (bool, error) {
+				return true, nil
+			},
+		}
+
+		middleware := authorisation.NewMiddlewareFromDependencies(mockJWTParser, permissionsChecker, zebedeeIdentity, identityClient)
+		middlewareFunc := middleware.RequireAny(permissionsList, mockHandler.ServeHTTP)
+
+		Convey("When the middleware function is called", func() {
+			middlewareFunc(response, request)
+
+			Convey("Then the permissions checker is called as expected", func() {
+				So(permissionsChecker.HasAnyPermissionCalls(), ShouldHaveLength, 1)
+				So(permissionsChecker.HasAnyPermissionCalls()[0].Permissions, ShouldResemble, permissionsList)
+				So(permissionsChecker.HasAnyPermissionCalls()[0].EntityData, ShouldResemble, *dummyEntityData)
+				So(permissionsChecker.HasPermissionCalls(), ShouldHaveLength, 0)
+			})
+
+			Convey("Then the underlying HTTP handler is called as expected", func() {
+				So(mockHandler.calls, ShouldEqual, 1)
+			})
+
+			Convey("Then the response code should be 200", func() {
+				So(response.Code, ShouldEqual, http.StatusOK)
+			})
+		})
+	})
+}
+
+func TestMiddleware_RequireAllWithAttributes_PermissionDenied(t *testing.T) {
+	Convey("Given a request with a valid JWT token that does not have all of the required permissions", t, func() {
+		response := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, testURL, http.NoBody)
+		request.Header.Set("Authorization", authorisationtest.AdminJWTToken)
+		mockHandler := &mockHandler{calls: 0}
+		mockJWTParser := newMockJWTParser()
+		mockAttributes := &mockAttributes{attributes: *dummyAttributesData, calls: 0}
+		permissionsList := []string{"collections:edit", "datasets:read"}
+
+		permissionsChecker := &mock.PermissionsCheckerMock{
+			HasAllPermissionsFunc: func(ctx context.Context, entityData permsdk.EntityData, permissions []string, attributes map[string]string) (bool, error) {
+				return false, nil
+			},
+		}
+
+		middleware := authorisation.NewMiddlewareFromDependencies(mockJWTParser, permissionsChecker, zebedeeIdentity, identityClient)
+		middlewareFunc := middleware.RequireAllWithAttributes(permissionsList, mockHandler.ServeHTTP, mockAttributes.GetAttributes)
+
+		Convey("When the middleware function is called", func() {
+			middlewareFunc(response, request)
+
+			Convey("Then the permissions checker is called as expected", func() {
+				So(permissionsChecker.HasAllPermissionsCalls(), ShouldHaveLength, 1)
+				So(permissionsChecker.HasAllPermissionsCalls()[0].Permissions, ShouldResemble, permissionsList)
+				So(permissionsChecker.HasAllPermissionsCalls()[0].Attributes, ShouldResemble, *dummyAttributesData)
+			})
+
+			Convey("Then the underlying HTTP handler is not called", func() {
+				So(mockHandler.calls, ShouldEqual, 0)
+			})
+
+			Convey("Then the response code should be 403 forbidden", func() {
+				So(response.Code, ShouldEqual, http.StatusForbidden)
+			})
+		})
+	})
+}
+
 func TestMiddleware_ServiceTokenUser_SuccessfullyAuthorised(t *testing.T) {
 	Convey("Given the permission check returns true", t, func() {
 		permissionsChecker := &mock.PermissionsCheckerMock{
@@ -336,10 +459,24 @@ func TestMiddleware_ServiceTokenUser_SuccessfullyAuthorised(t *testing.T) {
 				So(permissionsChecker.HasPermissionCalls()[0].EntityData, ShouldResemble, *dummyServiveTokenEntityData)
 			})
 
+			Convey("Then the permissions checker is told the caller is a service", func() {
+				serviceID, ok := permissions.ServiceFromContext(permissionsChecker.HasPermissionCalls()[0].Ctx)
+				So(ok, ShouldBeTrue)
+				So(serviceID, ShouldEqual, "bilbo.baggins@bilbo-baggins.io")
+			})
+
 			Convey("Then the underlying HTTP handler is called as expected", func() {
 				So(mockHandler.calls, ShouldEqual, 1)
 			})
 
+			Convey("Then the service entity is available from the request context", func() {
+				entity, ok := authorisation.EntityFromContext(mockHandler.request.Context())
+				So(ok, ShouldBeTrue)
+				So(entity.UserID(), ShouldEqual, dummyServiveTokenEntityData.UserID)
+				So(entity.TokenType, ShouldEqual, authorisation.TokenTypeZebedeeService)
+				So(entity.Claims, ShouldBeNil)
+			})
+
 			Convey("Then the response code should be 200", func() {
 				So(response.Code, ShouldEqual, http.StatusOK)
 			})
@@ -422,6 +559,193 @@ func TestMiddleware_ServiceTokenUser_ZebedeeIdentityVerificationError(t *testing
 		})
 	})
 }
+func TestMiddleware_WithAuditSink(t *testing.T) {
+	Convey("Given a middleware with an audit sink and a permissions checker", t, func() {
+		sink := audit.NewMemorySink(10)
+		permissionsCache := &permissionsMock.CacheMock{
+			GetPermissionsBundleFunc: func(ctx context.Context, headers permsdk.Headers) (permsdk.Bundle, error) {
+				return permsdk.Bundle{
+					permission: {
+						"users/fred": {{ID: "policy1"}},
+					},
+				}, nil
+			},
+		}
+		permissionsChecker := permissions.NewCheckerForStore(permissionsCache, permissions.WithAuditSink(sink))
+		middleware := authorisation.NewMiddlewareFromDependencies(newMockJWTParser(), permissionsChecker, zebedeeIdentity, identityClient,
+			authorisation.WithAuditSink(sink))
+
+		response := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, testURL, http.NoBody)
+		mockHandler := &mockHandler{calls: 0}
+
+		Convey("When an authorised request is made", func() {
+			request.Header.Set("Authorization", authorisationtest.AdminJWTToken)
+			middleware.Require(permission, mockHandler.ServeHTTP)(response, request.WithContext(dprequest.WithRequestId(request.Context(), "request-123")))
+
+			Convey("Then a single allow decision is recorded, including the policy matched by the checker", func() {
+				decisions := sink.Decisions()
+				So(decisions, ShouldHaveLength, 1)
+				So(decisions[0].Outcome, ShouldEqual, audit.OutcomeAllow)
+				So(decisions[0].Reason, ShouldEqual, audit.ReasonPolicyMatched)
+				So(decisions[0].PolicyIDs, ShouldResemble, []string{"policy1"})
+				So(decisions[0].UserID, ShouldEqual, "fred")
+				So(decisions[0].TokenType, ShouldEqual, string(authorisation.TokenTypeJWT))
+				So(decisions[0].Permissions, ShouldResemble, []string{permission})
+				So(decisions[0].RequestID, ShouldEqual, "request-123")
+				So(decisions[0].Timestamp.IsZero(), ShouldBeFalse)
+			})
+		})
+
+		Convey("When a request without a token is made", func() {
+			middleware.Require(permission, mockHandler.ServeHTTP)(response, request)
+
+			Convey("Then a deny decision is recorded with the error code as the reason", func() {
+				decisions := sink.Decisions()
+				So(decisions, ShouldHaveLength, 1)
+				So(decisions[0].Outcome, ShouldEqual, audit.OutcomeDeny)
+				So(decisions[0].Reason, ShouldEqual, string(authorisation.ErrorCodeMissingToken))
+				So(decisions[0].UserID, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When a request is made for a permission the caller does not have", func() {
+			request.Header.Set("Authorization", authorisationtest.AdminJWTToken)
+			middleware.Require("datasets:edit", mockHandler.ServeHTTP)(response, request)
+
+			Convey("Then a deny decision is recorded with the reason given by the checker", func() {
+				decisions := sink.Decisions()
+				So(decisions, ShouldHaveLength, 1)
+				So(decisions[0].Outcome, ShouldEqual, audit.OutcomeDeny)
+				So(decisions[0].Reason, ShouldEqual, audit.ReasonPermissionNotFound)
+			})
+		})
+	})
+}
+
+func TestMiddleware_WithMetrics(t *testing.T) {
+	Convey("Given a middleware with metrics", t, func() {
+		registry := prometheus.NewRegistry()
+		authMetrics, err := metrics.New(registry)
+		So(err, ShouldBeNil)
+
+		mockJWTParser := &mock.JWTParserMock{
+			ParseFunc: func(tokenString string) (*permsdk.EntityData, error) {
+				return nil, jwt.ErrTokenExpired
+			},
+		}
+		middleware := authorisation.NewMiddlewareFromDependencies(mockJWTParser, &mock.PermissionsCheckerMock{}, zebedeeIdentity, identityClient,
+			authorisation.WithMetrics(authMetrics))
+
+		response := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, testURL, http.NoBody)
+		request.Header.Set("Authorization", authorisationtest.AdminJWTToken)
+		mockHandler := &mockHandler{calls: 0}
+
+		Convey("When a request with an expired JWT is made", func() {
+			middleware.Require(permission, mockHandler.ServeHTTP)(response, request)
+
+			Convey("Then the decision and the JWT parse failure are counted", func() {
+				expected := `
+# HELP dp_authorisation_decisions_total Authorisation decisions made by the middleware, by permission, enforcement mode, outcome and reason.
+# TYPE dp_authorisation_decisions_total counter
+dp_authorisation_decisions_total{mode="enforce",outcome="deny",permission="dataset.read",reason="token_expired"} 1
+# HELP dp_authorisation_jwt_parse_failures_total JWT access tokens that failed verification, by reason.
+# TYPE dp_authorisation_jwt_parse_failures_total counter
+dp_authorisation_jwt_parse_failures_total{reason="token_expired"} 1
+`
+				So(testutil.GatherAndCompare(registry, strings.NewReader(expected),
+					"dp_authorisation_decisions_total", "dp_authorisation_jwt_parse_failures_total"), ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestMiddleware_WithMetrics_CheckerReason(t *testing.T) {
+	Convey("Given a middleware with metrics but without an audit sink", t, func() {
+		registry := prometheus.NewRegistry()
+		authMetrics, err := metrics.New(registry)
+		So(err, ShouldBeNil)
+		permissionsCache := &permissionsMock.CacheMock{
+			GetPermissionsBundleFunc: func(ctx context.Context, headers permsdk.Headers) (permsdk.Bundle, error) {
+				return permsdk.Bundle{}, nil
+			},
+		}
+		middleware := authorisation.NewMiddlewareFromDependencies(newMockJWTParser(), permissions.NewCheckerForStore(permissionsCache), zebedeeIdentity, identityClient,
+			authorisation.WithMetrics(authMetrics))
+
+		Convey("When a request is made for a permission the caller does not have", func() {
+			request := httptest.NewRequest(http.MethodGet, testURL, http.NoBody)
+			request.Header.Set("Authorization", authorisationtest.AdminJWTToken)
+			middleware.Require(permission, (&mockHandler{}).ServeHTTP)(httptest.NewRecorder(), request)
+
+			Convey("Then the decision is counted with the reason given by the checker", func() {
+				expected := `
+# HELP dp_authorisation_decisions_total Authorisation decisions made by the middleware, by permission, enforcement mode, outcome and reason.
+# TYPE dp_authorisation_decisions_total counter
+dp_authorisation_decisions_total{mode="enforce",outcome="deny",permission="dataset.read",reason="permission_not_found"} 1
+`
+				So(testutil.GatherAndCompare(registry, strings.NewReader(expected), "dp_authorisation_decisions_total"), ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestMiddleware_WithTracerProvider(t *testing.T) {
+	Convey("Given a middleware with a tracer provider", t, func() {
+		spanRecorder := tracetest.NewSpanRecorder()
+		tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder))
+		permissionsChecker := &mock.PermissionsCheckerMock{
+			HasPermissionFunc: func(ctx context.Context, entityData permsdk.EntityData, permission string, attributes map[string]string) (bool, error) {
+				return false, nil
+			},
+		}
+		middleware := authorisation.NewMiddlewareFromDependencies(newMockJWTParser(), permissionsChecker, zebedeeIdentity, identityClient,
+			authorisation.WithTracerProvider(tracerProvider))
+
+		response := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, testURL, http.NoBody)
+		request.Header.Set("Authorization", authorisationtest.AdminJWTToken)
+		mockHandler := &mockHandler{calls: 0}
+
+		Convey("When a request is denied", func() {
+			middleware.Require(permission, mockHandler.ServeHTTP)(response, request)
+
+			spans := map[string]sdktrace.ReadOnlySpan{}
+			for _, span := range spanRecorder.Ended() {
+				spans[span.Name()] = span
+			}
+
+			Convey("Then spans are created for each step of the authorisation", func() {
+				So(spans, ShouldContainKey, "authorisation.Require")
+				So(spans, ShouldContainKey, "authorisation.VerifyToken")
+				So(spans, ShouldContainKey, "authorisation.GetAttributes")
+				So(spans, ShouldContainKey, "authorisation.HasPermission")
+				So(spans["authorisation.VerifyToken"].Parent().SpanID(), ShouldEqual, spans["authorisation.Require"].SpanContext().SpanID())
+			})
+
+			Convey("Then the authorisation span has the permission, token type and outcome attributes", func() {
+				attributes := map[string]string{}
+				for _, attr := range spans["authorisation.Require"].Attributes() {
+					attributes[string(attr.Key)] = attr.Value.Emit()
+				}
+				So(attributes["authorisation.permissions"], ShouldEqual, `["dataset.read"]`)
+				So(attributes["authorisation.token_type"], ShouldEqual, "jwt")
+				So(attributes["authorisation.outcome"], ShouldEqual, "deny")
+				So(attributes["authorisation.reason"], ShouldEqual, "permission_denied")
+			})
+
+			Convey("Then the token is not added to any span", func() {
+				for _, span := range spanRecorder.Ended() {
+					for _, attr := range span.Attributes() {
+						So(attr.Value.Emit(), ShouldNotContainSubstring, trimmedToken)
+					}
+				}
+			})
+		})
+	})
+}
+
 func TestGetCollectionIdAttribute(t *testing.T) {
 	Convey("Given a request with a Collection-Id header", t, func() {
 		request := httptest.NewRequest(http.MethodGet, testURL, http.NoBody)
@@ -0,0 +1,121 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mock
+
+import (
+	"github.com/ONSdigital/dp-authorisation/v2/authorisation"
+	"github.com/ONSdigital/dp-authorisation/v2/jwt"
+	permsdk "github.com/ONSdigital/dp-permissions-api/sdk"
+	"sync"
+)
+
+// Ensure, that JWTClaimsParserMock does implement authorisation.JWTClaimsParser.
+// If this is not the case, regenerate this file with moq.
+var _ authorisation.JWTClaimsParser = &JWTClaimsParserMock{}
+
+// JWTClaimsParserMock is a mock implementation of authorisation.JWTClaimsParser.
+//
+//	func TestSomethingThatUsesJWTClaimsParser(t *testing.T) {
+//
+//		// make and configure a mocked authorisation.JWTClaimsParser
+//		mockedJWTClaimsParser := &JWTClaimsParserMock{
+//			ParseFunc: func(tokenString string) (*permsdk.EntityData, error) {
+//				panic("mock out the Parse method")
+//			},
+//			ParseWithClaimsFunc: func(tokenString string) (*jwt.ParseResult, error) {
+//				panic("mock out the ParseWithClaims method")
+//			},
+//		}
+//
+//		// use mockedJWTClaimsParser in code that requires authorisation.JWTClaimsParser
+//		// and then make assertions.
+//
+//	}
+type JWTClaimsParserMock struct {
+	// ParseFunc mocks the Parse method.
+	ParseFunc func(tokenString string) (*permsdk.EntityData, error)
+
+	// ParseWithClaimsFunc mocks the ParseWithClaims method.
+	ParseWithClaimsFunc func(tokenString string) (*jwt.ParseResult, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// Parse holds details about calls to the Parse method.
+		Parse []struct {
+			// TokenString is the tokenString argument value.
+			TokenString string
+		}
+		// ParseWithClaims holds details about calls to the ParseWithClaims method.
+		ParseWithClaims []struct {
+			// TokenString is the tokenString argument value.
+			TokenString string
+		}
+	}
+	lockParse           sync.RWMutex
+	lockParseWithClaims sync.RWMutex
+}
+
+// Parse calls ParseFunc.
+func (mock *JWTClaimsParserMock) Parse(tokenString string) (*permsdk.EntityData, error) {
+	if mock.ParseFunc == nil {
+		panic("JWTClaimsParserMock.ParseFunc: method is nil but JWTClaimsParser.Parse was just called")
+	}
+	callInfo := struct {
+		TokenString string
+	}{
+		TokenString: tokenString,
+	}
+	mock.lockParse.Lock()
+	mock.calls.Parse = append(mock.calls.Parse, callInfo)
+	mock.lockParse.Unlock()
+	return mock.ParseFunc(tokenString)
+}
+
+// ParseCalls gets all the calls that were made to Parse.
+// Check the length with:
+//
+//	len(mockedJWTClaimsParser.ParseCalls())
+func (mock *JWTClaimsParserMock) ParseCalls() []struct {
+	TokenString string
+} {
+	var calls []struct {
+		TokenString string
+	}
+	mock.lockParse.RLock()
+	calls = mock.calls.Parse
+	mock.lockParse.RUnlock()
+	return calls
+}
+
+// ParseWithClaims calls ParseWithClaimsFunc.
+func (mock *JWTClaimsParserMock) ParseWithClaims(tokenString string) (*jwt.ParseResult, error) {
+	if mock.ParseWithClaimsFunc == nil {
+		panic("JWTClaimsParserMock.ParseWithClaimsFunc: method is nil but JWTClaimsParser.ParseWithClaims was just called")
+	}
+	callInfo := struct {
+		TokenString string
+	}{
+		TokenString: tokenString,
+	}
+	mock.lockParseWithClaims.Lock()
+	mock.calls.ParseWithClaims = append(mock.calls.ParseWithClaims, callInfo)
+	mock.lockParseWithClaims.Unlock()
+	return mock.ParseWithClaimsFunc(tokenString)
+}
+
+// ParseWithClaimsCalls gets all the calls that were made to ParseWithClaims.
+// Check the length with:
+//
+//	len(mockedJWTClaimsParser.ParseWithClaimsCalls())
+func (mock *JWTClaimsParserMock) ParseWithClaimsCalls() []struct {
+	TokenString string
+} {
+	var calls []struct {
+		TokenString string
+	}
+	mock.lockParseWithClaims.RLock()
+	calls = mock.calls.ParseWithClaims
+	mock.lockParseWithClaims.RUnlock()
+	return calls
+}
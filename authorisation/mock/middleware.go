@@ -37,9 +37,24 @@ var _ authorisation.Middleware = &MiddlewareMock{}
 //			RequireFunc: func(permission string, handlerFunc http.HandlerFunc) http.HandlerFunc {
 //				panic("mock out the Require method")
 //			},
+//			RequireAllFunc: func(permissions []string, handlerFunc http.HandlerFunc) http.HandlerFunc {
+//				panic("mock out the RequireAll method")
+//			},
+//			RequireAllWithAttributesFunc: func(permissions []string, handlerFunc http.HandlerFunc, getAttributes authorisation.GetAttributesFromRequest) http.HandlerFunc {
+//				panic("mock out the RequireAllWithAttributes method")
+//			},
+//			RequireAnyFunc: func(permissions []string, handlerFunc http.HandlerFunc) http.HandlerFunc {
+//				panic("mock out the RequireAny method")
+//			},
+//			RequireAnyWithAttributesFunc: func(permissions []string, handlerFunc http.HandlerFunc, getAttributes authorisation.GetAttributesFromRequest) http.HandlerFunc {
+//				panic("mock out the RequireAnyWithAttributes method")
+//			},
 //			RequireWithAttributesFunc: func(permission string, handlerFunc http.HandlerFunc, getAttributes authorisation.GetAttributesFromRequest) http.HandlerFunc {
 //				panic("mock out the RequireWithAttributes method")
 //			},
+//			TokenUsageReportFunc: func() []authorisation.TokenUsage {
+//				panic("mock out the TokenUsageReport method")
+//			},
 //		}
 //
 //		// use mockedMiddleware in code that requires authorisation.Middleware
@@ -62,9 +77,24 @@ type MiddlewareMock struct {
 	// RequireFunc mocks the Require method.
 	RequireFunc func(permission string, handlerFunc http.HandlerFunc) http.HandlerFunc
 
+	// RequireAllFunc mocks the RequireAll method.
+	RequireAllFunc func(permissions []string, handlerFunc http.HandlerFunc) http.HandlerFunc
+
+	// RequireAllWithAttributesFunc mocks the RequireAllWithAttributes method.
+	RequireAllWithAttributesFunc func(permissions []string, handlerFunc http.HandlerFunc, getAttributes authorisation.GetAttributesFromRequest) http.HandlerFunc
+
+	// RequireAnyFunc mocks the RequireAny method.
+	RequireAnyFunc func(permissions []string, handlerFunc http.HandlerFunc) http.HandlerFunc
+
+	// RequireAnyWithAttributesFunc mocks the RequireAnyWithAttributes method.
+	RequireAnyWithAttributesFunc func(permissions []string, handlerFunc http.HandlerFunc, getAttributes authorisation.GetAttributesFromRequest) http.HandlerFunc
+
 	// RequireWithAttributesFunc mocks the RequireWithAttributes method.
 	RequireWithAttributesFunc func(permission string, handlerFunc http.HandlerFunc, getAttributes authorisation.GetAttributesFromRequest) http.HandlerFunc
 
+	// TokenUsageReportFunc mocks the TokenUsageReport method.
+	TokenUsageReportFunc func() []authorisation.TokenUsage
+
 	// calls tracks calls to the methods.
 	calls struct {
 		// Close holds details about calls to the Close method.
@@ -98,6 +128,38 @@ type MiddlewareMock struct {
 			// HandlerFunc is the handlerFunc argument value.
 			HandlerFunc http.HandlerFunc
 		}
+		// RequireAll holds details about calls to the RequireAll method.
+		RequireAll []struct {
+			// Permissions is the permissions argument value.
+			Permissions []string
+			// HandlerFunc is the handlerFunc argument value.
+			HandlerFunc http.HandlerFunc
+		}
+		// RequireAllWithAttributes holds details about calls to the RequireAllWithAttributes method.
+		RequireAllWithAttributes []struct {
+			// Permissions is the permissions argument value.
+			Permissions []string
+			// HandlerFunc is the handlerFunc argument value.
+			HandlerFunc http.HandlerFunc
+			// GetAttributes is the getAttributes argument value.
+			GetAttributes authorisation.GetAttributesFromRequest
+		}
+		// RequireAny holds details about calls to the RequireAny method.
+		RequireAny []struct {
+			// Permissions is the permissions argument value.
+			Permissions []string
+			// HandlerFunc is the handlerFunc argument value.
+			HandlerFunc http.HandlerFunc
+		}
+		// RequireAnyWithAttributes holds details about calls to the RequireAnyWithAttributes method.
+		RequireAnyWithAttributes []struct {
+			// Permissions is the permissions argument value.
+			Permissions []string
+			// HandlerFunc is the handlerFunc argument value.
+			HandlerFunc http.HandlerFunc
+			// GetAttributes is the getAttributes argument value.
+			GetAttributes authorisation.GetAttributesFromRequest
+		}
 		// RequireWithAttributes holds details about calls to the RequireWithAttributes method.
 		RequireWithAttributes []struct {
 			// Permission is the permission argument value.
@@ -107,13 +169,21 @@ type MiddlewareMock struct {
 			// GetAttributes is the getAttributes argument value.
 			GetAttributes authorisation.GetAttributesFromRequest
 		}
+		// TokenUsageReport holds details about calls to the TokenUsageReport method.
+		TokenUsageReport []struct {
+		}
 	}
-	lockClose                 sync.RWMutex
-	lockHealthCheck           sync.RWMutex
-	lockIdentityHealthCheck   sync.RWMutex
-	lockParse                 sync.RWMutex
-	lockRequire               sync.RWMutex
-	lockRequireWithAttributes sync.RWMutex
+	lockClose                    sync.RWMutex
+	lockHealthCheck              sync.RWMutex
+	lockIdentityHealthCheck      sync.RWMutex
+	lockParse                    sync.RWMutex
+	lockRequire                  sync.RWMutex
+	lockRequireAll               sync.RWMutex
+	lockRequireAllWithAttributes sync.RWMutex
+	lockRequireAny               sync.RWMutex
+	lockRequireAnyWithAttributes sync.RWMutex
+	lockRequireWithAttributes    sync.RWMutex
+	lockTokenUsageReport         sync.RWMutex
 }
 
 // Close calls CloseFunc.
@@ -288,6 +358,158 @@ func (mock *MiddlewareMock) RequireCalls() []struct {
 	return calls
 }
 
+// RequireAll calls RequireAllFunc.
+func (mock *MiddlewareMock) RequireAll(permissions []string, handlerFunc http.HandlerFunc) http.HandlerFunc {
+	if mock.RequireAllFunc == nil {
+		panic("MiddlewareMock.RequireAllFunc: method is nil but Middleware.RequireAll was just called")
+	}
+	callInfo := struct {
+		Permissions []string
+		HandlerFunc http.HandlerFunc
+	}{
+		Permissions: permissions,
+		HandlerFunc: handlerFunc,
+	}
+	mock.lockRequireAll.Lock()
+	mock.calls.RequireAll = append(mock.calls.RequireAll, callInfo)
+	mock.lockRequireAll.Unlock()
+	return mock.RequireAllFunc(permissions, handlerFunc)
+}
+
+// RequireAllCalls gets all the calls that were made to RequireAll.
+// Check the length with:
+//
+//	len(mockedMiddleware.RequireAllCalls())
+func (mock *MiddlewareMock) RequireAllCalls() []struct {
+	Permissions []string
+	HandlerFunc http.HandlerFunc
+} {
+	var calls []struct {
+		Permissions []string
+		HandlerFunc http.HandlerFunc
+	}
+	mock.lockRequireAll.RLock()
+	calls = mock.calls.RequireAll
+	mock.lockRequireAll.RUnlock()
+	return calls
+}
+
+// RequireAllWithAttributes calls RequireAllWithAttributesFunc.
+func (mock *MiddlewareMock) RequireAllWithAttributes(permissions []string, handlerFunc http.HandlerFunc, getAttributes authorisation.GetAttributesFromRequest) http.HandlerFunc {
+	if mock.RequireAllWithAttributesFunc == nil {
+		panic("MiddlewareMock.RequireAllWithAttributesFunc: method is nil but Middleware.RequireAllWithAttributes was just called")
+	}
+	callInfo := struct {
+		Permissions   []string
+		HandlerFunc   http.HandlerFunc
+		GetAttributes authorisation.GetAttributesFromRequest
+	}{
+		Permissions:   permissions,
+		HandlerFunc:   handlerFunc,
+		GetAttributes: getAttributes,
+	}
+	mock.lockRequireAllWithAttributes.Lock()
+	mock.calls.RequireAllWithAttributes = append(mock.calls.RequireAllWithAttributes, callInfo)
+	mock.lockRequireAllWithAttributes.Unlock()
+	return mock.RequireAllWithAttributesFunc(permissions, handlerFunc, getAttributes)
+}
+
+// RequireAllWithAttributesCalls gets all the calls that were made to RequireAllWithAttributes.
+// Check the length with:
+//
+//	len(mockedMiddleware.RequireAllWithAttributesCalls())
+func (mock *MiddlewareMock) RequireAllWithAttributesCalls() []struct {
+	Permissions   []string
+	HandlerFunc   http.HandlerFunc
+	GetAttributes authorisation.GetAttributesFromRequest
+} {
+	var calls []struct {
+		Permissions   []string
+		HandlerFunc   http.HandlerFunc
+		GetAttributes authorisation.GetAttributesFromRequest
+	}
+	mock.lockRequireAllWithAttributes.RLock()
+	calls = mock.calls.RequireAllWithAttributes
+	mock.lockRequireAllWithAttributes.RUnlock()
+	return calls
+}
+
+// RequireAny calls RequireAnyFunc.
+func (mock *MiddlewareMock) RequireAny(permissions []string, handlerFunc http.HandlerFunc) http.HandlerFunc {
+	if mock.RequireAnyFunc == nil {
+		panic("MiddlewareMock.RequireAnyFunc: method is nil but Middleware.RequireAny was just called")
+	}
+	callInfo := struct {
+		Permissions []string
+		HandlerFunc http.HandlerFunc
+	}{
+		Permissions: permissions,
+		HandlerFunc: handlerFunc,
+	}
+	mock.lockRequireAny.Lock()
+	mock.calls.RequireAny = append(mock.calls.RequireAny, callInfo)
+	mock.lockRequireAny.Unlock()
+	return mock.RequireAnyFunc(permissions, handlerFunc)
+}
+
+// RequireAnyCalls gets all the calls that were made to RequireAny.
+// Check the length with:
+//
+//	len(mockedMiddleware.RequireAnyCalls())
+func (mock *MiddlewareMock) RequireAnyCalls() []struct {
+	Permissions []string
+	HandlerFunc http.HandlerFunc
+} {
+	var calls []struct {
+		Permissions []string
+		HandlerFunc http.HandlerFunc
+	}
+	mock.lockRequireAny.RLock()
+	calls = mock.calls.RequireAny
+	mock.lockRequireAny.RUnlock()
+	return calls
+}
+
+// RequireAnyWithAttributes calls RequireAnyWithAttributesFunc.
+func (mock *MiddlewareMock) RequireAnyWithAttributes(permissions []string, handlerFunc http.HandlerFunc, getAttributes authorisation.GetAttributesFromRequest) http.HandlerFunc {
+	if mock.RequireAnyWithAttributesFunc == nil {
+		panic("MiddlewareMock.RequireAnyWithAttributesFunc: method is nil but Middleware.RequireAnyWithAttributes was just called")
+	}
+	callInfo := struct {
+		Permissions   []string
+		HandlerFunc   http.HandlerFunc
+		GetAttributes authorisation.GetAttributesFromRequest
+	}{
+		Permissions:   permissions,
+		HandlerFunc:   handlerFunc,
+		GetAttributes: getAttributes,
+	}
+	mock.lockRequireAnyWithAttributes.Lock()
+	mock.calls.RequireAnyWithAttributes = append(mock.calls.RequireAnyWithAttributes, callInfo)
+	mock.lockRequireAnyWithAttributes.Unlock()
+	return mock.RequireAnyWithAttributesFunc(permissions, handlerFunc, getAttributes)
+}
+
+// RequireAnyWithAttributesCalls gets all the calls that were made to RequireAnyWithAttributes.
+// Check the length with:
+//
+//	len(mockedMiddleware.RequireAnyWithAttributesCalls())
+func (mock *MiddlewareMock) RequireAnyWithAttributesCalls() []struct {
+	Permissions   []string
+	HandlerFunc   http.HandlerFunc
+	GetAttributes authorisation.GetAttributesFromRequest
+} {
+	var calls []struct {
+		Permissions   []string
+		HandlerFunc   http.HandlerFunc
+		GetAttributes authorisation.GetAttributesFromRequest
+	}
+	mock.lockRequireAnyWithAttributes.RLock()
+	calls = mock.calls.RequireAnyWithAttributes
+	mock.lockRequireAnyWithAttributes.RUnlock()
+	return calls
+}
+
 // RequireWithAttributes calls RequireWithAttributesFunc.
 func (mock *MiddlewareMock) RequireWithAttributes(permission string, handlerFunc http.HandlerFunc, getAttributes authorisation.GetAttributesFromRequest) http.HandlerFunc {
 	if mock.RequireWithAttributesFunc == nil {
@@ -327,3 +549,30 @@ func (mock *MiddlewareMock) RequireWithAttributesCalls() []struct {
 	mock.lockRequireWithAttributes.RUnlock()
 	return calls
 }
+
+// TokenUsageReport calls TokenUsageReportFunc.
+func (mock *MiddlewareMock) TokenUsageReport() []authorisation.TokenUsage {
+	if mock.TokenUsageReportFunc == nil {
+		panic("MiddlewareMock.TokenUsageReportFunc: method is nil but Middleware.TokenUsageReport was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockTokenUsageReport.Lock()
+	mock.calls.TokenUsageReport = append(mock.calls.TokenUsageReport, callInfo)
+	mock.lockTokenUsageReport.Unlock()
+	return mock.TokenUsageReportFunc()
+}
+
+// TokenUsageReportCalls gets all the calls that were made to TokenUsageReport.
+// Check the length with:
+//
+//	len(mockedMiddleware.TokenUsageReportCalls())
+func (mock *MiddlewareMock) TokenUsageReportCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockTokenUsageReport.RLock()
+	calls = mock.calls.TokenUsageReport
+	mock.lockTokenUsageReport.RUnlock()
+	return calls
+}
@@ -24,6 +24,12 @@ var _ authorisation.PermissionsChecker = &PermissionsCheckerMock{}
 //			CloseFunc: func(ctx context.Context) error {
 //				panic("mock out the Close method")
 //			},
+//			HasAllPermissionsFunc: func(ctx context.Context, entityData permsdk.EntityData, permissions []string, attributes map[string]string) (bool, error) {
+//				panic("mock out the HasAllPermissions method")
+//			},
+//			HasAnyPermissionFunc: func(ctx context.Context, entityData permsdk.EntityData, permissions []string, attributes map[string]string) (bool, error) {
+//				panic("mock out the HasAnyPermission method")
+//			},
 //			HasPermissionFunc: func(ctx context.Context, entityData permsdk.EntityData, permission string, attributes map[string]string) (bool, error) {
 //				panic("mock out the HasPermission method")
 //			},
@@ -40,6 +46,12 @@ type PermissionsCheckerMock struct {
 	// CloseFunc mocks the Close method.
 	CloseFunc func(ctx context.Context) error
 
+	// HasAllPermissionsFunc mocks the HasAllPermissions method.
+	HasAllPermissionsFunc func(ctx context.Context, entityData permsdk.EntityData, permissions []string, attributes map[string]string) (bool, error)
+
+	// HasAnyPermissionFunc mocks the HasAnyPermission method.
+	HasAnyPermissionFunc func(ctx context.Context, entityData permsdk.EntityData, permissions []string, attributes map[string]string) (bool, error)
+
 	// HasPermissionFunc mocks the HasPermission method.
 	HasPermissionFunc func(ctx context.Context, entityData permsdk.EntityData, permission string, attributes map[string]string) (bool, error)
 
@@ -53,6 +65,28 @@ type PermissionsCheckerMock struct {
 			// Ctx is the ctx argument value.
 			Ctx context.Context
 		}
+		// HasAllPermissions holds details about calls to the HasAllPermissions method.
+		HasAllPermissions []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// EntityData is the entityData argument value.
+			EntityData permsdk.EntityData
+			// Permissions is the permissions argument value.
+			Permissions []string
+			// Attributes is the attributes argument value.
+			Attributes map[string]string
+		}
+		// HasAnyPermission holds details about calls to the HasAnyPermission method.
+		HasAnyPermission []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// EntityData is the entityData argument value.
+			EntityData permsdk.EntityData
+			// Permissions is the permissions argument value.
+			Permissions []string
+			// Attributes is the attributes argument value.
+			Attributes map[string]string
+		}
 		// HasPermission holds details about calls to the HasPermission method.
 		HasPermission []struct {
 			// Ctx is the ctx argument value.
@@ -72,9 +106,11 @@ type PermissionsCheckerMock struct {
 			State *health.CheckState
 		}
 	}
-	lockClose         sync.RWMutex
-	lockHasPermission sync.RWMutex
-	lockHealthCheck   sync.RWMutex
+	lockClose             sync.RWMutex
+	lockHasAllPermissions sync.RWMutex
+	lockHasAnyPermission  sync.RWMutex
+	lockHasPermission     sync.RWMutex
+	lockHealthCheck       sync.RWMutex
 }
 
 // Close calls CloseFunc.
@@ -109,6 +145,94 @@ func (mock *PermissionsCheckerMock) CloseCalls() []struct {
 	return calls
 }
 
+// HasAllPermissions calls HasAllPermissionsFunc.
+func (mock *PermissionsCheckerMock) HasAllPermissions(ctx context.Context, entityData permsdk.EntityData, permissions []string, attributes map[string]string) (bool, error) {
+	if mock.HasAllPermissionsFunc == nil {
+		panic("PermissionsCheckerMock.HasAllPermissionsFunc: method is nil but PermissionsChecker.HasAllPermissions was just called")
+	}
+	callInfo := struct {
+		Ctx         context.Context
+		EntityData  permsdk.EntityData
+		Permissions []string
+		Attributes  map[string]string
+	}{
+		Ctx:         ctx,
+		EntityData:  entityData,
+		Permissions: permissions,
+		Attributes:  attributes,
+	}
+	mock.lockHasAllPermissions.Lock()
+	mock.calls.HasAllPermissions = append(mock.calls.HasAllPermissions, callInfo)
+	mock.lockHasAllPermissions.Unlock()
+	return mock.HasAllPermissionsFunc(ctx, entityData, permissions, attributes)
+}
+
+// HasAllPermissionsCalls gets all the calls that were made to HasAllPermissions.
+// Check the length with:
+//
+//	len(mockedPermissionsChecker.HasAllPermissionsCalls())
+func (mock *PermissionsCheckerMock) HasAllPermissionsCalls() []struct {
+	Ctx         context.Context
+	EntityData  permsdk.EntityData
+	Permissions []string
+	Attributes  map[string]string
+} {
+	var calls []struct {
+		Ctx         context.Context
+		EntityData  permsdk.EntityData
+		Permissions []string
+		Attributes  map[string]string
+	}
+	mock.lockHasAllPermissions.RLock()
+	calls = mock.calls.HasAllPermissions
+	mock.lockHasAllPermissions.RUnlock()
+	return calls
+}
+
+// HasAnyPermission calls HasAnyPermissionFunc.
+func (mock *PermissionsCheckerMock) HasAnyPermission(ctx context.Context, entityData permsdk.EntityData, permissions []string, attributes map[string]string) (bool, error) {
+	if mock.HasAnyPermissionFunc == nil {
+		panic("PermissionsCheckerMock.HasAnyPermissionFunc: method is nil but PermissionsChecker.HasAnyPermission was just called")
+	}
+	callInfo := struct {
+		Ctx         context.Context
+		EntityData  permsdk.EntityData
+		Permissions []string
+		Attributes  map[string]string
+	}{
+		Ctx:         ctx,
+		EntityData:  entityData,
+		Permissions: permissions,
+		Attributes:  attributes,
+	}
+	mock.lockHasAnyPermission.Lock()
+	mock.calls.HasAnyPermission = append(mock.calls.HasAnyPermission, callInfo)
+	mock.lockHasAnyPermission.Unlock()
+	return mock.HasAnyPermissionFunc(ctx, entityData, permissions, attributes)
+}
+
+// HasAnyPermissionCalls gets all the calls that were made to HasAnyPermission.
+// Check the length with:
+//
+//	len(mockedPermissionsChecker.HasAnyPermissionCalls())
+func (mock *PermissionsCheckerMock) HasAnyPermissionCalls() []struct {
+	Ctx         context.Context
+	EntityData  permsdk.EntityData
+	Permissions []string
+	Attributes  map[string]string
+} {
+	var calls []struct {
+		Ctx         context.Context
+		EntityData  permsdk.EntityData
+		Permissions []string
+		Attributes  map[string]string
+	}
+	mock.lockHasAnyPermission.RLock()
+	calls = mock.calls.HasAnyPermission
+	mock.lockHasAnyPermission.RUnlock()
+	return calls
+}
+
 // HasPermission calls HasPermissionFunc.
 func (mock *PermissionsCheckerMock) HasPermission(ctx context.Context, entityData permsdk.EntityData, permission string, attributes map[string]string) (bool, error) {
 	if mock.HasPermissionFunc == nil {
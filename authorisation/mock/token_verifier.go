@@ -0,0 +1,126 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mock
+
+import (
+	"context"
+	"github.com/ONSdigital/dp-authorisation/v2/authorisation"
+	"sync"
+)
+
+// Ensure, that TokenVerifierMock does implement authorisation.TokenVerifier.
+// If this is not the case, regenerate this file with moq.
+var _ authorisation.TokenVerifier = &TokenVerifierMock{}
+
+// TokenVerifierMock is a mock implementation of authorisation.TokenVerifier.
+//
+//	func TestSomethingThatUsesTokenVerifier(t *testing.T) {
+//
+//		// make and configure a mocked authorisation.TokenVerifier
+//		mockedTokenVerifier := &TokenVerifierMock{
+//			CanHandleFunc: func(token authorisation.Token) bool {
+//				panic("mock out the CanHandle method")
+//			},
+//			VerifyFunc: func(ctx context.Context, token authorisation.Token) (*authorisation.Entity, error) {
+//				panic("mock out the Verify method")
+//			},
+//		}
+//
+//		// use mockedTokenVerifier in code that requires authorisation.TokenVerifier
+//		// and then make assertions.
+//
+//	}
+type TokenVerifierMock struct {
+	// CanHandleFunc mocks the CanHandle method.
+	CanHandleFunc func(token authorisation.Token) bool
+
+	// VerifyFunc mocks the Verify method.
+	VerifyFunc func(ctx context.Context, token authorisation.Token) (*authorisation.Entity, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// CanHandle holds details about calls to the CanHandle method.
+		CanHandle []struct {
+			// Token is the token argument value.
+			Token authorisation.Token
+		}
+		// Verify holds details about calls to the Verify method.
+		Verify []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Token is the token argument value.
+			Token authorisation.Token
+		}
+	}
+	lockCanHandle sync.RWMutex
+	lockVerify    sync.RWMutex
+}
+
+// CanHandle calls CanHandleFunc.
+func (mock *TokenVerifierMock) CanHandle(token authorisation.Token) bool {
+	if mock.CanHandleFunc == nil {
+		panic("TokenVerifierMock.CanHandleFunc: method is nil but TokenVerifier.CanHandle was just called")
+	}
+	callInfo := struct {
+		Token authorisation.Token
+	}{
+		Token: token,
+	}
+	mock.lockCanHandle.Lock()
+	mock.calls.CanHandle = append(mock.calls.CanHandle, callInfo)
+	mock.lockCanHandle.Unlock()
+	return mock.CanHandleFunc(token)
+}
+
+// CanHandleCalls gets all the calls that were made to CanHandle.
+// Check the length with:
+//
+//	len(mockedTokenVerifier.CanHandleCalls())
+func (mock *TokenVerifierMock) CanHandleCalls() []struct {
+	Token authorisation.Token
+} {
+	var calls []struct {
+		Token authorisation.Token
+	}
+	mock.lockCanHandle.RLock()
+	calls = mock.calls.CanHandle
+	mock.lockCanHandle.RUnlock()
+	return calls
+}
+
+// Verify calls VerifyFunc.
+func (mock *TokenVerifierMock) Verify(ctx context.Context, token authorisation.Token) (*authorisation.Entity, error) {
+	if mock.VerifyFunc == nil {
+		panic("TokenVerifierMock.VerifyFunc: method is nil but TokenVerifier.Verify was just called")
+	}
+	callInfo := struct {
+		Ctx   context.Context
+		Token authorisation.Token
+	}{
+		Ctx:   ctx,
+		Token: token,
+	}
+	mock.lockVerify.Lock()
+	mock.calls.Verify = append(mock.calls.Verify, callInfo)
+	mock.lockVerify.Unlock()
+	return mock.VerifyFunc(ctx, token)
+}
+
+// VerifyCalls gets all the calls that were made to Verify.
+// Check the length with:
+//
+//	len(mockedTokenVerifier.VerifyCalls())
+func (mock *TokenVerifierMock) VerifyCalls() []struct {
+	Ctx   context.Context
+	Token authorisation.Token
+} {
+	var calls []struct {
+		Ctx   context.Context
+		Token authorisation.Token
+	}
+	mock.lockVerify.RLock()
+	calls = mock.calls.Verify
+	mock.lockVerify.RUnlock()
+	return calls
+}
@@ -23,6 +23,9 @@ var _ authorisation.ZebedeeClient = &ZebedeeClientMock{}
 //			CheckTokenIdentityFunc: func(ctx context.Context, token string) (*dprequest.IdentityResponse, error) {
 //				panic("mock out the CheckTokenIdentity method")
 //			},
+//			CheckUserTokenIdentityFunc: func(ctx context.Context, token string) (*dprequest.IdentityResponse, error) {
+//				panic("mock out the CheckUserTokenIdentity method")
+//			},
 //		}
 //
 //		// use mockedZebedeeClient in code that requires authorisation.ZebedeeClient
@@ -33,6 +36,9 @@ type ZebedeeClientMock struct {
 	// CheckTokenIdentityFunc mocks the CheckTokenIdentity method.
 	CheckTokenIdentityFunc func(ctx context.Context, token string) (*dprequest.IdentityResponse, error)
 
+	// CheckUserTokenIdentityFunc mocks the CheckUserTokenIdentity method.
+	CheckUserTokenIdentityFunc func(ctx context.Context, token string) (*dprequest.IdentityResponse, error)
+
 	// calls tracks calls to the methods.
 	calls struct {
 		// CheckTokenIdentity holds details about calls to the CheckTokenIdentity method.
@@ -42,8 +48,16 @@ type ZebedeeClientMock struct {
 			// Token is the token argument value.
 			Token string
 		}
+		// CheckUserTokenIdentity holds details about calls to the CheckUserTokenIdentity method.
+		CheckUserTokenIdentity []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Token is the token argument value.
+			Token string
+		}
 	}
-	lockCheckTokenIdentity sync.RWMutex
+	lockCheckTokenIdentity     sync.RWMutex
+	lockCheckUserTokenIdentity sync.RWMutex
 }
 
 // CheckTokenIdentity calls CheckTokenIdentityFunc.
@@ -81,3 +95,39 @@ func (mock *ZebedeeClientMock) CheckTokenIdentityCalls() []struct {
 	mock.lockCheckTokenIdentity.RUnlock()
 	return calls
 }
+
+// CheckUserTokenIdentity calls CheckUserTokenIdentityFunc.
+func (mock *ZebedeeClientMock) CheckUserTokenIdentity(ctx context.Context, token string) (*dprequest.IdentityResponse, error) {
+	if mock.CheckUserTokenIdentityFunc == nil {
+		panic("ZebedeeClientMock.CheckUserTokenIdentityFunc: method is nil but ZebedeeClient.CheckUserTokenIdentity was just called")
+	}
+	callInfo := struct {
+		Ctx   context.Context
+		Token string
+	}{
+		Ctx:   ctx,
+		Token: token,
+	}
+	mock.lockCheckUserTokenIdentity.Lock()
+	mock.calls.CheckUserTokenIdentity = append(mock.calls.CheckUserTokenIdentity, callInfo)
+	mock.lockCheckUserTokenIdentity.Unlock()
+	return mock.CheckUserTokenIdentityFunc(ctx, token)
+}
+
+// CheckUserTokenIdentityCalls gets all the calls that were made to CheckUserTokenIdentity.
+// Check the length with:
+//
+//	len(mockedZebedeeClient.CheckUserTokenIdentityCalls())
+func (mock *ZebedeeClientMock) CheckUserTokenIdentityCalls() []struct {
+	Ctx   context.Context
+	Token string
+} {
+	var calls []struct {
+		Ctx   context.Context
+		Token string
+	}
+	mock.lockCheckUserTokenIdentity.RLock()
+	calls = mock.calls.CheckUserTokenIdentity
+	mock.lockCheckUserTokenIdentity.RUnlock()
+	return calls
+}
@@ -0,0 +1,77 @@
+package authorisation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Mode determines how the middleware acts on the outcome of a permissions check
+type Mode string
+
+const (
+	// ModeEnforce rejects requests that are not authorised
+	ModeEnforce Mode = "enforce"
+	// ModeShadow runs the full permissions check, recording requests that would have been rejected,
+	// but always calls the handler. Use this to validate policies before enforcing them.
+	ModeShadow Mode = "shadow"
+	// ModeDisabled does not check permissions at all
+	ModeDisabled Mode = "disabled"
+)
+
+// modeStrictness orders the modes, so that the strictest mode can be used for a list of permissions
+var modeStrictness = map[Mode]int{
+	ModeDisabled: 0,
+	ModeShadow:   1,
+	ModeEnforce:  2,
+}
+
+// ParseMode returns the Mode with the given case-insensitive name
+func ParseMode(name string) (Mode, error) {
+	mode := Mode(strings.ToLower(strings.TrimSpace(name)))
+	if _, ok := modeStrictness[mode]; !ok {
+		return "", fmt.Errorf("invalid authorisation mode %q", name)
+	}
+	return mode, nil
+}
+
+// parsePermissionModes parses a list of 'permission=mode' overrides, e.g. 'datasets:edit=shadow'
+func parsePermissionModes(overrides []string) (map[string]Mode, error) {
+	permissionModes := make(map[string]Mode, len(overrides))
+	for _, override := range overrides {
+		permission, name, found := strings.Cut(override, "=")
+		permission = strings.TrimSpace(permission)
+		if !found || permission == "" {
+			return nil, fmt.Errorf("invalid authorisation permission mode %q, expected 'permission=mode'", override)
+		}
+		mode, err := ParseMode(name)
+		if err != nil {
+			return nil, err
+		}
+		permissionModes[permission] = mode
+	}
+	return permissionModes, nil
+}
+
+// modeFor returns the mode used to check the given permissions. If the permissions have different modes,
+// the strictest is used.
+func (m PermissionCheckMiddleware) modeFor(permissions []string) Mode {
+	defaultMode := m.mode
+	if defaultMode == "" {
+		defaultMode = ModeEnforce
+	}
+
+	var mode Mode
+	for _, permission := range permissions {
+		permissionMode, ok := m.permissionModes[permission]
+		if !ok {
+			permissionMode = defaultMode
+		}
+		if mode == "" || modeStrictness[permissionMode] > modeStrictness[mode] {
+			mode = permissionMode
+		}
+	}
+	if mode == "" {
+		return defaultMode
+	}
+	return mode
+}
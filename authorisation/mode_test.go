@@ -0,0 +1,206 @@
+package authorisation_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-authorisation/v2/audit"
+	"github.com/ONSdigital/dp-authorisation/v2/authorisation"
+	"github.com/ONSdigital/dp-authorisation/v2/authorisation/mock"
+	"github.com/ONSdigital/dp-authorisation/v2/authorisationtest"
+	permsdk "github.com/ONSdigital/dp-permissions-api/sdk"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseMode(t *testing.T) {
+	Convey("Given valid mode names in any case", t, func() {
+		Convey("Then the modes are returned", func() {
+			for name, expected := range map[string]authorisation.Mode{
+				"enforce":  authorisation.ModeEnforce,
+				"Shadow":   authorisation.ModeShadow,
+				"DISABLED": authorisation.ModeDisabled,
+			} {
+				mode, err := authorisation.ParseMode(name)
+				So(err, ShouldBeNil)
+				So(mode, ShouldEqual, expected)
+			}
+		})
+	})
+
+	Convey("Given an invalid mode name", t, func() {
+		_, err := authorisation.ParseMode("audit")
+
+		Convey("Then an error is returned", func() {
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestConfig_EffectiveMode(t *testing.T) {
+	Convey("Given a config without a mode", t, func() {
+		config := &authorisation.Config{}
+
+		Convey("Then the mode is derived from the enabled flag", func() {
+			mode, err := config.EffectiveMode()
+			So(err, ShouldBeNil)
+			So(mode, ShouldEqual, authorisation.ModeDisabled)
+
+			config.Enabled = true
+			mode, err = config.EffectiveMode()
+			So(err, ShouldBeNil)
+			So(mode, ShouldEqual, authorisation.ModeEnforce)
+		})
+	})
+
+	Convey("Given a config with a mode", t, func() {
+		config := &authorisation.Config{Enabled: true, Mode: "shadow"}
+
+		Convey("Then the configured mode takes precedence over the enabled flag", func() {
+			mode, err := config.EffectiveMode()
+			So(err, ShouldBeNil)
+			So(mode, ShouldEqual, authorisation.ModeShadow)
+		})
+	})
+}
+
+func TestMiddleware_ShadowMode(t *testing.T) {
+	Convey("Given a middleware in shadow mode and a caller without the required permission", t, func() {
+		sink := audit.NewMemorySink(10)
+		permissionsChecker := &mock.PermissionsCheckerMock{
+			HasPermissionFunc: func(ctx context.Context, entityData permsdk.EntityData, permission string, attributes map[string]string) (bool, error) {
+				return false, nil
+			},
+		}
+		middleware := authorisation.NewMiddlewareFromDependencies(newMockJWTParser(), permissionsChecker, zebedeeIdentity, identityClient,
+			authorisation.WithMode(authorisation.ModeShadow), authorisation.WithAuditSink(sink))
+
+		response := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, testURL, http.NoBody)
+		mockHandler := &mockHandler{calls: 0}
+
+		Convey("When a request with a valid token is made", func() {
+			request.Header.Set("Authorization", authorisationtest.AdminJWTToken)
+			middleware.Require(permission, mockHandler.ServeHTTP)(response, request)
+
+			Convey("Then the permissions are checked", func() {
+				So(permissionsChecker.HasPermissionCalls(), ShouldHaveLength, 1)
+			})
+
+			Convey("Then the handler is called with the authenticated entity", func() {
+				So(mockHandler.calls, ShouldEqual, 1)
+				So(response.Code, ShouldEqual, http.StatusOK)
+				entity, ok := authorisation.EntityFromContext(mockHandler.request.Context())
+				So(ok, ShouldBeTrue)
+				So(entity.UserID(), ShouldEqual, dummyEntityData.UserID)
+			})
+
+			Convey("Then the decision that would have been made is recorded", func() {
+				decisions := sink.Decisions()
+				So(decisions, ShouldHaveLength, 1)
+				So(decisions[0].Outcome, ShouldEqual, audit.OutcomeDeny)
+				So(decisions[0].Mode, ShouldEqual, string(authorisation.ModeShadow))
+				So(decisions[0].Reason, ShouldEqual, string(authorisation.ErrorCodePermissionDenied))
+			})
+		})
+
+		Convey("When a request without a token is made", func() {
+			middleware.Require(permission, mockHandler.ServeHTTP)(response, request)
+
+			Convey("Then the handler is called with an unauthenticated entity", func() {
+				So(mockHandler.calls, ShouldEqual, 1)
+				entity, ok := authorisation.EntityFromContext(mockHandler.request.Context())
+				So(ok, ShouldBeTrue)
+				So(entity.TokenType, ShouldEqual, authorisation.TokenTypeNone)
+			})
+		})
+	})
+}
+
+func TestMiddleware_WithPermissionModes(t *testing.T) {
+	Convey("Given an enforcing middleware that shadows one permission and disables another", t, func() {
+		permissionsChecker := &mock.PermissionsCheckerMock{
+			HasPermissionFunc: func(ctx context.Context, entityData permsdk.EntityData, permission string, attributes map[string]string) (bool, error) {
+				return false, nil
+			},
+			HasAllPermissionsFunc: func(ctx context.Context, entityData permsdk.EntityData, permissions []string, attributes map[string]string) (bool, error) {
+				return false, nil
+			},
+		}
+		middleware := authorisation.NewMiddlewareFromDependencies(newMockJWTParser(), permissionsChecker, zebedeeIdentity, identityClient,
+			authorisation.WithPermissionModes(map[string]authorisation.Mode{
+				"datasets:new":    authorisation.ModeShadow,
+				"datasets:legacy": authorisation.ModeDisabled,
+			}))
+
+		response := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, testURL, http.NoBody)
+		request.Header.Set("Authorization", authorisationtest.AdminJWTToken)
+		mockHandler := &mockHandler{calls: 0}
+
+		Convey("When a request is made for the shadowed permission", func() {
+			middleware.Require("datasets:new", mockHandler.ServeHTTP)(response, request)
+
+			Convey("Then the permission is checked but the handler is called", func() {
+				So(permissionsChecker.HasPermissionCalls(), ShouldHaveLength, 1)
+				So(mockHandler.calls, ShouldEqual, 1)
+			})
+		})
+
+		Convey("When a request is made for the disabled permission", func() {
+			middleware.Require("datasets:legacy", mockHandler.ServeHTTP)(response, request)
+
+			Convey("Then the permission is not checked and the handler is called", func() {
+				So(permissionsChecker.HasPermissionCalls(), ShouldHaveLength, 0)
+				So(mockHandler.calls, ShouldEqual, 1)
+			})
+		})
+
+		Convey("When a request is made for permissions with different modes", func() {
+			middleware.RequireAll([]string{"datasets:new", "datasets:read"}, mockHandler.ServeHTTP)(response, request)
+
+			Convey("Then the strictest mode is used and the request is rejected", func() {
+				So(mockHandler.calls, ShouldEqual, 0)
+				So(response.Code, ShouldEqual, http.StatusForbidden)
+			})
+		})
+	})
+}
+
+func TestMiddleware_NewFeatureFlaggedMiddleware_Mode(t *testing.T) {
+	Convey("Given a config with shadow mode and authorisation not enabled", t, func() {
+		config := &authorisation.Config{
+			Mode:                           "shadow",
+			PermissionsAPIURL:              "http://localhost:4567",
+			PermissionsCacheUpdateInterval: time.Second * 60,
+		}
+
+		Convey("When a feature flagged middleware is created", func() {
+			middleware, err := authorisation.NewFeatureFlaggedMiddleware(context.Background(), config, map[string]string{})
+			So(err, ShouldBeNil)
+			defer middleware.Close(context.Background())
+
+			Convey("Then a permission checking middleware is returned", func() {
+				So(reflect.TypeOf(middleware), ShouldEqual, reflect.TypeOf(&authorisation.PermissionCheckMiddleware{}))
+			})
+		})
+	})
+
+	Convey("Given a config with an invalid permission mode override", t, func() {
+		config := &authorisation.Config{
+			Enabled:         true,
+			PermissionModes: []string{"datasets:edit"},
+		}
+
+		Convey("When a feature flagged middleware is created", func() {
+			_, err := authorisation.NewFeatureFlaggedMiddleware(context.Background(), config, map[string]string{})
+
+			Convey("Then an error is returned", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
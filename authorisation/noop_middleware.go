@@ -16,17 +16,46 @@ func NewNoopMiddleware() *NoopMiddleware {
 	return &NoopMiddleware{}
 }
 
-// RequireWithAttributes wraps an existing handler. The Noop implementation just calls the underlying handler.
+// RequireWithAttributes wraps an existing handler. The Noop implementation just calls the underlying handler,
+// storing an unauthenticated Entity in the request context so that EntityFromContext behaves consistently.
 func (m NoopMiddleware) RequireWithAttributes(_ string, handlerFunc http.HandlerFunc, _ GetAttributesFromRequest) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-		handlerFunc(w, req)
+		handlerFunc(w, withNoopEntity(req))
 	}
 }
 
 // Require wraps an existing handler. The Noop implementation just calls the underlying handler.
 func (m NoopMiddleware) Require(_ string, handlerFunc http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-		handlerFunc(w, req)
+		handlerFunc(w, withNoopEntity(req))
+	}
+}
+
+// RequireAnyWithAttributes wraps an existing handler. The Noop implementation just calls the underlying handler.
+func (m NoopMiddleware) RequireAnyWithAttributes(_ []string, handlerFunc http.HandlerFunc, _ GetAttributesFromRequest) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		handlerFunc(w, withNoopEntity(req))
+	}
+}
+
+// RequireAny wraps an existing handler. The Noop implementation just calls the underlying handler.
+func (m NoopMiddleware) RequireAny(_ []string, handlerFunc http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		handlerFunc(w, withNoopEntity(req))
+	}
+}
+
+// RequireAllWithAttributes wraps an existing handler. The Noop implementation just calls the underlying handler.
+func (m NoopMiddleware) RequireAllWithAttributes(_ []string, handlerFunc http.HandlerFunc, _ GetAttributesFromRequest) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		handlerFunc(w, withNoopEntity(req))
+	}
+}
+
+// RequireAll wraps an existing handler. The Noop implementation just calls the underlying handler.
+func (m NoopMiddleware) RequireAll(_ []string, handlerFunc http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		handlerFunc(w, withNoopEntity(req))
 	}
 }
 
@@ -49,3 +78,16 @@ func (m NoopMiddleware) HealthCheck(_ context.Context, state *health.CheckState)
 func (m NoopMiddleware) IdentityHealthCheck(_ context.Context, state *health.CheckState) error {
 	return state.Update(health.StatusOK, "noop jwt keys request", 0)
 }
+
+// TokenUsageReport returns an empty report, as the Noop implementation does not authenticate requests
+func (m NoopMiddleware) TokenUsageReport() []TokenUsage {
+	return nil
+}
+
+// withNoopEntity returns the request with an unauthenticated Entity in its context, unless one is already present
+func withNoopEntity(req *http.Request) *http.Request {
+	if _, ok := EntityFromContext(req.Context()); ok {
+		return req
+	}
+	return req.WithContext(NewContextWithEntity(req.Context(), &Entity{TokenType: TokenTypeNone}))
+}
@@ -0,0 +1,284 @@
+package authorisation
+
+import (
+	"github.com/ONSdigital/dp-authorisation/v2/audit"
+	"github.com/ONSdigital/dp-authorisation/v2/identityclient"
+	"github.com/ONSdigital/dp-authorisation/v2/jwt"
+	"github.com/ONSdigital/dp-authorisation/v2/metrics"
+	"github.com/ONSdigital/dp-authorisation/v2/zebedeeclient"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Option configures optional behaviour of PermissionCheckMiddleware
+type Option func(m *PermissionCheckMiddleware)
+
+// WithErrorResponder sets the ErrorResponder used to write responses for rejected requests.
+// The default writes RFC 7807 application/problem+json bodies, see ProblemResponder.
+func WithErrorResponder(errorResponder ErrorResponder) Option {
+	return func(m *PermissionCheckMiddleware) {
+		if errorResponder != nil {
+			m.errorResponder = errorResponder
+		}
+	}
+}
+
+// WithChallenge configures the RFC 6750 WWW-Authenticate challenge sent when a request is rejected.
+//   - realm - the protection realm included in the challenge, omitted if empty
+//   - includeErrorDescription - whether a human readable error_description is included in the challenge
+func WithChallenge(realm string, includeErrorDescription bool) Option {
+	return func(m *PermissionCheckMiddleware) {
+		m.challenge = bearerChallenge{
+			realm:                   realm,
+			includeErrorDescription: includeErrorDescription,
+		}
+	}
+}
+
+// WithTokenExtractor sets the TokenExtractor used to read the access token from requests.
+// The default reads the token from the Authorization header, see NewAuthorizationHeaderExtractor.
+func WithTokenExtractor(tokenExtractor TokenExtractor) Option {
+	return func(m *PermissionCheckMiddleware) {
+		if tokenExtractor != nil {
+			m.tokenExtractor = tokenExtractor
+		}
+	}
+}
+
+// WithTokenVerifiers replaces the TokenVerifiers used to authenticate requests. The verifiers are tried in
+// the given order, and the first that can handle a token is used to verify it. The default verifiers are the
+// JWTVerifier followed by the ZebedeeVerifier, built from the middleware dependencies.
+func WithTokenVerifiers(tokenVerifiers ...TokenVerifier) Option {
+	return func(m *PermissionCheckMiddleware) {
+		m.tokenVerifiers = tokenVerifiers
+	}
+}
+
+// WithAuditSink sets the sink that allow and deny decisions are recorded to, see the audit package.
+// A sink that can be closed is closed along with the middleware.
+func WithAuditSink(auditSink audit.Sink) Option {
+	return func(m *PermissionCheckMiddleware) {
+		m.auditSink = auditSink
+	}
+}
+
+// WithMetrics sets the metrics used to instrument the middleware. When used with NewMiddlewareFromConfig,
+// the permissions cache, identity client and Zebedee client created by the constructor are also instrumented.
+func WithMetrics(authMetrics *metrics.Metrics) Option {
+	return func(m *PermissionCheckMiddleware) {
+		m.metrics = authMetrics
+	}
+}
+
+// WithTracerProvider sets the provider used to create OpenTelemetry spans, the global provider is used by default.
+// When used with NewMiddlewareFromConfig, the dependencies created by the constructor also use the provider.
+func WithTracerProvider(tracerProvider trace.TracerProvider) Option {
+	return func(m *PermissionCheckMiddleware) {
+		m.tracerProvider = tracerProvider
+	}
+}
+
+// WithMode sets the Mode used for permissions that do not have their own mode set. The default is ModeEnforce.
+func WithMode(mode Mode) Option {
+	return func(m *PermissionCheckMiddleware) {
+		m.mode = mode
+	}
+}
+
+// WithPermissionModes overrides the Mode used for individual permissions, e.g. to shadow a new permission
+// while the rest are enforced. When a handler requires several permissions, the strictest of their modes is used.
+func WithPermissionModes(permissionModes map[string]Mode) Option {
+	return func(m *PermissionCheckMiddleware) {
+		m.permissionModes = permissionModes
+	}
+}
+
+// WithZebedeeUserTokenSources sets the token sources that carry Florence user tokens, e.g. 'header:X-Florence-Token'.
+// Tokens from these sources are checked with Zebedee as user tokens. The default is DefaultZebedeeUserTokenSources.
+func WithZebedeeUserTokenSources(sources ...string) Option {
+	return func(m *PermissionCheckMiddleware) {
+		m.zebedeeUserTokenSources = sources
+	}
+}
+
+// WithZebedeeUserTokenFallback sets whether an opaque token from any other source is checked with Zebedee as a user
+// token when it is not a valid service token, e.g. for Florence user tokens sent in the Authorization header
+func WithZebedeeUserTokenFallback(enabled bool) Option {
+	return func(m *PermissionCheckMiddleware) {
+		m.zebedeeUserTokenFallback = enabled
+	}
+}
+
+// WithZebedeeTokenActions sets the action taken when a permission is requested using a Zebedee service or user
+// token, allowing Zebedee tokens to be deprecated one permission at a time. The AllPermissions key sets the action
+// for permissions that do not have their own action. Zebedee tokens are allowed by default.
+func WithZebedeeTokenActions(actions map[string]ZebedeeTokenAction) Option {
+	return func(m *PermissionCheckMiddleware) {
+		m.zebedeeTokenActions = actions
+	}
+}
+
+// WithRouteFunc sets the function used to determine the route of a request in the token usage report. The default
+// is DefaultRoute. Services using a router other than http.ServeMux should supply the matched route template, so
+// that requests for different resources are grouped together.
+func WithRouteFunc(routeFunc RouteFunc) Option {
+	return func(m *PermissionCheckMiddleware) {
+		if routeFunc != nil {
+			m.routeFunc = routeFunc
+		}
+	}
+}
+
+// resolveOptions applies the given options to an empty middleware, so that the values they set can be used
+// when creating dependencies
+func resolveOptions(opts []Option) *PermissionCheckMiddleware {
+	m := &PermissionCheckMiddleware{}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// configOptions returns the options derived from the given configuration
+func configOptions(config *Config) ([]Option, error) {
+	tokenExtractor, err := NewTokenExtractorFromSources(config.TokenSources)
+	if err != nil {
+		return nil, err
+	}
+
+	permissionModes, err := parsePermissionModes(config.PermissionModes)
+	if err != nil {
+		return nil, err
+	}
+
+	zebedeeTokenActions, err := parseZebedeeTokenActions(config.ZebedeeTokenActions)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []Option{
+		WithChallenge(config.ChallengeRealm, config.ChallengeErrorDescriptions),
+		WithTokenExtractor(tokenExtractor),
+		WithPermissionModes(permissionModes),
+		WithZebedeeUserTokenFallback(config.ZebedeeUserTokenFallback),
+		WithZebedeeTokenActions(zebedeeTokenActions),
+	}
+
+	if len(config.ZebedeeUserTokenSources) > 0 {
+		opts = append(opts, WithZebedeeUserTokenSources(config.ZebedeeUserTokenSources...))
+	}
+
+	// without an explicit mode, middleware created from config enforces permissions
+	if config.Mode != "" {
+		mode, err := ParseMode(config.Mode)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithMode(mode))
+	}
+
+	return opts, nil
+}
+
+// newIdentityClientFromConfig returns the identity client used to get the JWT verification keys. The keys are read
+// from the configured JSON Web Key Set URL if there is one, otherwise from the identity service.
+func newIdentityClientFromConfig(config *Config) (*identityclient.IdentityClient, error) {
+	var identityClient *identityclient.IdentityClient
+	var err error
+	if config.JWKSURL != "" {
+		identityClient, err = identityclient.NewJSONWebKeySetClient(config.JWKSURL, config.IdentityClientMaxRetries)
+	} else {
+		identityClient, err = identityclient.NewIdentityClient(config.IdentityWebKeySetURL, config.IdentityClientMaxRetries)
+	}
+	if err != nil {
+		return nil, err
+	}
+	identityClient.ParserOptions = jwtParserOptions(config)
+	identityClient.KeyRefreshMinInterval = config.JWTKeyRefreshMinInterval
+	identityClient.KeySnapshotPath = config.JWTKeySnapshotPath
+	identityClient.KeySnapshotMaxAge = config.JWTKeySnapshotMaxAge
+	return identityClient, nil
+}
+
+// jwtParserOptions returns the options for the JWT parser described by the given configuration
+func jwtParserOptions(config *Config) []jwt.ParserOption {
+	var opts []jwt.ParserOption
+	if len(config.JWTIssuers) > 0 {
+		opts = append(opts, jwt.WithIssuers(config.JWTIssuers...))
+	}
+	if len(config.JWTClientIDs) > 0 {
+		opts = append(opts, jwt.WithClientIDs(config.JWTClientIDs...))
+	}
+	if config.JWTTokenUse != "" {
+		opts = append(opts, jwt.WithTokenUse(config.JWTTokenUse))
+	}
+	if config.JWTLeeway > 0 {
+		opts = append(opts, jwt.WithLeeway(config.JWTLeeway))
+	}
+	if config.JWTMaxLifetime > 0 {
+		opts = append(opts, jwt.WithMaxLifetime(config.JWTMaxLifetime))
+	}
+	if config.JWTUserIDClaim != "" || config.JWTGroupsClaim != "" || config.JWTGroupPrefix != "" || config.JWTGroupsOptional {
+		opts = append(opts, jwt.WithClaimMapping(claimMappingFromConfig(config)))
+	}
+	if config.JWTVerificationCacheTTL > 0 {
+		opts = append(opts, jwt.WithVerificationCache(config.JWTVerificationCacheMaxEntries, config.JWTVerificationCacheTTL))
+	}
+	return opts
+}
+
+// claimMappingFromConfig returns the Cognito claim mapping, with the claims overridden by the given configuration.
+// Groups without the configured group prefix are ignored.
+func claimMappingFromConfig(config *Config) jwt.ClaimMapping {
+	mapping := jwt.DefaultClaimMapping()
+	if config.JWTUserIDClaim != "" {
+		mapping.UserIDClaim = config.JWTUserIDClaim
+	}
+	if config.JWTGroupsClaim != "" {
+		mapping.GroupsClaim = config.JWTGroupsClaim
+	}
+	if config.JWTGroupPrefix != "" {
+		mapping.GroupTransform = jwt.TrimGroupPrefix(config.JWTGroupPrefix, true)
+	}
+	mapping.GroupsRequired = !config.JWTGroupsOptional
+	return mapping
+}
+
+// newZebedeeClientFromConfig returns the Zebedee client described by the given configuration, instrumented as
+// set by the resolved options. Identities are cached unless the configured cache ttl is 0.
+func newZebedeeClientFromConfig(config *Config, resolved *PermissionCheckMiddleware) ZebedeeClient {
+	zebedeeClient := zebedeeclient.NewZebedeeClient(config.ZebedeeURL)
+	zebedeeClient.Metrics = resolved.metrics
+	zebedeeClient.TracerProvider = resolved.tracerProvider
+
+	if config.ZebedeeIdentityCacheTTL <= 0 {
+		return zebedeeClient
+	}
+	return zebedeeclient.NewCachingZebedeeClient(zebedeeClient, config.ZebedeeIdentityCacheTTL,
+		zebedeeclient.WithNegativeTTL(config.ZebedeeIdentityCacheNegativeTTL),
+		zebedeeclient.WithMaxEntries(config.ZebedeeIdentityCacheMaxEntries),
+	)
+}
+
+// newAuditSinkFromConfig returns the audit sink described by the given configuration, or nil if auditing is not configured
+func newAuditSinkFromConfig(config *Config) (audit.Sink, error) {
+	var sinks []audit.Sink
+	if config.AuditLogEnabled {
+		sinks = append(sinks, audit.NewLogSink())
+	}
+	if config.AuditFilePath != "" {
+		fileSink, err := audit.NewFileSink(config.AuditFilePath)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, fileSink)
+	}
+
+	switch len(sinks) {
+	case 0:
+		return nil, nil
+	case 1:
+		return audit.NewSamplingSink(sinks[0], config.AuditAllowSampleRate), nil
+	default:
+		return audit.NewSamplingSink(audit.NewMultiSink(sinks...), config.AuditAllowSampleRate), nil
+	}
+}
@@ -0,0 +1,140 @@
+package authorisation
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Token sources that can be configured using NewTokenExtractorFromSources
+const (
+	TokenSourceAuthorization = "authorization"
+	tokenSourceHeaderPrefix  = "header:"
+	tokenSourceCookiePrefix  = "cookie:"
+	bearerScheme             = "bearer"
+)
+
+// Token is an access token extracted from a request, along with where it was found.
+type Token struct {
+	Value  string
+	Source string
+}
+
+// TokenExtractor reads the access token from a request. The boolean return value is false if the request
+// does not contain a token in the place the extractor looks for it.
+type TokenExtractor interface {
+	ExtractToken(req *http.Request) (Token, bool)
+}
+
+// TokenExtractorFunc is an adapter allowing an ordinary function to be used as a TokenExtractor
+type TokenExtractorFunc func(req *http.Request) (Token, bool)
+
+// ExtractToken calls f(req)
+func (f TokenExtractorFunc) ExtractToken(req *http.Request) (Token, bool) {
+	return f(req)
+}
+
+// NewAuthorizationHeaderExtractor returns a TokenExtractor that reads the token from the Authorization header.
+// The 'Bearer' scheme is matched case-insensitively and removed. A value without a scheme is returned as is,
+// and values using any other scheme are ignored.
+func NewAuthorizationHeaderExtractor() TokenExtractor {
+	return TokenExtractorFunc(func(req *http.Request) (Token, bool) {
+		value, ok := bearerValue(req.Header.Get("Authorization"))
+		if !ok {
+			return Token{}, false
+		}
+		return Token{Value: value, Source: TokenSourceAuthorization}, true
+	})
+}
+
+// NewHeaderExtractor returns a TokenExtractor that reads the token from the named header, e.g. 'X-Florence-Token'.
+// An optional 'Bearer' scheme is removed from the value.
+func NewHeaderExtractor(name string) TokenExtractor {
+	source := tokenSourceHeaderPrefix + http.CanonicalHeaderKey(name)
+	return TokenExtractorFunc(func(req *http.Request) (Token, bool) {
+		value, ok := bearerValue(req.Header.Get(name))
+		if !ok {
+			return Token{}, false
+		}
+		return Token{Value: value, Source: source}, true
+	})
+}
+
+// NewCookieExtractor returns a TokenExtractor that reads the token from the named cookie, e.g. 'access_token'
+func NewCookieExtractor(name string) TokenExtractor {
+	source := tokenSourceCookiePrefix + name
+	return TokenExtractorFunc(func(req *http.Request) (Token, bool) {
+		cookie, err := req.Cookie(name)
+		if err != nil {
+			return Token{}, false
+		}
+		value := strings.TrimSpace(cookie.Value)
+		if value == "" {
+			return Token{}, false
+		}
+		return Token{Value: value, Source: source}, true
+	})
+}
+
+// NewChainExtractor returns a TokenExtractor that tries each of the given extractors in order,
+// returning the first token that is found.
+func NewChainExtractor(extractors ...TokenExtractor) TokenExtractor {
+	return TokenExtractorFunc(func(req *http.Request) (Token, bool) {
+		for _, extractor := range extractors {
+			if token, ok := extractor.ExtractToken(req); ok {
+				return token, true
+			}
+		}
+		return Token{}, false
+	})
+}
+
+// NewTokenExtractorFromSources creates a chain of token extractors from a list of source descriptions, in order of precedence:
+//   - 'authorization' - the Authorization header, see NewAuthorizationHeaderExtractor
+//   - 'header:<name>' - the named header, e.g. 'header:X-Florence-Token'
+//   - 'cookie:<name>' - the named cookie, e.g. 'cookie:access_token'
+//
+// If no sources are given, the Authorization header is used.
+func NewTokenExtractorFromSources(sources []string) (TokenExtractor, error) {
+	if len(sources) == 0 {
+		return NewAuthorizationHeaderExtractor(), nil
+	}
+
+	extractors := make([]TokenExtractor, 0, len(sources))
+	for _, source := range sources {
+		source = strings.TrimSpace(source)
+		switch {
+		case strings.EqualFold(source, TokenSourceAuthorization):
+			extractors = append(extractors, NewAuthorizationHeaderExtractor())
+		case strings.HasPrefix(source, tokenSourceHeaderPrefix) && len(source) > len(tokenSourceHeaderPrefix):
+			extractors = append(extractors, NewHeaderExtractor(strings.TrimPrefix(source, tokenSourceHeaderPrefix)))
+		case strings.HasPrefix(source, tokenSourceCookiePrefix) && len(source) > len(tokenSourceCookiePrefix):
+			extractors = append(extractors, NewCookieExtractor(strings.TrimPrefix(source, tokenSourceCookiePrefix)))
+		default:
+			return nil, fmt.Errorf("invalid token source %q", source)
+		}
+	}
+
+	return NewChainExtractor(extractors...), nil
+}
+
+// bearerValue removes an optional, case-insensitive 'Bearer' scheme from an authorization value.
+// It returns false if the value is empty or uses another scheme.
+func bearerValue(value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", false
+	}
+
+	scheme, credentials, found := strings.Cut(value, " ")
+	if !found {
+		// a value without a scheme is the token itself, unless it is only the scheme
+		return value, !strings.EqualFold(value, bearerScheme)
+	}
+	if !strings.EqualFold(scheme, bearerScheme) {
+		return "", false
+	}
+
+	credentials = strings.TrimSpace(credentials)
+	return credentials, credentials != ""
+}
@@ -0,0 +1,148 @@
+package authorisation_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ONSdigital/dp-authorisation/v2/authorisation"
+	"github.com/ONSdigital/dp-authorisation/v2/authorisation/mock"
+	"github.com/ONSdigital/dp-authorisation/v2/authorisationtest"
+	permsdk "github.com/ONSdigital/dp-permissions-api/sdk"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAuthorizationHeaderExtractor(t *testing.T) {
+	Convey("Given an Authorization header extractor", t, func() {
+		extractor := authorisation.NewAuthorizationHeaderExtractor()
+		request := httptest.NewRequest(http.MethodGet, testURL, http.NoBody)
+
+		Convey("When the header contains a token with the Bearer scheme in any case", func() {
+			request.Header.Set("Authorization", "bEaReR   abc.def.ghi")
+			token, ok := extractor.ExtractToken(request)
+
+			Convey("Then the token is returned without the scheme", func() {
+				So(ok, ShouldBeTrue)
+				So(token, ShouldResemble, authorisation.Token{Value: "abc.def.ghi", Source: authorisation.TokenSourceAuthorization})
+			})
+		})
+
+		Convey("When the header contains a token without a scheme", func() {
+			request.Header.Set("Authorization", "abc123")
+			token, ok := extractor.ExtractToken(request)
+
+			Convey("Then the token is returned as is", func() {
+				So(ok, ShouldBeTrue)
+				So(token.Value, ShouldEqual, "abc123")
+			})
+		})
+
+		Convey("When the header uses another scheme", func() {
+			request.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+			_, ok := extractor.ExtractToken(request)
+
+			Convey("Then no token is returned", func() {
+				So(ok, ShouldBeFalse)
+			})
+		})
+
+		Convey("When the header only contains the scheme", func() {
+			request.Header.Set("Authorization", "Bearer ")
+			_, ok := extractor.ExtractToken(request)
+
+			Convey("Then no token is returned", func() {
+				So(ok, ShouldBeFalse)
+			})
+		})
+	})
+}
+
+func TestTokenExtractorFromSources(t *testing.T) {
+	Convey("Given a token extractor created from a list of sources", t, func() {
+		extractor, err := authorisation.NewTokenExtractorFromSources([]string{"authorization", "cookie:access_token", "header:X-Florence-Token"})
+		So(err, ShouldBeNil)
+		request := httptest.NewRequest(http.MethodGet, testURL, http.NoBody)
+
+		Convey("When the request only contains the token in a cookie", func() {
+			request.AddCookie(&http.Cookie{Name: "access_token", Value: "cookie-token"})
+			request.Header.Set("X-Florence-Token", "header-token")
+			token, ok := extractor.ExtractToken(request)
+
+			Convey("Then the cookie token is returned, as it takes precedence over the header", func() {
+				So(ok, ShouldBeTrue)
+				So(token, ShouldResemble, authorisation.Token{Value: "cookie-token", Source: "cookie:access_token"})
+			})
+		})
+
+		Convey("When the request only contains the token in the X-Florence-Token header", func() {
+			request.Header.Set("x-florence-token", "header-token")
+			token, ok := extractor.ExtractToken(request)
+
+			Convey("Then the header token is returned", func() {
+				So(ok, ShouldBeTrue)
+				So(token, ShouldResemble, authorisation.Token{Value: "header-token", Source: "header:X-Florence-Token"})
+			})
+		})
+
+		Convey("When the request does not contain a token", func() {
+			_, ok := extractor.ExtractToken(request)
+
+			Convey("Then no token is returned", func() {
+				So(ok, ShouldBeFalse)
+			})
+		})
+	})
+
+	Convey("Given an invalid token source", t, func() {
+		Convey("When NewTokenExtractorFromSources is called", func() {
+			extractor, err := authorisation.NewTokenExtractorFromSources([]string{"query:token"})
+
+			Convey("Then an error is returned", func() {
+				So(err, ShouldNotBeNil)
+				So(extractor, ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestMiddleware_WithTokenExtractor(t *testing.T) {
+	Convey("Given a middleware instance that reads the token from the access_token cookie", t, func() {
+		mockJWTParser := newMockJWTParser()
+		permissionsChecker := &mock.PermissionsCheckerMock{
+			HasPermissionFunc: func(_ context.Context, _ permsdk.EntityData, _ string, _ map[string]string) (bool, error) {
+				return true, nil
+			},
+		}
+		middleware := authorisation.NewMiddlewareFromDependencies(mockJWTParser, permissionsChecker, zebedeeIdentity, identityClient,
+			authorisation.WithTokenExtractor(authorisation.NewCookieExtractor("access_token")))
+		mockHandler := &mockHandler{calls: 0}
+		response := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, testURL, http.NoBody)
+		request.AddCookie(&http.Cookie{Name: "access_token", Value: trimmedToken})
+
+		Convey("When the middleware function is called", func() {
+			middleware.Require(permission, mockHandler.ServeHTTP)(response, request)
+
+			Convey("Then the token from the cookie is parsed", func() {
+				So(mockJWTParser.ParseCalls(), ShouldHaveLength, 1)
+				So(mockJWTParser.ParseCalls()[0].TokenString, ShouldEqual, trimmedToken)
+			})
+
+			Convey("Then the underlying HTTP handler is called", func() {
+				So(mockHandler.calls, ShouldEqual, 1)
+			})
+		})
+
+		Convey("When the token is only in the Authorization header", func() {
+			request = httptest.NewRequest(http.MethodGet, testURL, http.NoBody)
+			request.Header.Set("Authorization", authorisationtest.AdminJWTToken)
+			middleware.Require(permission, mockHandler.ServeHTTP)(response, request)
+
+			Convey("Then the request is rejected as it has no token", func() {
+				So(response.Code, ShouldEqual, http.StatusUnauthorized)
+				So(mockJWTParser.ParseCalls(), ShouldHaveLength, 0)
+			})
+		})
+	})
+}
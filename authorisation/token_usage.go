@@ -0,0 +1,218 @@
+package authorisation
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxTokenUsageEntries limits the memory used by the token usage report. Once the limit is reached, requests whose
+// caller, permission and route have not been seen before are counted in an overflow entry for their token type.
+const maxTokenUsageEntries = 10000
+
+// TokenUsage is the number of authenticated requests made by a caller using a particular type of token. The caller
+// is empty for users authenticated with a JWT, whose requests are counted together.
+type TokenUsage struct {
+	TokenType  TokenType `json:"token_type"`
+	Caller     string    `json:"caller"`
+	Permission string    `json:"permission"`
+	Route      string    `json:"route"`
+	Count      uint64    `json:"count"`
+	LastSeen   time.Time `json:"last_seen"`
+	// Overflow is true for the entry counting the requests made once the report was full, which have no caller,
+	// permission or route
+	Overflow bool `json:"overflow,omitempty"`
+}
+
+// RouteFunc returns the route of a request, used to group requests in the token usage report
+type RouteFunc func(req *http.Request) string
+
+// DefaultRoute returns the pattern matched by http.ServeMux, or the request method if there is no pattern. The path
+// is not used, as it would add an entry to the token usage report for every resource requested.
+func DefaultRoute(req *http.Request) string {
+	if req.Pattern != "" {
+		return req.Pattern
+	}
+	return req.Method
+}
+
+// tokenUsageKey identifies an entry in the token usage report
+type tokenUsageKey struct {
+	tokenType  TokenType
+	caller     string
+	permission string
+	route      string
+	overflow   bool
+}
+
+// tokenUsageTracker counts authenticated requests by token type, caller, permission and route
+type tokenUsageTracker struct {
+	mu      sync.Mutex
+	entries map[tokenUsageKey]*TokenUsage
+}
+
+func newTokenUsageTracker() *tokenUsageTracker {
+	return &tokenUsageTracker{
+		entries: make(map[tokenUsageKey]*TokenUsage),
+	}
+}
+
+// record counts a request made by the entity, returning the route it was counted against. This is empty if the
+// request was counted in the overflow entry, so that the routes in the token usage metric are bounded too.
+func (t *tokenUsageTracker) record(entity *Entity, permission, route string) string {
+	key := tokenUsageKey{
+		tokenType:  entity.TokenType,
+		caller:     reportCaller(entity),
+		permission: permission,
+		route:      route,
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	usage, ok := t.entries[key]
+	if !ok && len(t.entries) >= maxTokenUsageEntries {
+		key = tokenUsageKey{tokenType: entity.TokenType, overflow: true}
+		usage, ok = t.entries[key]
+	}
+	if !ok {
+		usage = &TokenUsage{
+			TokenType:  key.tokenType,
+			Caller:     key.caller,
+			Permission: key.permission,
+			Route:      key.route,
+			Overflow:   key.overflow,
+		}
+		t.entries[key] = usage
+	}
+	usage.Count++
+	usage.LastSeen = time.Now().UTC()
+	return key.route
+}
+
+// report returns a copy of the token usage entries, ordered by token type, caller, permission and route
+func (t *tokenUsageTracker) report() []TokenUsage {
+	t.mu.Lock()
+	report := make([]TokenUsage, 0, len(t.entries))
+	for _, usage := range t.entries {
+		report = append(report, *usage)
+	}
+	t.mu.Unlock()
+
+	sort.Slice(report, func(i, j int) bool {
+		a, b := report[i], report[j]
+		if a.TokenType != b.TokenType {
+			return a.TokenType < b.TokenType
+		}
+		if a.Caller != b.Caller {
+			return a.Caller < b.Caller
+		}
+		if a.Permission != b.Permission {
+			return a.Permission < b.Permission
+		}
+		if a.Route != b.Route {
+			return a.Route < b.Route
+		}
+		return !a.Overflow && b.Overflow
+	})
+	return report
+}
+
+// reportCaller returns the caller recorded in the token usage report. Zebedee callers and services are identified,
+// but users authenticated with a JWT are not, so that the report is not filled by them.
+func reportCaller(entity *Entity) string {
+	if isZebedeeToken(entity.TokenType) {
+		return entity.UserID()
+	}
+	return usageCaller(entity)
+}
+
+// usageCaller returns the caller recorded in the token usage metrics. Only services are identified, so that the
+// number of metric series is bounded and users' email addresses are not used as label values.
+func usageCaller(entity *Entity) string {
+	if _, ok := entity.ServiceID(); ok {
+		return entity.UserID()
+	}
+	return ""
+}
+
+// isZebedeeToken returns true for the old world Zebedee token types
+func isZebedeeToken(tokenType TokenType) bool {
+	return tokenType == TokenTypeZebedeeService || tokenType == TokenTypeZebedeeUser
+}
+
+// ZebedeeTokenAction is the action taken when a request is authenticated using a Zebedee service or user token
+type ZebedeeTokenAction string
+
+const (
+	// ZebedeeTokenAllow accepts Zebedee tokens
+	ZebedeeTokenAllow ZebedeeTokenAction = "allow"
+	// ZebedeeTokenWarn accepts Zebedee tokens, logging a deprecation warning
+	ZebedeeTokenWarn ZebedeeTokenAction = "warn"
+	// ZebedeeTokenReject rejects requests authenticated using a Zebedee token
+	ZebedeeTokenReject ZebedeeTokenAction = "reject"
+)
+
+// zebedeeTokenActionStrictness orders the actions, so that the strictest action can be used for a list of permissions
+var zebedeeTokenActionStrictness = map[ZebedeeTokenAction]int{
+	ZebedeeTokenAllow:  0,
+	ZebedeeTokenWarn:   1,
+	ZebedeeTokenReject: 2,
+}
+
+// AllPermissions is used in place of a permission to set the Zebedee token action for all permissions
+const AllPermissions = "*"
+
+// ParseZebedeeTokenAction returns the ZebedeeTokenAction with the given case-insensitive name
+func ParseZebedeeTokenAction(name string) (ZebedeeTokenAction, error) {
+	action := ZebedeeTokenAction(strings.ToLower(strings.TrimSpace(name)))
+	if _, ok := zebedeeTokenActionStrictness[action]; !ok {
+		return "", fmt.Errorf("invalid zebedee token action %q", name)
+	}
+	return action, nil
+}
+
+// parseZebedeeTokenActions parses a list of 'permission=action' entries, e.g. 'datasets:edit=reject' or '*=warn'
+func parseZebedeeTokenActions(entries []string) (map[string]ZebedeeTokenAction, error) {
+	actions := make(map[string]ZebedeeTokenAction, len(entries))
+	for _, entry := range entries {
+		permission, name, found := strings.Cut(entry, "=")
+		permission = strings.TrimSpace(permission)
+		if !found || permission == "" {
+			return nil, fmt.Errorf("invalid zebedee token action %q, expected 'permission=action'", entry)
+		}
+		action, err := ParseZebedeeTokenAction(name)
+		if err != nil {
+			return nil, err
+		}
+		actions[permission] = action
+	}
+	return actions, nil
+}
+
+// zebedeeTokenActionFor returns the action taken when the given permissions are requested using a Zebedee token.
+// If the permissions have different actions, the strictest is used.
+func (m PermissionCheckMiddleware) zebedeeTokenActionFor(permissions []string) ZebedeeTokenAction {
+	defaultAction, ok := m.zebedeeTokenActions[AllPermissions]
+	if !ok {
+		defaultAction = ZebedeeTokenAllow
+	}
+
+	var action ZebedeeTokenAction
+	for _, permission := range permissions {
+		permissionAction, ok := m.zebedeeTokenActions[permission]
+		if !ok {
+			permissionAction = defaultAction
+		}
+		if action == "" || zebedeeTokenActionStrictness[permissionAction] > zebedeeTokenActionStrictness[action] {
+			action = permissionAction
+		}
+	}
+	if action == "" {
+		return defaultAction
+	}
+	return action
+}
@@ -0,0 +1,259 @@
+package authorisation_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ONSdigital/dp-authorisation/v2/authorisation"
+	"github.com/ONSdigital/dp-authorisation/v2/authorisation/mock"
+	"github.com/ONSdigital/dp-authorisation/v2/authorisationtest"
+	"github.com/ONSdigital/dp-authorisation/v2/metrics"
+	dprequest "github.com/ONSdigital/dp-net/v3/request"
+	permsdk "github.com/ONSdigital/dp-permissions-api/sdk"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func newServiceZebedeeClient() *mock.ZebedeeClientMock {
+	return &mock.ZebedeeClientMock{
+		CheckTokenIdentityFunc: func(ctx context.Context, token string) (*dprequest.IdentityResponse, error) {
+			return &dprequest.IdentityResponse{Identifier: "bilbo.baggins@bilbo-baggins.io"}, nil
+		},
+	}
+}
+
+func newAllowingPermissionsChecker() *mock.PermissionsCheckerMock {
+	return &mock.PermissionsCheckerMock{
+		HasPermissionFunc: func(ctx context.Context, entityData permsdk.EntityData, permission string, attributes map[string]string) (bool, error) {
+			return true, nil
+		},
+	}
+}
+
+func TestMiddleware_TokenUsageReport(t *testing.T) {
+	Convey("Given a middleware that allows every request", t, func() {
+		middleware := authorisation.NewMiddlewareFromDependencies(newMockJWTParser(), newAllowingPermissionsChecker(), newServiceZebedeeClient(), identityClient)
+		handler := &mockHandler{}
+		middlewareFunc := middleware.Require(permission, handler.ServeHTTP)
+
+		Convey("When requests are made using a Zebedee service token and a JWT", func() {
+			for _, token := range []string{authorisationtest.ZebedeeServiceToken, authorisationtest.ZebedeeServiceToken, authorisationtest.AdminJWTToken} {
+				request := httptest.NewRequest(http.MethodGet, "/datasets", http.NoBody)
+				request.Header.Set("Authorization", token)
+				middlewareFunc(httptest.NewRecorder(), request)
+			}
+
+			Convey("Then the report counts the requests by token type, caller, permission and route", func() {
+				report := middleware.TokenUsageReport()
+				So(report, ShouldHaveLength, 2)
+
+				So(report[0].TokenType, ShouldEqual, authorisation.TokenTypeJWT)
+				So(report[0].Caller, ShouldBeEmpty)
+				So(report[0].Count, ShouldEqual, 1)
+
+				So(report[1].TokenType, ShouldEqual, authorisation.TokenTypeZebedeeService)
+				So(report[1].Caller, ShouldEqual, "services/bilbo.baggins@bilbo-baggins.io")
+				So(report[1].Permission, ShouldEqual, permission)
+				So(report[1].Route, ShouldEqual, http.MethodGet)
+				So(report[1].Count, ShouldEqual, 2)
+				So(report[1].LastSeen.IsZero(), ShouldBeFalse)
+			})
+		})
+	})
+
+	Convey("Given a middleware with a route function", t, func() {
+		middleware := authorisation.NewMiddlewareFromDependencies(newMockJWTParser(), newAllowingPermissionsChecker(), newServiceZebedeeClient(), identityClient,
+			authorisation.WithRouteFunc(func(req *http.Request) string { return "/datasets/{id}" }))
+		handler := &mockHandler{}
+
+		Convey("When requests are made for different resources", func() {
+			for _, url := range []string{"/datasets/cpih", "/datasets/cpi"} {
+				request := httptest.NewRequest(http.MethodGet, url, http.NoBody)
+				request.Header.Set("Authorization", authorisationtest.ZebedeeServiceToken)
+				middleware.Require(permission, handler.ServeHTTP)(httptest.NewRecorder(), request)
+			}
+
+			Convey("Then the requests are grouped by the route", func() {
+				report := middleware.TokenUsageReport()
+				So(report, ShouldHaveLength, 1)
+				So(report[0].Route, ShouldEqual, "/datasets/{id}")
+				So(report[0].Count, ShouldEqual, 2)
+			})
+		})
+	})
+}
+
+func TestMiddleware_TokenUsageReport_Callers(t *testing.T) {
+	Convey("Given a middleware with metrics that accepts Zebedee user tokens", t, func() {
+		registry := prometheus.NewRegistry()
+		authMetrics, err := metrics.New(registry)
+		So(err, ShouldBeNil)
+		zebedeeClient := &mock.ZebedeeClientMock{
+			CheckTokenIdentityFunc: func(ctx context.Context, token string) (*dprequest.IdentityResponse, error) {
+				return nil, errors.New("unauthorised")
+			},
+			CheckUserTokenIdentityFunc: func(ctx context.Context, token string) (*dprequest.IdentityResponse, error) {
+				return &dprequest.IdentityResponse{Identifier: "publisher@ons.gov.uk"}, nil
+			},
+		}
+		middleware := authorisation.NewMiddlewareFromDependencies(newMockJWTParser(), newAllowingPermissionsChecker(), zebedeeClient, identityClient,
+			authorisation.WithZebedeeUserTokenFallback(true), authorisation.WithMetrics(authMetrics))
+		handler := &mockHandler{}
+
+		Convey("When a request is made using a Zebedee user token", func() {
+			request := httptest.NewRequest(http.MethodGet, "/datasets", http.NoBody)
+			request.Header.Set("Authorization", "florence-token")
+			middleware.Require(permission, handler.ServeHTTP)(httptest.NewRecorder(), request)
+
+			Convey("Then the user is identified in the report", func() {
+				report := middleware.TokenUsageReport()
+				So(report, ShouldHaveLength, 1)
+				So(report[0].TokenType, ShouldEqual, authorisation.TokenTypeZebedeeUser)
+				So(report[0].Caller, ShouldEqual, "publisher@ons.gov.uk")
+			})
+
+			Convey("Then the user is not used as the caller label of the metric", func() {
+				expected := `
+# HELP dp_authorisation_token_usage_total Requests authenticated by the middleware, by token type, caller, permission and route. The caller is only set for services.
+# TYPE dp_authorisation_token_usage_total counter
+dp_authorisation_token_usage_total{caller="",permission="dataset.read",route="GET",token_type="zebedee_user"} 1
+`
+				So(testutil.GatherAndCompare(registry, strings.NewReader(expected), "dp_authorisation_token_usage_total"), ShouldBeNil)
+			})
+		})
+	})
+
+	Convey("Given a middleware whose token usage report is full", t, func() {
+		registry := prometheus.NewRegistry()
+		authMetrics, err := metrics.New(registry)
+		So(err, ShouldBeNil)
+		var route int
+		middleware := authorisation.NewMiddlewareFromDependencies(newMockJWTParser(), newAllowingPermissionsChecker(), newServiceZebedeeClient(), identityClient,
+			authorisation.WithMetrics(authMetrics),
+			authorisation.WithRouteFunc(func(req *http.Request) string {
+				route++
+				return strconv.Itoa(route)
+			}))
+		handler := &mockHandler{}
+		middlewareFunc := middleware.Require(permission, handler.ServeHTTP)
+		for range 10000 {
+			request := httptest.NewRequest(http.MethodGet, testURL, http.NoBody)
+			request.Header.Set("Authorization", authorisationtest.ZebedeeServiceToken)
+			middlewareFunc(httptest.NewRecorder(), request)
+		}
+
+		Convey("When requests are made for new routes", func() {
+			for range 2 {
+				request := httptest.NewRequest(http.MethodGet, testURL, http.NoBody)
+				request.Header.Set("Authorization", authorisationtest.ZebedeeServiceToken)
+				middlewareFunc(httptest.NewRecorder(), request)
+			}
+
+			Convey("Then they are counted in an overflow entry for the token type", func() {
+				report := middleware.TokenUsageReport()
+				So(report, ShouldHaveLength, 10001)
+				overflow := report[0]
+				So(overflow.Overflow, ShouldBeTrue)
+				So(overflow.TokenType, ShouldEqual, authorisation.TokenTypeZebedeeService)
+				So(overflow.Caller, ShouldBeEmpty)
+				So(overflow.Count, ShouldEqual, 2)
+			})
+
+			Convey("Then they are counted in the metric without a route", func() {
+				count, err := testutil.GatherAndCount(registry, "dp_authorisation_token_usage_total")
+				So(err, ShouldBeNil)
+				So(count, ShouldEqual, 10001)
+
+				families, err := registry.Gather()
+				So(err, ShouldBeNil)
+				var overflowCount float64
+				for _, family := range families {
+					for _, metric := range family.GetMetric() {
+						for _, label := range metric.GetLabel() {
+							if label.GetName() == "route" && label.GetValue() == "" {
+								overflowCount = metric.GetCounter().GetValue()
+							}
+						}
+					}
+				}
+				So(overflowCount, ShouldEqual, 2)
+			})
+		})
+	})
+}
+
+func TestMiddleware_WithZebedeeTokenActions(t *testing.T) {
+	Convey("Given a middleware that rejects Zebedee tokens for one permission and warns for the rest", t, func() {
+		middleware := authorisation.NewMiddlewareFromDependencies(newMockJWTParser(), newAllowingPermissionsChecker(), newServiceZebedeeClient(), identityClient,
+			authorisation.WithZebedeeTokenActions(map[string]authorisation.ZebedeeTokenAction{
+				authorisation.AllPermissions: authorisation.ZebedeeTokenWarn,
+				"datasets:publish":           authorisation.ZebedeeTokenReject,
+			}))
+		handler := &mockHandler{}
+
+		Convey("When the rejected permission is requested using a Zebedee token", func() {
+			response := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, testURL, http.NoBody)
+			request.Header.Set("Authorization", authorisationtest.ZebedeeServiceToken)
+			middleware.Require("datasets:publish", handler.ServeHTTP)(response, request)
+
+			Convey("Then the request is rejected with the token_deprecated code", func() {
+				So(handler.calls, ShouldEqual, 0)
+				So(response.Code, ShouldEqual, http.StatusUnauthorized)
+				var problem authorisation.Problem
+				So(json.Unmarshal(response.Body.Bytes(), &problem), ShouldBeNil)
+				So(problem.Code, ShouldEqual, authorisation.ErrorCodeTokenDeprecated)
+			})
+
+			Convey("Then the request is still included in the token usage report", func() {
+				So(middleware.TokenUsageReport(), ShouldHaveLength, 1)
+			})
+		})
+
+		Convey("When the rejected permission is requested using a JWT", func() {
+			response := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, testURL, http.NoBody)
+			request.Header.Set("Authorization", authorisationtest.AdminJWTToken)
+			middleware.Require("datasets:publish", handler.ServeHTTP)(response, request)
+
+			Convey("Then the request is allowed", func() {
+				So(handler.calls, ShouldEqual, 1)
+			})
+		})
+
+		Convey("When another permission is requested using a Zebedee token", func() {
+			response := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, testURL, http.NoBody)
+			request.Header.Set("Authorization", authorisationtest.ZebedeeServiceToken)
+			middleware.Require(permission, handler.ServeHTTP)(response, request)
+
+			Convey("Then the request is allowed", func() {
+				So(handler.calls, ShouldEqual, 1)
+			})
+		})
+	})
+}
+
+func TestParseZebedeeTokenAction(t *testing.T) {
+	Convey("Given valid action names", t, func() {
+		Convey("Then they are parsed case-insensitively", func() {
+			action, err := authorisation.ParseZebedeeTokenAction(" Reject ")
+			So(err, ShouldBeNil)
+			So(action, ShouldEqual, authorisation.ZebedeeTokenReject)
+		})
+	})
+
+	Convey("Given an invalid action name", t, func() {
+		Convey("Then an error is returned", func() {
+			_, err := authorisation.ParseZebedeeTokenAction("block")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
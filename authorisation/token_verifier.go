@@ -0,0 +1,198 @@
+package authorisation
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/dp-authorisation/v2/jwt"
+	"github.com/ONSdigital/dp-authorisation/v2/metrics"
+	"github.com/ONSdigital/dp-authorisation/v2/permissions"
+	permsdk "github.com/ONSdigital/dp-permissions-api/sdk"
+)
+
+// errReservedUserID is returned when a user ID could be mistaken for a service entity ID
+var errReservedUserID = errors.New("user id is reserved for services")
+
+// Compiler checks to ensure the built in verifiers implement the TokenVerifier interface.
+var (
+	_ TokenVerifier = (*JWTVerifier)(nil)
+	_ TokenVerifier = (*ZebedeeVerifier)(nil)
+)
+
+// defaultTokenVerifiers returns the verifiers for the token types supported by the middleware dependencies
+func defaultTokenVerifiers(m *PermissionCheckMiddleware) []TokenVerifier {
+	var verifiers []TokenVerifier
+	if m.jwtParser != nil {
+		jwtVerifier := NewJWTVerifier(m.jwtParser)
+		jwtVerifier.Metrics = m.metrics
+		verifiers = append(verifiers, jwtVerifier)
+	}
+	if m.zebedeeClient != nil {
+		zebedeeVerifier := NewZebedeeVerifier(m.zebedeeClient)
+		if m.zebedeeUserTokenSources != nil {
+			zebedeeVerifier.UserTokenSources = m.zebedeeUserTokenSources
+		}
+		zebedeeVerifier.UserTokenFallback = m.zebedeeUserTokenFallback
+		verifiers = append(verifiers, zebedeeVerifier)
+	}
+	return verifiers
+}
+
+// JWTVerifier is a TokenVerifier for JWT access tokens, e.g. those issued by AWS Cognito.
+// If Metrics is set, the parse latency and failure reasons are recorded.
+type JWTVerifier struct {
+	Metrics *metrics.Metrics
+	parser  JWTParser
+}
+
+// NewJWTVerifier creates a new instance of JWTVerifier that verifies tokens using the given parser
+func NewJWTVerifier(parser JWTParser) *JWTVerifier {
+	return &JWTVerifier{
+		parser: parser,
+	}
+}
+
+// CanHandle returns true if the token has the structure of a JWT - three base64url encoded segments,
+// the first of which is a JSON header
+func (v *JWTVerifier) CanHandle(token Token) bool {
+	segments := strings.Split(token.Value, ".")
+	if len(segments) != 3 {
+		return false
+	}
+
+	headerBytes, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(segments[0], "="))
+	if err != nil {
+		return false
+	}
+
+	var header map[string]interface{}
+	return json.Unmarshal(headerBytes, &header) == nil
+}
+
+// Verify parses and verifies the JWT, returning the entity it identifies
+func (v *JWTVerifier) Verify(_ context.Context, token Token) (*Entity, error) {
+	start := time.Now()
+	result, err := v.parse(token.Value)
+	if err != nil {
+		authErr := newJWTError(err)
+		v.Metrics.ObserveJWTParse(time.Since(start), string(authErr.Code))
+		return nil, authErr
+	}
+	v.Metrics.ObserveJWTParse(time.Since(start), "")
+
+	if result.ClientID != "" {
+		entity := NewServiceEntity(result.ClientID, TokenTypeJWT)
+		entity.Claims = result.Claims
+		return entity, nil
+	}
+	if err = checkUserID(result.EntityData.UserID); err != nil {
+		return nil, NewError(ErrorCodeInvalidClaims, err)
+	}
+
+	return &Entity{
+		EntityData: result.EntityData,
+		TokenType:  TokenTypeJWT,
+		Claims:     result.Claims,
+	}, nil
+}
+
+// parse verifies the token, returning its claims along with the entity data. The claims of a parser that does not
+// return them are decoded from the token once it has been verified.
+func (v *JWTVerifier) parse(token string) (*jwt.ParseResult, error) {
+	if claimsParser, ok := v.parser.(JWTClaimsParser); ok {
+		return claimsParser.ParseWithClaims(token)
+	}
+
+	// the claims of a token are only read from a parser that has verified them
+	entityData, err := v.parser.Parse(token)
+	if err != nil {
+		return nil, err
+	}
+	return &jwt.ParseResult{EntityData: *entityData}, nil
+}
+
+// DefaultZebedeeUserTokenSources are the token sources that Florence sends user tokens from
+var DefaultZebedeeUserTokenSources = []string{"header:X-Florence-Token", "cookie:access_token"}
+
+// ZebedeeVerifier is a TokenVerifier for old world Zebedee service and Florence user tokens.
+//   - UserTokenSources - tokens read from these sources, e.g. 'header:X-Florence-Token', are checked as user tokens
+//   - UserTokenFallback - if true, a token from another source that is not a valid service token is checked as a user token
+//
+// Zebedee only returns the identifier of a user, so entities for user tokens do not include any groups.
+type ZebedeeVerifier struct {
+	UserTokenSources  []string
+	UserTokenFallback bool
+	client            ZebedeeClient
+}
+
+// NewZebedeeVerifier creates a new instance of ZebedeeVerifier that verifies tokens using the given Zebedee client
+func NewZebedeeVerifier(client ZebedeeClient) *ZebedeeVerifier {
+	return &ZebedeeVerifier{
+		UserTokenSources: DefaultZebedeeUserTokenSources,
+		client:           client,
+	}
+}
+
+// CanHandle returns true if the token is an opaque token, rather than a structured token such as a JWT
+func (v *ZebedeeVerifier) CanHandle(token Token) bool {
+	return token.Value != "" && !strings.Contains(token.Value, ".")
+}
+
+// Verify checks the token identity with Zebedee, returning the entity it identifies
+func (v *ZebedeeVerifier) Verify(ctx context.Context, token Token) (*Entity, error) {
+	if v.isUserTokenSource(token.Source) {
+		return v.verifyUserToken(ctx, token)
+	}
+
+	identityResponse, err := v.client.CheckTokenIdentity(ctx, token.Value)
+	if err != nil {
+		if v.UserTokenFallback {
+			if entity, userErr := v.verifyUserToken(ctx, token); userErr == nil {
+				return entity, nil
+			}
+		}
+		return nil, NewError(ErrorCodeInvalidServiceToken, err)
+	}
+
+	return NewServiceEntity(identityResponse.Identifier, TokenTypeZebedeeService), nil
+}
+
+// verifyUserToken checks the token identity with Zebedee as a Florence user token
+func (v *ZebedeeVerifier) verifyUserToken(ctx context.Context, token Token) (*Entity, error) {
+	identityResponse, err := v.client.CheckUserTokenIdentity(ctx, token.Value)
+	if err != nil {
+		return nil, NewError(ErrorCodeInvalidUserToken, err)
+	}
+	if err = checkUserID(identityResponse.Identifier); err != nil {
+		return nil, NewError(ErrorCodeInvalidUserToken, err)
+	}
+
+	return &Entity{
+		EntityData: permsdk.EntityData{UserID: identityResponse.Identifier},
+		TokenType:  TokenTypeZebedeeUser,
+	}, nil
+}
+
+// checkUserID returns an error if the given user ID is a service entity ID. Services are only identified by the
+// verifiers that authenticate them, but a user ID that looks like a service would be misleading in logs and audit
+// records.
+func checkUserID(userID string) error {
+	if strings.HasPrefix(userID, permissions.ServiceEntityPrefix) {
+		return errReservedUserID
+	}
+	return nil
+}
+
+// isUserTokenSource returns true if tokens from the given source are user tokens
+func (v *ZebedeeVerifier) isUserTokenSource(source string) bool {
+	for _, userTokenSource := range v.UserTokenSources {
+		if strings.EqualFold(source, userTokenSource) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,341 @@
+package authorisation_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ONSdigital/dp-authorisation/v2/authorisation"
+	"github.com/ONSdigital/dp-authorisation/v2/authorisation/mock"
+	"github.com/ONSdigital/dp-authorisation/v2/authorisationtest"
+	"github.com/ONSdigital/dp-authorisation/v2/jwt"
+	dprequest "github.com/ONSdigital/dp-net/v3/request"
+	permsdk "github.com/ONSdigital/dp-permissions-api/sdk"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestJWTVerifier_CanHandle(t *testing.T) {
+	Convey("Given a JWT verifier", t, func() {
+		verifier := authorisation.NewJWTVerifier(newMockJWTParser())
+
+		Convey("Then it can handle a JWT", func() {
+			So(verifier.CanHandle(authorisation.Token{Value: trimmedToken}), ShouldBeTrue)
+		})
+
+		Convey("Then it cannot handle a Zebedee service token", func() {
+			So(verifier.CanHandle(authorisation.Token{Value: "bdd5ad2aa7f1c0e54bd6d1b9c0a5d3b0"}), ShouldBeFalse)
+		})
+
+		Convey("Then it cannot handle a dotted token that does not have a JWT header", func() {
+			So(verifier.CanHandle(authorisation.Token{Value: "first.last@ons.gov.uk"}), ShouldBeFalse)
+			So(verifier.CanHandle(authorisation.Token{Value: "abc.def.ghi"}), ShouldBeFalse)
+		})
+	})
+}
+
+func TestJWTVerifier_Verify(t *testing.T) {
+	Convey("Given a JWT verifier with a parser that succeeds", t, func() {
+		verifier := authorisation.NewJWTVerifier(newMockJWTParser())
+
+		Convey("When a JWT is verified", func() {
+			entity, err := verifier.Verify(context.Background(), authorisation.Token{Value: trimmedToken})
+
+			Convey("Then the entity is returned with the token type, but without claims as the parser does not return them", func() {
+				So(err, ShouldBeNil)
+				So(entity.EntityData, ShouldResemble, *dummyEntityData)
+				So(entity.TokenType, ShouldEqual, authorisation.TokenTypeJWT)
+				So(entity.Claims, ShouldBeNil)
+			})
+		})
+	})
+
+	Convey("Given a JWT verifier with a parser that returns the verified claims", t, func() {
+		parser := &mock.JWTClaimsParserMock{
+			ParseWithClaimsFunc: func(tokenString string) (*jwt.ParseResult, error) {
+				return &jwt.ParseResult{
+					EntityData: *dummyEntityData,
+					Claims:     jwt.Claims{"email": "fred@ons.gov.uk"},
+				}, nil
+			},
+		}
+		verifier := authorisation.NewJWTVerifier(parser)
+
+		Convey("When a JWT is verified", func() {
+			entity, err := verifier.Verify(context.Background(), authorisation.Token{Value: trimmedToken})
+
+			Convey("Then the entity has the claims returned by the parser", func() {
+				So(err, ShouldBeNil)
+				So(entity.EntityData, ShouldResemble, *dummyEntityData)
+				So(entity.Claims.Email(), ShouldEqual, "fred@ons.gov.uk")
+				So(parser.ParseCalls(), ShouldBeEmpty)
+			})
+		})
+	})
+
+	Convey("Given a JWT verifier with a parser that returns the client ID of a client credentials token", t, func() {
+		verifier := authorisation.NewJWTVerifier(&mock.JWTClaimsParserMock{
+			ParseWithClaimsFunc: func(tokenString string) (*jwt.ParseResult, error) {
+				return &jwt.ParseResult{ClientID: "dp-dataset-api", Claims: jwt.Claims{"client_id": "dp-dataset-api"}}, nil
+			},
+		})
+
+		Convey("When a JWT is verified", func() {
+			entity, err := verifier.Verify(context.Background(), authorisation.Token{Value: trimmedToken})
+
+			Convey("Then the service entity for the client is returned", func() {
+				So(err, ShouldBeNil)
+				So(entity.UserID(), ShouldEqual, "services/dp-dataset-api")
+				So(entity.TokenType, ShouldEqual, authorisation.TokenTypeJWT)
+				So(entity.Claims.ClientID(), ShouldEqual, "dp-dataset-api")
+				serviceID, ok := entity.ServiceID()
+				So(ok, ShouldBeTrue)
+				So(serviceID, ShouldEqual, "dp-dataset-api")
+			})
+		})
+	})
+
+	Convey("Given a JWT verifier with a parser that returns a user ID that looks like a service", t, func() {
+		verifier := authorisation.NewJWTVerifier(&mock.JWTClaimsParserMock{
+			ParseWithClaimsFunc: func(tokenString string) (*jwt.ParseResult, error) {
+				return &jwt.ParseResult{EntityData: permsdk.EntityData{UserID: "services/dp-dataset-api"}}, nil
+			},
+		})
+
+		Convey("When a JWT is verified", func() {
+			entity, err := verifier.Verify(context.Background(), authorisation.Token{Value: trimmedToken})
+
+			Convey("Then the token is rejected as having invalid claims", func() {
+				So(entity, ShouldBeNil)
+				var authErr *authorisation.Error
+				So(errors.As(err, &authErr), ShouldBeTrue)
+				So(authErr.Code, ShouldEqual, authorisation.ErrorCodeInvalidClaims)
+			})
+		})
+	})
+
+	Convey("Given a JWT verifier with a parser that returns an error", t, func() {
+		verifier := authorisation.NewJWTVerifier(&mock.JWTParserMock{
+			ParseFunc: func(tokenString string) (*permsdk.EntityData, error) {
+				return nil, jwt.ErrTokenExpired
+			},
+		})
+
+		Convey("When a JWT is verified", func() {
+			entity, err := verifier.Verify(context.Background(), authorisation.Token{Value: trimmedToken})
+
+			Convey("Then the error is mapped to an authorisation error", func() {
+				So(entity, ShouldBeNil)
+				var authErr *authorisation.Error
+				So(errors.As(err, &authErr), ShouldBeTrue)
+				So(authErr.Code, ShouldEqual, authorisation.ErrorCodeTokenExpired)
+			})
+		})
+	})
+}
+
+func TestZebedeeVerifier(t *testing.T) {
+	Convey("Given a Zebedee verifier", t, func() {
+		zebedeeClient := &mock.ZebedeeClientMock{
+			CheckTokenIdentityFunc: func(ctx context.Context, token string) (*dprequest.IdentityResponse, error) {
+				return &dprequest.IdentityResponse{Identifier: "bilbo.baggins@bilbo-baggins.io"}, nil
+			},
+		}
+		verifier := authorisation.NewZebedeeVerifier(zebedeeClient)
+
+		Convey("Then it can handle an opaque token but not a JWT", func() {
+			So(verifier.CanHandle(authorisation.Token{Value: "bdd5ad2aa7f1c0e54bd6d1b9c0a5d3b0"}), ShouldBeTrue)
+			So(verifier.CanHandle(authorisation.Token{Value: trimmedToken}), ShouldBeFalse)
+		})
+
+		Convey("When a token is verified", func() {
+			entity, err := verifier.Verify(context.Background(), authorisation.Token{Value: "bdd5ad2aa7f1c0e54bd6d1b9c0a5d3b0"})
+
+			Convey("Then the service entity identified by Zebedee is returned", func() {
+				So(err, ShouldBeNil)
+				So(entity.UserID(), ShouldEqual, "services/bilbo.baggins@bilbo-baggins.io")
+				So(entity.TokenType, ShouldEqual, authorisation.TokenTypeZebedeeService)
+				serviceID, ok := entity.ServiceID()
+				So(ok, ShouldBeTrue)
+				So(serviceID, ShouldEqual, "bilbo.baggins@bilbo-baggins.io")
+			})
+		})
+
+		Convey("When Zebedee cannot verify the token", func() {
+			zebedeeClient.CheckTokenIdentityFunc = func(ctx context.Context, token string) (*dprequest.IdentityResponse, error) {
+				return nil, errors.New("unauthorised")
+			}
+			_, err := verifier.Verify(context.Background(), authorisation.Token{Value: "bdd5ad2aa7f1c0e54bd6d1b9c0a5d3b0"})
+
+			Convey("Then an invalid service token error is returned", func() {
+				var authErr *authorisation.Error
+				So(errors.As(err, &authErr), ShouldBeTrue)
+				So(authErr.Code, ShouldEqual, authorisation.ErrorCodeInvalidServiceToken)
+			})
+		})
+	})
+}
+
+func TestZebedeeVerifier_UserTokens(t *testing.T) {
+	Convey("Given a Zebedee verifier and a client that only identifies user tokens", t, func() {
+		zebedeeClient := &mock.ZebedeeClientMock{
+			CheckTokenIdentityFunc: func(ctx context.Context, token string) (*dprequest.IdentityResponse, error) {
+				return nil, errors.New("unauthorised")
+			},
+			CheckUserTokenIdentityFunc: func(ctx context.Context, token string) (*dprequest.IdentityResponse, error) {
+				if token != "florence-token" {
+					return nil, errors.New("unauthorised")
+				}
+				return &dprequest.IdentityResponse{Identifier: "publisher@ons.gov.uk"}, nil
+			},
+		}
+		verifier := authorisation.NewZebedeeVerifier(zebedeeClient)
+
+		Convey("When a token from the X-Florence-Token header is verified", func() {
+			entity, err := verifier.Verify(context.Background(), authorisation.Token{Value: "florence-token", Source: "header:X-Florence-Token"})
+
+			Convey("Then it is checked as a user token and the user entity is returned", func() {
+				So(err, ShouldBeNil)
+				So(entity.UserID(), ShouldEqual, "publisher@ons.gov.uk")
+				So(entity.TokenType, ShouldEqual, authorisation.TokenTypeZebedeeUser)
+				So(zebedeeClient.CheckTokenIdentityCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When a user token identifying a user whose ID looks like a service is verified", func() {
+			zebedeeClient.CheckUserTokenIdentityFunc = func(ctx context.Context, token string) (*dprequest.IdentityResponse, error) {
+				return &dprequest.IdentityResponse{Identifier: "services/dp-dataset-api"}, nil
+			}
+			entity, err := verifier.Verify(context.Background(), authorisation.Token{Value: "florence-token", Source: "header:X-Florence-Token"})
+
+			Convey("Then an invalid user token error is returned", func() {
+				So(entity, ShouldBeNil)
+				var authErr *authorisation.Error
+				So(errors.As(err, &authErr), ShouldBeTrue)
+				So(authErr.Code, ShouldEqual, authorisation.ErrorCodeInvalidUserToken)
+			})
+		})
+
+		Convey("When an invalid token from the X-Florence-Token header is verified", func() {
+			_, err := verifier.Verify(context.Background(), authorisation.Token{Value: "expired-token", Source: "header:X-Florence-Token"})
+
+			Convey("Then an invalid user token error is returned", func() {
+				var authErr *authorisation.Error
+				So(errors.As(err, &authErr), ShouldBeTrue)
+				So(authErr.Code, ShouldEqual, authorisation.ErrorCodeInvalidUserToken)
+			})
+		})
+
+		Convey("When a user token from the Authorization header is verified without the fallback enabled", func() {
+			_, err := verifier.Verify(context.Background(), authorisation.Token{Value: "florence-token", Source: authorisation.TokenSourceAuthorization})
+
+			Convey("Then an invalid service token error is returned", func() {
+				var authErr *authorisation.Error
+				So(errors.As(err, &authErr), ShouldBeTrue)
+				So(authErr.Code, ShouldEqual, authorisation.ErrorCodeInvalidServiceToken)
+				So(zebedeeClient.CheckUserTokenIdentityCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When the user token fallback is enabled", func() {
+			verifier.UserTokenFallback = true
+
+			Convey("And a user token from the Authorization header is verified", func() {
+				entity, err := verifier.Verify(context.Background(), authorisation.Token{Value: "florence-token", Source: authorisation.TokenSourceAuthorization})
+
+				Convey("Then the user entity is returned", func() {
+					So(err, ShouldBeNil)
+					So(entity.UserID(), ShouldEqual, "publisher@ons.gov.uk")
+					So(entity.TokenType, ShouldEqual, authorisation.TokenTypeZebedeeUser)
+				})
+			})
+
+			Convey("And a token that is neither a service nor a user token is verified", func() {
+				_, err := verifier.Verify(context.Background(), authorisation.Token{Value: "unknown-token", Source: authorisation.TokenSourceAuthorization})
+
+				Convey("Then an invalid service token error is returned", func() {
+					var authErr *authorisation.Error
+					So(errors.As(err, &authErr), ShouldBeTrue)
+					So(authErr.Code, ShouldEqual, authorisation.ErrorCodeInvalidServiceToken)
+				})
+			})
+		})
+	})
+}
+
+func TestMiddleware_WithTokenVerifiers(t *testing.T) {
+	Convey("Given a middleware with custom token verifiers", t, func() {
+		apiKeyEntity := &authorisation.Entity{
+			EntityData: permsdk.EntityData{UserID: "api-key-user"},
+			TokenType:  authorisation.TokenType("api_key"),
+		}
+		skippedVerifier := &mock.TokenVerifierMock{
+			CanHandleFunc: func(token authorisation.Token) bool { return false },
+		}
+		apiKeyVerifier := &mock.TokenVerifierMock{
+			CanHandleFunc: func(token authorisation.Token) bool { return true },
+			VerifyFunc: func(ctx context.Context, token authorisation.Token) (*authorisation.Entity, error) {
+				return apiKeyEntity, nil
+			},
+		}
+		permissionsChecker := &mock.PermissionsCheckerMock{
+			HasPermissionFunc: func(ctx context.Context, entityData permsdk.EntityData, permission string, attributes map[string]string) (bool, error) {
+				return true, nil
+			},
+		}
+		jwtParser := newMockJWTParser()
+		middleware := authorisation.NewMiddlewareFromDependencies(jwtParser, permissionsChecker, zebedeeIdentity, identityClient,
+			authorisation.WithTokenVerifiers(skippedVerifier, apiKeyVerifier))
+
+		response := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, testURL, http.NoBody)
+		request.Header.Set("Authorization", authorisationtest.AdminJWTToken)
+		mockHandler := &mockHandler{calls: 0}
+
+		Convey("When the middleware function is called", func() {
+			middleware.Require(permission, mockHandler.ServeHTTP)(response, request)
+
+			Convey("Then the first verifier that can handle the token is used", func() {
+				So(skippedVerifier.CanHandleCalls(), ShouldHaveLength, 1)
+				So(skippedVerifier.VerifyCalls(), ShouldHaveLength, 0)
+				So(apiKeyVerifier.VerifyCalls(), ShouldHaveLength, 1)
+				So(apiKeyVerifier.VerifyCalls()[0].Token.Value, ShouldEqual, trimmedToken)
+				So(jwtParser.ParseCalls(), ShouldHaveLength, 0)
+			})
+
+			Convey("Then the permissions check uses the verified entity", func() {
+				So(permissionsChecker.HasPermissionCalls()[0].EntityData, ShouldResemble, apiKeyEntity.EntityData)
+			})
+
+			Convey("Then the handler is called with the verified entity", func() {
+				So(mockHandler.calls, ShouldEqual, 1)
+				entity, ok := authorisation.EntityFromContext(mockHandler.request.Context())
+				So(ok, ShouldBeTrue)
+				So(entity, ShouldEqual, apiKeyEntity)
+			})
+		})
+	})
+
+	Convey("Given a middleware with no verifier that can handle the token", t, func() {
+		middleware := authorisation.NewMiddlewareFromDependencies(newMockJWTParser(), &mock.PermissionsCheckerMock{}, zebedeeIdentity, identityClient,
+			authorisation.WithTokenVerifiers(&mock.TokenVerifierMock{
+				CanHandleFunc: func(token authorisation.Token) bool { return false },
+			}))
+
+		response := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, testURL, http.NoBody)
+		request.Header.Set("Authorization", authorisationtest.AdminJWTToken)
+		mockHandler := &mockHandler{calls: 0}
+
+		Convey("When the middleware function is called", func() {
+			middleware.Require(permission, mockHandler.ServeHTTP)(response, request)
+
+			Convey("Then the request is rejected as an unsupported token", func() {
+				So(mockHandler.calls, ShouldEqual, 0)
+				So(response.Code, ShouldEqual, http.StatusUnauthorized)
+				So(response.Body.String(), ShouldContainSubstring, `"code":"unsupported_token"`)
+			})
+		})
+	})
+}
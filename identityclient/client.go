@@ -6,13 +6,18 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"slices"
+	"sync"
 	"time"
 
+	"github.com/ONSdigital/dp-authorisation/v2/internal/tracing"
 	"github.com/ONSdigital/dp-authorisation/v2/jwt"
+	"github.com/ONSdigital/dp-authorisation/v2/metrics"
 	health "github.com/ONSdigital/dp-healthcheck/healthcheck"
 	"github.com/ONSdigital/log.go/v2/log"
 
 	dphttp "github.com/ONSdigital/dp-net/v3/http"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // contains default dp-net client settings
@@ -28,6 +33,7 @@ const (
 	identityRequestError     = "identity service request failed"
 	identityHealthStateError = "Error updating state during authorisation identity client healthcheck"
 	identityServiceJWTKeys   = "/v1/jwt-keys"
+	jwtKeysRotated           = "jwt verification keys rotated"
 )
 
 // IdentityInterface interface contains one method
@@ -37,37 +43,68 @@ type IdentityInterface interface {
 	Get(ctx context.Context, url string) (*http.Response, error)
 }
 
-// IdentityClient contains identity client handler
+// IdentityClient contains identity client handler. JWTKeys and JSONWebKeySet hold the keys last fetched by the client,
+// and are replaced whenever the keys are refreshed. Parsers created by NewParser all verify tokens using the client's
+// key store, so that the refreshed keys are used immediately.
 type IdentityClient struct {
 	Client,
 	BasicClient IdentityInterface
 	JWTKeys          map[string]string
+	JSONWebKeySet    *jwt.JSONWebKeySet
 	IdentityEndpoint string
 	CognitoRSAParser *jwt.CognitoRSAParser
+	ParserOptions    []jwt.ParserOption
+	// KeyRefreshMinInterval is the minimum time between refetches of the keys by parsers created by the client, when
+	// a token is signed with an unknown key. A value of 0 disables refetching, see jwt.WithKeyRefresh.
+	KeyRefreshMinInterval time.Duration
+	// KeySnapshotPath is the file the keys are saved to each time they are fetched, so that they can be loaded by
+	// LoadKeySnapshot if the identity service is unavailable when a service starts. Keys are not saved if it is empty.
+	KeySnapshotPath string
+	// KeySnapshotMaxAge is the age after which a snapshot is not loaded. A value of 0 loads a snapshot of any age.
+	KeySnapshotMaxAge time.Duration
+	Metrics           *metrics.Metrics
+	TracerProvider    trace.TracerProvider
+
+	refresher *keyRefresher
+
+	// mu guards JWTKeys and JSONWebKeySet, which are replaced along with the key set held by keys
+	mu                sync.RWMutex
+	keys              jwt.KeyStore
+	snapshotFetchedAt time.Time
 }
 
 // NewIdentityClient identity client constructor
 func NewIdentityClient(identityEndpoint string, maxRetries int) (*IdentityClient, error) {
+	return newIdentityClient(identityEndpoint+identityServiceJWTKeys, maxRetries), nil
+}
+
+// NewJSONWebKeySetClient returns an identity client that gets the JWT verification keys from a standard
+// JSON Web Key Set document, such as a Cognito user pool's /.well-known/jwks.json, rather than from the identity service
+func NewJSONWebKeySetClient(jwksURL string, maxRetries int) (*IdentityClient, error) {
+	return newIdentityClient(jwksURL, maxRetries), nil
+}
+
+func newIdentityClient(keysURL string, maxRetries int) *IdentityClient {
 	return &IdentityClient{
 		Client: &dphttp.Client{
 			MaxRetries: maxRetries,
 			RetryTime:  retryTime * time.Second,
 			HTTPClient: &http.Client{
 				Timeout: timeoutTime * time.Second,
-				Transport: &http.Transport{
+				Transport: tracing.NewTransport(&http.Transport{
 					DialContext: (&net.Dialer{
 						Timeout: dialTimeoutTime * time.Second,
 					}).DialContext,
 					TLSHandshakeTimeout: tlsTimeoutTime * time.Second,
 					MaxIdleConns:        maxIdleConns,
 					IdleConnTimeout:     idleTimeoutTime * time.Second,
-				},
+				}),
 			},
 		},
-		BasicClient:      dphttp.NewClient(),
+		BasicClient:      dphttp.NewClientWithTransport(tracing.NewTransport(dphttp.DefaultTransport)),
 		JWTKeys:          nil,
-		IdentityEndpoint: identityEndpoint + identityServiceJWTKeys,
-	}, nil
+		IdentityEndpoint: keysURL,
+	}
 }
 
 // Get wrapper for dp-net Get
@@ -84,22 +121,28 @@ func (c *IdentityClient) basicGet(ctx context.Context) (*http.Response, error) {
 	return c.BasicClient.Get(ctx, c.IdentityEndpoint)
 }
 
-// IdentityHealthCheck reports on status of jwt keys request against identity service
+// IdentityHealthCheck reports on status of jwt keys request against identity service. If the key refresher has been
+// started, the age of the keys and the outcome of the last refresh are reported.
 func (c *IdentityClient) IdentityHealthCheck(ctx context.Context, state *health.CheckState) error {
-	if c.JWTKeys == nil {
+	if c.refresher != nil && c.hasKeys() {
+		return c.refresher.healthCheck(state)
+	}
+	if !c.hasKeys() {
 		// attempt a new request on fail
 		identityResponse, err := c.basicGet(ctx)
 		if err != nil {
+			c.Metrics.RecordJWKSRefresh(err)
+			c.recordRefresh(err)
 			if stateErr := state.Update(health.StatusCritical, jwtKeyRequestError, http.StatusInternalServerError); stateErr != nil {
 				log.Error(context.Background(), identityHealthStateError, stateErr)
 			}
 			return err
 		}
+		defer identityResponse.Body.Close()
+
 		err = c.unmarshalIdentityResponse(identityResponse.Body)
-		if err != nil {
-			return err
-		}
-		c.CognitoRSAParser, err = jwt.NewCognitoRSAParser(c.JWTKeys)
+		c.Metrics.RecordJWKSRefresh(err)
+		c.recordRefresh(err)
 		if err != nil {
 			return err
 		}
@@ -114,27 +157,182 @@ func (c *IdentityClient) IdentityHealthCheck(ctx context.Context, state *health.
 	return nil
 }
 
-// GetJWTVerificationKeys gets the JWT verification keys - takes identity client as argument
+// GetJWTVerificationKeys gets the JWT verification keys, returning an error if they could not be fetched. The keys
+// are used by the client's parsers as soon as they have been fetched.
 func (c *IdentityClient) GetJWTVerificationKeys(ctx context.Context) error {
-	identityResponse, _ := c.Get(ctx)
-	if identityResponse != nil {
-		err := c.unmarshalIdentityResponse(identityResponse.Body)
-		if err != nil {
-			return err
+	return c.getJWTVerificationKeys(ctx, "identity.GetJWTVerificationKeys")
+}
+
+// getJWTVerificationKeys fetches the keys, replacing the keys held by the client and saving them to the snapshot.
+// Every refresh of the keys goes through here, so that the client's fields and key store always agree.
+func (c *IdentityClient) getJWTVerificationKeys(ctx context.Context, spanName string) error {
+	ctx, span := tracing.Tracer(c.TracerProvider).Start(ctx, spanName)
+	identityResponse, err := c.Get(ctx)
+	if err != nil {
+		c.Metrics.RecordJWKSRefresh(err)
+		tracing.EndSpan(span, err)
+		return err
+	}
+	defer identityResponse.Body.Close()
+
+	err = c.unmarshalIdentityResponse(identityResponse.Body)
+	c.Metrics.RecordJWKSRefresh(err)
+	tracing.EndSpan(span, err)
+	return err
+}
+
+// recordRefresh records the outcome of fetching the keys for the key refresher's health check, if it has been started
+func (c *IdentityClient) recordRefresh(err error) {
+	if c.refresher != nil {
+		c.refresher.record(err)
+	}
+}
+
+// NewParser returns a JWT parser using the verification keys that have been retrieved and the client's ParserOptions.
+// Keys from a JSON Web Key Set take precedence over the identity service key map. The parser verifies tokens using
+// the client's key store, so it uses the keys fetched by the client from then on, including any fetched later by the
+// health check or the key refresher. If KeyRefreshMinInterval is set, the parser refetches the keys when a token is
+// signed with an unknown key.
+func (c *IdentityClient) NewParser() (*jwt.CognitoRSAParser, error) {
+	// the store is updated with any keys set on the client directly
+	c.mu.Lock()
+	keys, err := verificationKeys(c.JWTKeys, c.JSONWebKeySet)
+	if err == nil {
+		err = c.storeKeys(keys)
+	}
+	c.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := append(slices.Clone(c.ParserOptions), jwt.WithKeyProvider(&c.keys))
+	if c.KeyRefreshMinInterval > 0 {
+		opts = append(opts, jwt.WithKeyRefresh(c.refreshVerificationKeys, c.KeyRefreshMinInterval))
+	}
+	return jwt.NewCognitoRSAParserFromKeys(keys, opts...)
+}
+
+// KeySet returns the verification keys currently used by the client's parsers, so that the client may be used as a
+// jwt.KeyProvider
+func (c *IdentityClient) KeySet() *jwt.KeySet {
+	return c.keys.KeySet()
+}
+
+// refreshVerificationKeys refetches the keys for a parser given a token signed with an unknown key. The keys are
+// swapped into the client's key store, which the parser uses, so no keys are returned to the parser.
+func (c *IdentityClient) refreshVerificationKeys(ctx context.Context) (map[string]jwt.VerificationKey, error) {
+	err := c.getJWTVerificationKeys(ctx, "identity.RefreshJWTVerificationKeys")
+	c.recordRefresh(err)
+	return nil, err
+}
+
+// verificationKeys returns the keys from a JSON Web Key Set if there is one, or else from the identity service map
+// of key IDs to base64 encoded public keys
+func verificationKeys(jwtKeys map[string]string, keySet *jwt.JSONWebKeySet) (map[string]jwt.VerificationKey, error) {
+	if keySet != nil {
+		return keySet.VerificationKeys()
+	}
+	return jwt.ParseVerificationKeys(jwtKeys)
+}
+
+// storeKeys swaps the given keys into the key store used by the client's parsers, logging any change to the key IDs.
+// It must be called with mu held.
+func (c *IdentityClient) storeKeys(keys map[string]jwt.VerificationKey) error {
+	keySet, err := jwt.NewKeySet(keys)
+	if err != nil {
+		return err
+	}
+
+	// keys fetched by a client that did not have any have not been rotated
+	previous := c.keys.Swap(keySet)
+	if previous.Len() > 0 {
+		if added, removed := jwt.DiffKeyIDs(previous, keySet); len(added) > 0 || len(removed) > 0 {
+			c.keysRotated(added, removed)
 		}
 	}
 	return nil
 }
 
-// unmarshalIdentityResponse method to unmarshal Get response body
+// keysRotated logs and counts a change to the IDs of the keys fetched by the client or by one of its parsers
+func (c *IdentityClient) keysRotated(added, removed []string) {
+	log.Info(context.Background(), jwtKeysRotated, log.Data{"added": added, "removed": removed})
+	c.Metrics.RecordJWKSRotation()
+}
+
+// hasKeys returns true if verification keys have been retrieved
+func (c *IdentityClient) hasKeys() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.JWTKeys != nil || c.JSONWebKeySet != nil
+}
+
+// unmarshalIdentityResponse method to unmarshal Get response body, which is either a JSON Web Key Set or the
+// identity service map of key IDs to base64 encoded public keys. The keys replace those held by the client and its
+// key store, unless they cannot be parsed, and are saved to the key snapshot.
 func (c *IdentityClient) unmarshalIdentityResponse(responseBody io.ReadCloser) error {
 	body, err := io.ReadAll(responseBody)
 	if err != nil {
 		return err
 	}
-	err = json.Unmarshal(body, &c.JWTKeys)
+	if err = c.setKeys(body, time.Time{}); err != nil {
+		return err
+	}
+	c.saveKeySnapshot(context.Background(), body)
+	return nil
+}
+
+// setKeys replaces the keys held by the client and its key store with those in the body of a response for the keys.
+// snapshotFetchedAt is the time keys loaded from a snapshot were fetched, or zero for keys that have just been fetched.
+func (c *IdentityClient) setKeys(body []byte, snapshotFetchedAt time.Time) error {
+	jwtKeys, keySet, err := decodeKeys(body)
 	if err != nil {
 		return err
 	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// a JSON Web Key Set takes precedence over a key map fetched previously
+	currentKeySet := c.JSONWebKeySet
+	if keySet != nil {
+		currentKeySet = keySet
+	}
+	keys, err := verificationKeys(jwtKeys, currentKeySet)
+	if err != nil {
+		return err
+	}
+	if err = c.storeKeys(keys); err != nil {
+		return err
+	}
+
+	c.snapshotFetchedAt = snapshotFetchedAt
+	if keySet != nil {
+		c.JSONWebKeySet = keySet
+		return nil
+	}
+	c.JWTKeys = jwtKeys
 	return nil
 }
+
+// keysFromSnapshot returns the time the client's keys were fetched, if they were loaded from a snapshot
+func (c *IdentityClient) keysFromSnapshot() (time.Time, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.snapshotFetchedAt, !c.snapshotFetchedAt.IsZero()
+}
+
+// decodeKeys decodes either a JSON Web Key Set, or the identity service map of key IDs to base64 encoded public keys
+func decodeKeys(body []byte) (map[string]string, *jwt.JSONWebKeySet, error) {
+	if jwt.IsJSONWebKeySet(body) {
+		keySet, err := jwt.ParseJSONWebKeySet(body)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, keySet, nil
+	}
+	var jwtKeys map[string]string
+	if err := json.Unmarshal(body, &jwtKeys); err != nil {
+		return nil, nil, err
+	}
+	return jwtKeys, nil, nil
+}
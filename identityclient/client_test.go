@@ -3,13 +3,23 @@ package identityclient_test
 import (
 	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/ONSdigital/dp-authorisation/v2/identityclient"
+	"github.com/ONSdigital/dp-authorisation/v2/jwt"
+	gojwt "github.com/golang-jwt/jwt/v4"
 
 	"github.com/ONSdigital/dp-authorisation/v2/identityclient/mock"
 
@@ -32,8 +42,22 @@ const (
 	testJWTPublicKeyAPIMap = `{"test123=": "MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEAu1SU1LfVLPHCozMxH2Mo4lgOEePzNm0tRgeLezV6ffAt0gunVTLw7onLRnrq0/IzW7yWR7QkrmBL7jTKEn5u+qKhbwKfBstIs+bMY2Zkp18gnTxKLxoS2tFczGkPLPgizskuemMghRniWaoLcyehkd3qqGElvW/VDL5AaWTg0nLVkjRo9z+40RQzuVaE8AkAFmxZzow3x+VJYKdjykkJ0iT9wCS0DRTXu269V264Vf/3jvredZiKRkgwlL9xNAwxXFg0x/XFw005UWVRIkdgcKWTjpBP2dPwVZ4WWC+9aGVd+Gyn1o0CLelf4rEjGoXbAAEgAqeGUxrcIlbjXfbcmwIDAQAB","test456=": "MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEAu1SU1LfVLPHCozMxH2Mo4lgOEePzNm0tRgeLezV6ffAt0gunVTLw7onLRnrq0/IzW7yWR7QkrmBL7jTKEn5u+qKhbwKfBstIs+bMY2Zkp18gnTxKLxoS2tFczGkPLPgizskuemMghRniWaoLcyehkd3qqGElvW/VDL5AaWTg0nLVkjRo9z+40RQzuVaE8AkAFmxZzow3x+VJYKdjykkJ0iT9wCS0DRTXu269V264Vf/3jvredZiKRkgwlL9xNAwxXFg0x/XFw005UWVRIkdgcKWTjpBP2dPwVZ4WWC+9aGVd+Gyn1o0CLelf4rEjGoXbAAEgAqeGUxrcIlbjXfbcmwIDAQAB"}`
 )
 
+// testJSONWebKeySet contains the public key of testJWTPublicKeyAPIMap in JSON Web Key format
+var testJSONWebKeySet = `{"keys":[{"kty":"RSA","kid":"test123=","use":"sig","alg":"RS256","x5c":[],"n":"u1SU1LfVLPHCozMxH2Mo4lgOEePzNm0tRgeLezV6ffAt0gunVTLw7onLRnrq0_IzW7yWR7QkrmBL7jTKEn5u-qKhbwKfBstIs-bMY2Zkp18gnTxKLxoS2tFczGkPLPgizskuemMghRniWaoLcyehkd3qqGElvW_VDL5AaWTg0nLVkjRo9z-40RQzuVaE8AkAFmxZzow3x-VJYKdjykkJ0iT9wCS0DRTXu269V264Vf_3jvredZiKRkgwlL9xNAwxXFg0x_XFw005UWVRIkdgcKWTjpBP2dPwVZ4WWC-9aGVd-Gyn1o0CLelf4rEjGoXbAAEgAqeGUxrcIlbjXfbcmw","e":"AQAB"}]}`
+
 var errTest = errors.New("dummy test error")
 
+// closeRecorder is a response body that records whether it has been closed
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (r *closeRecorder) Close() error {
+	r.closed = true
+	return nil
+}
+
 func TestIndentityClient(t *testing.T) {
 	ctx := context.Background()
 
@@ -117,12 +141,150 @@ func TestIndentityClient_GetJWTVerificationKeys(t *testing.T) {
 				},
 			},
 		}
-		_ = identityClient.GetJWTVerificationKeys(ctx)
+		err := identityClient.GetJWTVerificationKeys(ctx)
 
+		So(err, ShouldEqual, errTest)
 		So(identityClient.JWTKeys, ShouldBeNil)
 	})
 }
 
+func TestIndentityClient_GetJWTVerificationKeys_JSONWebKeySet(t *testing.T) {
+	Convey("Given a client for a JSON Web Key Set URL", t, func() {
+		ctx := context.Background()
+		var requestedURL string
+
+		identityClient, err := identityclient.NewJSONWebKeySetClient("https://cognito.example/.well-known/jwks.json", 1)
+		So(err, ShouldBeNil)
+		identityClient.Client = &mock.IdentityInterfaceMock{
+			GetFunc: func(ctx context.Context, url string) (*http.Response, error) {
+				requestedURL = url
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       io.NopCloser(bytes.NewBufferString(testJSONWebKeySet)),
+				}, nil
+			},
+		}
+
+		Convey("When the JWT verification keys are requested", func() {
+			err := identityClient.GetJWTVerificationKeys(ctx)
+
+			Convey("Then the key set is read from the given URL", func() {
+				So(err, ShouldBeNil)
+				So(requestedURL, ShouldEqual, "https://cognito.example/.well-known/jwks.json")
+				So(identityClient.JWTKeys, ShouldBeNil)
+				So(identityClient.JSONWebKeySet.Keys, ShouldHaveLength, 1)
+			})
+
+			Convey("Then a parser can be created from the key set", func() {
+				parser, err := identityClient.NewParser()
+				So(err, ShouldBeNil)
+				So(parser.PublicKeys["test123="], ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestIndentityClient_NewParser_KeyRefresh(t *testing.T) {
+	rotatedKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotatedKeySet, err := json.Marshal(jwt.JSONWebKeySet{Keys: []jwt.JSONWebKey{{
+		KeyType: jwt.KeyTypeEC,
+		KeyID:   "rotated",
+		Curve:   jwt.CurveP256,
+		X:       base64.RawURLEncoding.EncodeToString(rotatedKey.X.FillBytes(make([]byte, 32))),
+		Y:       base64.RawURLEncoding.EncodeToString(rotatedKey.Y.FillBytes(make([]byte, 32))),
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := gojwt.NewWithClaims(gojwt.SigningMethodES256, gojwt.MapClaims{
+		"username":       "janedoe@example.com",
+		"cognito:groups": []string{"admin"},
+		"exp":            time.Now().Add(time.Hour).Unix(),
+	})
+	token.Header[jwt.Kid] = "rotated"
+	signedToken, err := token.SignedString(rotatedKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Convey("Given a client holding keys that have since been rotated", t, func() {
+		var requests int
+		clientMock := &mock.IdentityInterfaceMock{
+			GetFunc: func(ctx context.Context, url string) (*http.Response, error) {
+				requests++
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       io.NopCloser(bytes.NewReader(rotatedKeySet)),
+				}, nil
+			},
+		}
+		identityClient := &identityclient.IdentityClient{
+			Client:           clientMock,
+			IdentityEndpoint: testURL,
+		}
+		So(json.Unmarshal([]byte(testJWTPublicKeyAPIMap), &identityClient.JWTKeys), ShouldBeNil)
+
+		Convey("When a parser that refetches its keys parses a token signed with the new key", func() {
+			identityClient.KeyRefreshMinInterval = time.Minute
+			parser, err := identityClient.NewParser()
+			So(err, ShouldBeNil)
+			entityData, err := parser.Parse(signedToken)
+
+			Convey("Then the keys are fetched and the token is verified", func() {
+				So(err, ShouldBeNil)
+				So(entityData.UserID, ShouldEqual, "janedoe@example.com")
+				So(requests, ShouldEqual, 1)
+			})
+
+			Convey("Then the keys held by the client are replaced by the fetched keys", func() {
+				So(identityClient.JSONWebKeySet, ShouldNotBeNil)
+				So(identityClient.JSONWebKeySet.Keys[0].KeyID, ShouldEqual, "rotated")
+				So(identityClient.KeySet().KeyIDs(), ShouldResemble, []string{"rotated"})
+			})
+
+		})
+
+		Convey("When a client that started without keys has them fetched by its parser", func() {
+			snapshotPath := filepath.Join(t.TempDir(), "jwt-keys.json")
+			started := &identityclient.IdentityClient{
+				Client:                clientMock,
+				BasicClient:           clientMock,
+				KeyRefreshMinInterval: time.Minute,
+				KeySnapshotPath:       snapshotPath,
+			}
+			parser, err := started.NewParser()
+			So(err, ShouldBeNil)
+			_, err = parser.Parse(signedToken)
+			So(err, ShouldBeNil)
+
+			Convey("Then the fetched keys are saved to the snapshot", func() {
+				_, err := os.Stat(snapshotPath)
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Then the health check does not fetch the keys again", func() {
+				So(started.IdentityHealthCheck(context.Background(), health.NewCheckState(healthCheckTestName)), ShouldBeNil)
+				So(requests, ShouldEqual, 1)
+			})
+		})
+
+		Convey("When a parser without a refresh interval parses a token signed with the new key", func() {
+			parser, err := identityClient.NewParser()
+			So(err, ShouldBeNil)
+			_, err = parser.Parse(signedToken)
+
+			Convey("Then the token is rejected without fetching the keys", func() {
+				So(err.Error(), ShouldEqual, jwt.ErrJWTKeySet.Error())
+				So(requests, ShouldEqual, 0)
+			})
+		})
+	})
+}
+
 func TestIndentityClient_IdentityHealthCheck(t *testing.T) {
 	ctx := context.Background()
 
@@ -151,6 +313,47 @@ func TestIndentityClient_IdentityHealthCheck(t *testing.T) {
 		So(checkState.Message(), ShouldEqual, jwtKeyRequestOK)
 	})
 
+	Convey("Given a health check that fetches the keys", t, func() {
+		body := &closeRecorder{Reader: bytes.NewBufferString(testJWTPublicKeyAPIMap)}
+		identityClient := identityclient.IdentityClient{
+			BasicClient: &mock.IdentityInterfaceMock{
+				GetFunc: func(ctx context.Context, url string) (*http.Response, error) {
+					return &http.Response{StatusCode: http.StatusOK, Body: body}, nil
+				},
+			},
+		}
+
+		Convey("Then the response body is closed", func() {
+			So(identityClient.IdentityHealthCheck(ctx, checkState), ShouldBeNil)
+			So(body.closed, ShouldBeTrue)
+		})
+	})
+
+	Convey("Given a parser created by a client before the keys could be fetched", t, func() {
+		identityClient := &identityclient.IdentityClient{
+			BasicClient: &mock.IdentityInterfaceMock{
+				GetFunc: func(ctx context.Context, url string) (*http.Response, error) {
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       io.NopCloser(bytes.NewBufferString(testJWTPublicKeyAPIMap)),
+					}, nil
+				},
+			},
+		}
+		parser, err := identityClient.NewParser()
+		So(err, ShouldBeNil)
+		So(parser.KeySet().Len(), ShouldEqual, 0)
+
+		Convey("When the health check fetches the keys", func() {
+			So(identityClient.IdentityHealthCheck(ctx, checkState), ShouldBeNil)
+
+			Convey("Then the parser uses the keys", func() {
+				So(parser.KeySet().KeyIDs(), ShouldResemble, []string{"test123=", "test456="})
+				So(identityClient.KeySet(), ShouldEqual, parser.KeySet())
+			})
+		})
+	})
+
 	Convey("Get JWT Verification keys on health check call - success", t, func() {
 		identityClient := identityclient.IdentityClient{
 			BasicClient: &mock.IdentityInterfaceMock{
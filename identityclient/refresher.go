@@ -0,0 +1,193 @@
+package identityclient
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	health "github.com/ONSdigital/dp-healthcheck/healthcheck"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// Default values used by the key refresher
+const (
+	DefaultKeyRefreshInterval = 15 * time.Minute
+	DefaultKeyRefreshBackoff  = 5 * time.Second
+)
+
+// Messages used for the health state when the keys are refreshed periodically
+const (
+	jwtKeysRefreshOK     = "jwt keys request ok, keys updated %s ago"
+	jwtKeysRefreshFailed = "the last jwt keys refresh failed, keys updated %s ago"
+	jwtKeysStale         = "jwt keys are stale, keys updated %s ago and the last refresh failed"
+)
+
+// KeyRefresherOption configures optional behaviour of the key refresher
+type KeyRefresherOption func(r *keyRefresher)
+
+// WithRefreshBackoff sets the delay before the first retry of a failed refresh. The delay doubles with each failure
+// that follows, up to the refresh interval, and is randomised so that services do not retry in step. The default
+// is DefaultKeyRefreshBackoff.
+func WithRefreshBackoff(backoff time.Duration) KeyRefresherOption {
+	return func(r *keyRefresher) {
+		if backoff > 0 {
+			r.backoff = backoff
+		}
+	}
+}
+
+// WithMaxKeyAge sets the age after which the keys are reported as critical by the health check, if they could not
+// be refreshed. Until then, a failed refresh is reported as a warning. A max age of 0, the default, never reports
+// stale keys as critical.
+func WithMaxKeyAge(maxAge time.Duration) KeyRefresherOption {
+	return func(r *keyRefresher) {
+		r.maxAge = maxAge
+	}
+}
+
+// keyRefresher periodically refetches the JWT verification keys of an identity client
+type keyRefresher struct {
+	client   *IdentityClient
+	interval time.Duration
+	backoff  time.Duration
+	maxAge   time.Duration
+
+	closing   chan struct{}
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	mu          sync.Mutex
+	lastUpdated time.Time
+	lastErr     error
+	failures    int
+}
+
+// StartKeyRefresher starts a go routine that refetches the JWT verification keys at the given interval, so that
+// the keys are kept up to date and are fetched once the identity service is available if it was not at startup.
+// Failed refreshes are retried with exponential backoff. The health check reports the age of the keys and the
+// outcome of the last refresh. Stop the refresher by calling Close. An interval that is not positive uses
+// DefaultKeyRefreshInterval. The refresher is only started once, later calls are ignored.
+func (c *IdentityClient) StartKeyRefresher(ctx context.Context, interval time.Duration, opts ...KeyRefresherOption) {
+	if c.refresher != nil {
+		return
+	}
+	if interval <= 0 {
+		interval = DefaultKeyRefreshInterval
+	}
+	r := &keyRefresher{
+		client:   c,
+		interval: interval,
+		backoff:  DefaultKeyRefreshBackoff,
+		closing:  make(chan struct{}),
+		closed:   make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	// keys fetched at startup are as good as a successful refresh, while a client without keys, or with keys loaded
+	// from a snapshot, retries promptly
+	if fetchedAt, ok := c.keysFromSnapshot(); ok {
+		r.lastUpdated = fetchedAt
+		r.lastErr = errKeysFromSnapshot
+		r.failures = 1
+	} else if c.hasKeys() {
+		r.lastUpdated = time.Now()
+	} else {
+		r.failures = 1
+	}
+
+	c.refresher = r
+	go r.run(ctx)
+}
+
+// Close stops the key refresher, if it has been started, and blocks until it has stopped
+func (c *IdentityClient) Close(_ context.Context) error {
+	if c.refresher == nil {
+		return nil
+	}
+	c.refresher.closeOnce.Do(func() {
+		close(c.refresher.closing)
+	})
+	<-c.refresher.closed
+	return nil
+}
+
+func (r *keyRefresher) run(ctx context.Context) {
+	defer close(r.closed)
+
+	timer := time.NewTimer(r.nextDelay())
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			r.refresh(ctx)
+			timer.Reset(r.nextDelay())
+		case <-r.closing:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// refresh refetches the keys, recording the outcome for the health check
+func (r *keyRefresher) refresh(ctx context.Context) {
+	err := r.client.GetJWTVerificationKeys(ctx)
+	if failures := r.record(err); err != nil {
+		log.Error(ctx, "failed to refresh jwt verification keys", err, log.Data{"failures": failures})
+	}
+}
+
+// record records the outcome of fetching the keys, returning the number of consecutive failures
+func (r *keyRefresher) record(err error) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastErr = err
+	if err != nil {
+		r.failures++
+		return r.failures
+	}
+	r.failures = 0
+	r.lastUpdated = time.Now()
+	return 0
+}
+
+// nextDelay returns the time until the next refresh: the interval after a successful refresh, or an exponentially
+// increasing, randomised backoff after a failure
+func (r *keyRefresher) nextDelay() time.Duration {
+	r.mu.Lock()
+	failures := r.failures
+	r.mu.Unlock()
+
+	if failures == 0 {
+		return r.interval
+	}
+	delay := r.backoff
+	for i := 1; i < failures && delay < r.interval; i++ {
+		delay *= 2
+	}
+	delay = min(delay, r.interval)
+
+	// equal jitter, so that the delay is between half and all of the backoff
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// healthCheck updates the health state with the age of the keys and the outcome of the last refresh
+func (r *keyRefresher) healthCheck(state *health.CheckState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	age := time.Since(r.lastUpdated)
+	switch {
+	case r.lastErr == nil:
+		return state.Update(health.StatusOK, fmt.Sprintf(jwtKeysRefreshOK, age.Round(time.Second)), 0)
+	case r.maxAge > 0 && age > r.maxAge:
+		return state.Update(health.StatusCritical, fmt.Sprintf(jwtKeysStale, age.Round(time.Second)), 0)
+	default:
+		return state.Update(health.StatusWarning, fmt.Sprintf(jwtKeysRefreshFailed, age.Round(time.Second)), 0)
+	}
+}
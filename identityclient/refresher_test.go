@@ -0,0 +1,138 @@
+package identityclient_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-authorisation/v2/identityclient"
+	"github.com/ONSdigital/dp-authorisation/v2/identityclient/mock"
+	health "github.com/ONSdigital/dp-healthcheck/healthcheck"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIdentityClient_StartKeyRefresher(t *testing.T) {
+	ctx := context.Background()
+
+	// failingGets returns a mock that fails the given number of requests before returning the keys
+	failingGets := func(failures int) *mock.IdentityInterfaceMock {
+		clientMock := &mock.IdentityInterfaceMock{}
+		clientMock.GetFunc = func(ctx context.Context, url string) (*http.Response, error) {
+			if len(clientMock.GetCalls()) <= failures {
+				return nil, errTest
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewBufferString(testJWTPublicKeyAPIMap)),
+			}, nil
+		}
+		return clientMock
+	}
+
+	// waitForGets waits for the mock to have been called the given number of times
+	waitForGets := func(clientMock *mock.IdentityInterfaceMock, calls int) {
+		deadline := time.Now().Add(5 * time.Second)
+		for len(clientMock.GetCalls()) < calls && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	Convey("Given a client that started while the identity service was unavailable", t, func() {
+		clientMock := failingGets(3)
+		identityClient := &identityclient.IdentityClient{Client: clientMock}
+		So(identityClient.GetJWTVerificationKeys(ctx), ShouldEqual, errTest)
+		parser, err := identityClient.NewParser()
+		So(err, ShouldBeNil)
+
+		Convey("When the key refresher is started", func() {
+			identityClient.StartKeyRefresher(ctx, time.Hour, identityclient.WithRefreshBackoff(time.Millisecond))
+			waitForGets(clientMock, 4)
+			So(identityClient.Close(ctx), ShouldBeNil)
+
+			Convey("Then the failed refreshes are retried until the keys are fetched", func() {
+				So(clientMock.GetCalls(), ShouldHaveLength, 4)
+				So(identityClient.JWTKeys, ShouldHaveLength, 2)
+			})
+
+			Convey("Then the parser created before the keys were fetched uses them", func() {
+				So(parser.KeySet().KeyIDs(), ShouldResemble, []string{"test123=", "test456="})
+			})
+
+			Convey("Then the health check reports the age of the keys", func() {
+				checkState := health.NewCheckState(healthCheckTestName)
+				So(identityClient.IdentityHealthCheck(ctx, checkState), ShouldBeNil)
+				So(checkState.Status(), ShouldEqual, health.StatusOK)
+				So(checkState.Message(), ShouldEqual, "jwt keys request ok, keys updated 0s ago")
+			})
+		})
+	})
+
+	Convey("Given a client with keys that can no longer be refreshed", t, func() {
+		clientMock := failingGets(1)
+		identityClient := &identityclient.IdentityClient{Client: clientMock}
+		So(identityClient.GetJWTVerificationKeys(ctx), ShouldEqual, errTest)
+		So(identityClient.GetJWTVerificationKeys(ctx), ShouldBeNil)
+		clientMock.GetFunc = func(ctx context.Context, url string) (*http.Response, error) {
+			return nil, errTest
+		}
+
+		Convey("When a refresh fails", func() {
+			identityClient.StartKeyRefresher(ctx, time.Millisecond)
+			waitForGets(clientMock, 3)
+			So(identityClient.Close(ctx), ShouldBeNil)
+
+			Convey("Then the existing keys are kept", func() {
+				So(identityClient.JWTKeys, ShouldHaveLength, 2)
+			})
+
+			Convey("Then the health check reports a warning", func() {
+				checkState := health.NewCheckState(healthCheckTestName)
+				So(identityClient.IdentityHealthCheck(ctx, checkState), ShouldBeNil)
+				So(checkState.Status(), ShouldEqual, health.StatusWarning)
+				So(checkState.Message(), ShouldEqual, "the last jwt keys refresh failed, keys updated 0s ago")
+			})
+		})
+
+		Convey("When a refresh fails after the keys have reached their max age", func() {
+			identityClient.StartKeyRefresher(ctx, time.Millisecond, identityclient.WithMaxKeyAge(time.Nanosecond))
+			waitForGets(clientMock, 3)
+			So(identityClient.Close(ctx), ShouldBeNil)
+
+			Convey("Then the health check reports that the keys are stale", func() {
+				checkState := health.NewCheckState(healthCheckTestName)
+				So(identityClient.IdentityHealthCheck(ctx, checkState), ShouldBeNil)
+				So(checkState.Status(), ShouldEqual, health.StatusCritical)
+			})
+		})
+	})
+
+	Convey("Given a client with keys", t, func() {
+		clientMock := failingGets(0)
+		identityClient := &identityclient.IdentityClient{Client: clientMock}
+		So(identityClient.GetJWTVerificationKeys(ctx), ShouldBeNil)
+
+		Convey("When the key refresher is started twice and then closed", func() {
+			identityClient.StartKeyRefresher(ctx, time.Millisecond)
+			identityClient.StartKeyRefresher(ctx, time.Millisecond)
+			waitForGets(clientMock, 3)
+			So(identityClient.Close(ctx), ShouldBeNil)
+			calls := len(clientMock.GetCalls())
+			time.Sleep(20 * time.Millisecond)
+
+			Convey("Then no refresher is left running", func() {
+				So(clientMock.GetCalls(), ShouldHaveLength, calls)
+			})
+		})
+	})
+
+	Convey("Given a client without a key refresher", t, func() {
+		identityClient := &identityclient.IdentityClient{}
+
+		Convey("Then Close returns without error", func() {
+			So(identityClient.Close(ctx), ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,94 @@
+package identityclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+var (
+	ErrNoKeySnapshot      = errors.New("jwt keys snapshot path is not set")
+	ErrKeySnapshotTooOld  = errors.New("jwt keys snapshot is older than the max age")
+	ErrKeySnapshotInvalid = errors.New("jwt keys snapshot is invalid")
+	errKeysFromSnapshot   = errors.New("jwt keys have not been fetched since they were loaded from a snapshot")
+)
+
+// keySnapshot is the file written to KeySnapshotPath. The keys are the response body of the last successful request
+// for the keys, either a JSON Web Key Set or the identity service map of key IDs to base64 encoded public keys.
+type keySnapshot struct {
+	FetchedAt time.Time       `json:"fetched_at"`
+	Keys      json.RawMessage `json:"keys"`
+}
+
+// LoadKeySnapshot loads the keys saved to KeySnapshotPath when they were last fetched, e.g. so that a service can
+// start while the identity service is unavailable. The keys are used by the client's parsers, until they are
+// replaced by keys fetched from the identity service. An error is returned if there is no snapshot, or if it is
+// older than KeySnapshotMaxAge.
+func (c *IdentityClient) LoadKeySnapshot(ctx context.Context) error {
+	if c.KeySnapshotPath == "" {
+		return ErrNoKeySnapshot
+	}
+	data, err := os.ReadFile(c.KeySnapshotPath)
+	if err != nil {
+		return err
+	}
+
+	var snapshot keySnapshot
+	if err = json.Unmarshal(data, &snapshot); err != nil || snapshot.FetchedAt.IsZero() || len(snapshot.Keys) == 0 {
+		return ErrKeySnapshotInvalid
+	}
+	age := time.Since(snapshot.FetchedAt)
+	if c.KeySnapshotMaxAge > 0 && age > c.KeySnapshotMaxAge {
+		return ErrKeySnapshotTooOld
+	}
+
+	if err = c.setKeys(snapshot.Keys, snapshot.FetchedAt); err != nil {
+		return fmt.Errorf("%w: %v", ErrKeySnapshotInvalid, err)
+	}
+	log.Info(ctx, "loaded jwt verification keys from snapshot", log.Data{
+		"path":       c.KeySnapshotPath,
+		"fetched_at": snapshot.FetchedAt,
+		"age":        age.Round(time.Second).String(),
+	})
+	return nil
+}
+
+// saveKeySnapshot saves the fetched keys to KeySnapshotPath, if it is set. The file is replaced atomically, so that
+// a service starting while it is being written reads either the old or the new snapshot.
+func (c *IdentityClient) saveKeySnapshot(ctx context.Context, keys []byte) {
+	if c.KeySnapshotPath == "" {
+		return
+	}
+	if err := writeKeySnapshot(c.KeySnapshotPath, keySnapshot{FetchedAt: time.Now().UTC(), Keys: keys}); err != nil {
+		log.Error(ctx, "failed to save jwt verification keys snapshot", err, log.Data{"path": c.KeySnapshotPath})
+	}
+}
+
+// writeKeySnapshot writes the snapshot to a temporary file in the same directory, which then replaces the file at path
+func writeKeySnapshot(path string, snapshot keySnapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,131 @@
+package identityclient_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-authorisation/v2/identityclient"
+	"github.com/ONSdigital/dp-authorisation/v2/identityclient/mock"
+	health "github.com/ONSdigital/dp-healthcheck/healthcheck"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIdentityClient_LoadKeySnapshot(t *testing.T) {
+	ctx := context.Background()
+
+	unavailable := &mock.IdentityInterfaceMock{
+		GetFunc: func(ctx context.Context, url string) (*http.Response, error) {
+			return nil, errTest
+		},
+	}
+
+	// writeSnapshot writes a snapshot of the test keys, fetched at the given time
+	writeSnapshot := func(path string, fetchedAt time.Time) {
+		snapshot := fmt.Sprintf(`{"fetched_at":%q,"keys":%s}`, fetchedAt.Format(time.RFC3339Nano), testJWTPublicKeyAPIMap)
+		So(os.WriteFile(path, []byte(snapshot), 0o600), ShouldBeNil)
+	}
+
+	Convey("Given a client that saves a snapshot of the keys it fetches", t, func() {
+		path := filepath.Join(t.TempDir(), "jwt-keys.json")
+		identityClient := &identityclient.IdentityClient{
+			Client: &mock.IdentityInterfaceMock{
+				GetFunc: func(ctx context.Context, url string) (*http.Response, error) {
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       io.NopCloser(bytes.NewBufferString(testJWTPublicKeyAPIMap)),
+					}, nil
+				},
+			},
+			KeySnapshotPath: path,
+		}
+		So(identityClient.GetJWTVerificationKeys(ctx), ShouldBeNil)
+
+		Convey("When a client starts while the identity service is unavailable", func() {
+			restarted := &identityclient.IdentityClient{
+				Client:            unavailable,
+				KeySnapshotPath:   path,
+				KeySnapshotMaxAge: time.Hour,
+			}
+			parser, err := restarted.NewParser()
+			So(err, ShouldBeNil)
+			So(restarted.GetJWTVerificationKeys(ctx), ShouldEqual, errTest)
+
+			Convey("Then the keys are loaded from the snapshot and used by its parsers", func() {
+				So(restarted.LoadKeySnapshot(ctx), ShouldBeNil)
+				So(restarted.JWTKeys, ShouldResemble, identityClient.JWTKeys)
+				So(parser.KeySet().KeyIDs(), ShouldResemble, []string{"test123=", "test456="})
+			})
+
+			Convey("Then the snapshot can only be read by the service", func() {
+				info, err := os.Stat(path)
+				So(err, ShouldBeNil)
+				So(info.Mode().Perm(), ShouldEqual, os.FileMode(0o600))
+			})
+		})
+	})
+
+	Convey("Given a client with keys loaded from a snapshot", t, func() {
+		path := filepath.Join(t.TempDir(), "jwt-keys.json")
+		writeSnapshot(path, time.Now().Add(-time.Hour))
+		identityClient := &identityclient.IdentityClient{Client: unavailable, KeySnapshotPath: path}
+		So(identityClient.LoadKeySnapshot(ctx), ShouldBeNil)
+
+		Convey("When the key refresher is started", func() {
+			identityClient.StartKeyRefresher(ctx, time.Hour, identityclient.WithRefreshBackoff(time.Hour))
+			So(identityClient.Close(ctx), ShouldBeNil)
+
+			Convey("Then the health check reports a warning with the age of the snapshot", func() {
+				checkState := health.NewCheckState(healthCheckTestName)
+				So(identityClient.IdentityHealthCheck(ctx, checkState), ShouldBeNil)
+				So(checkState.Status(), ShouldEqual, health.StatusWarning)
+				So(checkState.Message(), ShouldEqual, "the last jwt keys refresh failed, keys updated 1h0m0s ago")
+			})
+		})
+	})
+
+	Convey("Given a snapshot older than the max age", t, func() {
+		path := filepath.Join(t.TempDir(), "jwt-keys.json")
+		writeSnapshot(path, time.Now().Add(-48*time.Hour))
+		identityClient := &identityclient.IdentityClient{KeySnapshotPath: path, KeySnapshotMaxAge: 24 * time.Hour}
+
+		Convey("Then the snapshot is not loaded", func() {
+			So(identityClient.LoadKeySnapshot(ctx), ShouldEqual, identityclient.ErrKeySnapshotTooOld)
+			So(identityClient.JWTKeys, ShouldBeNil)
+		})
+	})
+
+	Convey("Given an invalid snapshot", t, func() {
+		path := filepath.Join(t.TempDir(), "jwt-keys.json")
+		So(os.WriteFile(path, []byte(`{"keys":`), 0o600), ShouldBeNil)
+		identityClient := &identityclient.IdentityClient{KeySnapshotPath: path}
+
+		Convey("Then the snapshot is not loaded", func() {
+			So(identityClient.LoadKeySnapshot(ctx), ShouldEqual, identityclient.ErrKeySnapshotInvalid)
+		})
+	})
+
+	Convey("Given a snapshot that does not exist", t, func() {
+		identityClient := &identityclient.IdentityClient{KeySnapshotPath: filepath.Join(t.TempDir(), "jwt-keys.json")}
+
+		Convey("Then an error is returned", func() {
+			So(errors.Is(identityClient.LoadKeySnapshot(ctx), fs.ErrNotExist), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given a client without a snapshot path", t, func() {
+		identityClient := &identityclient.IdentityClient{}
+
+		Convey("Then an error is returned", func() {
+			So(identityClient.LoadKeySnapshot(ctx), ShouldEqual, identityclient.ErrNoKeySnapshot)
+		})
+	})
+}
@@ -0,0 +1,124 @@
+// Package lru contains a size bounded, least recently used cache with per entry expiry.
+package lru
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// DefaultMaxEntries is the number of entries held by a cache created with a max entries value that is not positive
+const DefaultMaxEntries = 1000
+
+// Cache is a least recently used cache, safe for concurrent use. Entries expire after the ttl they were added
+// with, and the least recently used entry is evicted when an entry is added to a full cache.
+type Cache[K comparable, V any] struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[K]*list.Element
+	order      *list.List
+	now        func() time.Time
+}
+
+type entry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+// Option configures optional behaviour of a Cache
+type Option func(*options)
+
+type options struct {
+	now func() time.Time
+}
+
+// WithClock sets the function used to get the current time, time.Now is used by default
+func WithClock(now func() time.Time) Option {
+	return func(o *options) {
+		if now != nil {
+			o.now = now
+		}
+	}
+}
+
+// New creates a cache holding at most maxEntries entries
+func New[K comparable, V any](maxEntries int, opts ...Option) *Cache[K, V] {
+	o := &options{now: time.Now}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+
+	return &Cache[K, V]{
+		maxEntries: maxEntries,
+		entries:    make(map[K]*list.Element),
+		order:      list.New(),
+		now:        o.now,
+	}
+}
+
+// Get returns the value for the given key, and whether an unexpired value was found. Expired entries are removed.
+func (c *Cache[K, V]) Get(key K) (value V, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, found := c.entries[key]
+	if !found {
+		return value, false
+	}
+
+	e := element.Value.(*entry[K, V])
+	if !c.now().Before(e.expires) {
+		c.removeElement(element)
+		return value, false
+	}
+
+	c.order.MoveToFront(element)
+	return e.value, true
+}
+
+// Add adds or replaces the value for the given key, expiring after the given ttl
+func (c *Cache[K, V]) Add(key K, value V, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := c.now().Add(ttl)
+	if element, found := c.entries[key]; found {
+		e := element.Value.(*entry[K, V])
+		e.value = value
+		e.expires = expires
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&entry[K, V]{key: key, value: value, expires: expires})
+	for c.order.Len() > c.maxEntries {
+		c.removeElement(c.order.Back())
+	}
+}
+
+// Remove removes the value for the given key, if there is one
+func (c *Cache[K, V]) Remove(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, found := c.entries[key]; found {
+		c.removeElement(element)
+	}
+}
+
+// Len returns the number of entries in the cache, including any that have expired but not yet been removed
+func (c *Cache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *Cache[K, V]) removeElement(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*entry[K, V]).key)
+}
@@ -0,0 +1,73 @@
+package lru_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-authorisation/v2/internal/lru"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCache(t *testing.T) {
+	Convey("Given a cache holding two entries with a controllable clock", t, func() {
+		now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		cache := lru.New[string, int](2, lru.WithClock(func() time.Time { return now }))
+
+		cache.Add("a", 1, time.Minute)
+		cache.Add("b", 2, time.Minute)
+
+		Convey("Then added values are returned", func() {
+			value, ok := cache.Get("a")
+			So(ok, ShouldBeTrue)
+			So(value, ShouldEqual, 1)
+			So(cache.Len(), ShouldEqual, 2)
+		})
+
+		Convey("When an entry is added to the full cache", func() {
+			cache.Get("a")
+			cache.Add("c", 3, time.Minute)
+
+			Convey("Then the least recently used entry is evicted", func() {
+				_, ok := cache.Get("b")
+				So(ok, ShouldBeFalse)
+				_, ok = cache.Get("a")
+				So(ok, ShouldBeTrue)
+				_, ok = cache.Get("c")
+				So(ok, ShouldBeTrue)
+				So(cache.Len(), ShouldEqual, 2)
+			})
+		})
+
+		Convey("When an existing entry is replaced", func() {
+			cache.Add("a", 10, time.Minute)
+
+			Convey("Then the new value is returned", func() {
+				value, ok := cache.Get("a")
+				So(ok, ShouldBeTrue)
+				So(value, ShouldEqual, 10)
+				So(cache.Len(), ShouldEqual, 2)
+			})
+		})
+
+		Convey("When an entry's ttl has passed", func() {
+			cache.Add("b", 2, time.Second)
+			now = now.Add(time.Second)
+
+			Convey("Then the entry is not returned and is removed", func() {
+				_, ok := cache.Get("b")
+				So(ok, ShouldBeFalse)
+				So(cache.Len(), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When an entry is removed", func() {
+			cache.Remove("a")
+
+			Convey("Then it is no longer returned", func() {
+				_, ok := cache.Get("a")
+				So(ok, ShouldBeFalse)
+				So(cache.Len(), ShouldEqual, 1)
+			})
+		})
+	})
+}
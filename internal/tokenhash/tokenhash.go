@@ -0,0 +1,14 @@
+// Package tokenhash derives the keys used to cache the result of checking a token. Caches are keyed on a hash of
+// the token rather than the token itself, so that tokens are not held in memory for longer than a request.
+package tokenhash
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Key returns the hex encoded SHA-256 hash of the token
+func Key(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,22 @@
+package tokenhash_test
+
+import (
+	"testing"
+
+	"github.com/ONSdigital/dp-authorisation/v2/internal/tokenhash"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestKey(t *testing.T) {
+	Convey("Given a token", t, func() {
+		token := "my-token"
+
+		Convey("Then the key is the hex encoded SHA-256 hash of the token", func() {
+			So(tokenhash.Key(token), ShouldEqual, "fece50d2287f7245aea5819b75f95ee8bec295a14f8ef1e7a31f17f1dae9df44")
+		})
+
+		Convey("Then different tokens have different keys", func() {
+			So(tokenhash.Key(token), ShouldNotEqual, tokenhash.Key("other-token"))
+		})
+	})
+}
@@ -0,0 +1,53 @@
+// Package tracing contains the OpenTelemetry helpers shared by the authorisation packages.
+package tracing
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName is the name of the tracer used for all spans created by this library
+const InstrumentationName = "github.com/ONSdigital/dp-authorisation/v2"
+
+// Tracer returns the library tracer from the given provider, or from the global provider if it is nil
+func Tracer(tracerProvider trace.TracerProvider) trace.Tracer {
+	if tracerProvider == nil {
+		tracerProvider = otel.GetTracerProvider()
+	}
+	return tracerProvider.Tracer(InstrumentationName)
+}
+
+// EndSpan records the error on the span, if there is one, and ends it
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Transport is a http.RoundTripper that propagates the trace context of each request to the server,
+// using the globally configured propagator.
+type Transport struct {
+	Base http.RoundTripper
+}
+
+// NewTransport creates a Transport that wraps the given round tripper, or http.DefaultTransport if it is nil
+func NewTransport(base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{Base: base}
+}
+
+// RoundTrip injects the trace context into the request headers and sends the request using the base round tripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// a round tripper must not modify the caller's request
+	req = req.Clone(req.Context())
+	otel.GetTextMapPropagator().Inject(req.Context(), propagation.HeaderCarrier(req.Header))
+	return t.Base.RoundTrip(req)
+}
@@ -0,0 +1,49 @@
+package tracing_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ONSdigital/dp-authorisation/v2/internal/tracing"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestTransport(t *testing.T) {
+	Convey("Given a transport and a server that captures the request headers", t, func() {
+		previousPropagator := otel.GetTextMapPropagator()
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+		defer otel.SetTextMapPropagator(previousPropagator)
+
+		var traceparent string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			traceparent = req.Header.Get("traceparent")
+		}))
+		defer server.Close()
+
+		client := &http.Client{Transport: tracing.NewTransport(nil)}
+		tracerProvider := sdktrace.NewTracerProvider()
+
+		Convey("When a request is made within a span", func() {
+			ctx, span := tracing.Tracer(tracerProvider).Start(context.Background(), "test")
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, http.NoBody)
+			So(err, ShouldBeNil)
+			resp, err := client.Do(req)
+			So(err, ShouldBeNil)
+			resp.Body.Close()
+			span.End()
+
+			Convey("Then the trace context is propagated to the server", func() {
+				So(traceparent, ShouldContainSubstring, span.SpanContext().TraceID().String())
+			})
+
+			Convey("Then the caller's request is not modified", func() {
+				So(req.Header.Get("traceparent"), ShouldBeEmpty)
+			})
+		})
+	})
+}
@@ -0,0 +1,114 @@
+package jwt
+
+import (
+	"crypto"
+	"slices"
+	"time"
+
+	"github.com/ONSdigital/dp-authorisation/v2/internal/lru"
+	permsdk "github.com/ONSdigital/dp-permissions-api/sdk"
+)
+
+// DefaultVerificationCacheMaxEntries is the number of tokens held by a verification cache created with a max entries
+// value that is not positive
+const DefaultVerificationCacheMaxEntries = lru.DefaultMaxEntries
+
+// cachedResult is a verified token held in the verification cache, along with the key that verified its signature
+type cachedResult struct {
+	result ParseResult
+	kid    string
+	key    crypto.PublicKey
+}
+
+// WithVerificationCache caches the result of verifying each token, so that a token presented repeatedly is only
+// verified once. Results are cached for up to the ttl, and never beyond the expiry of the token. A cached result is
+// discarded if the key that verified it is no longer in the parser's key set, or has been replaced. Failed
+// verifications are not cached. Tokens are not held by the cache, entries are keyed on a SHA-256 hash of the token.
+// The least recently used token is evicted when the cache holds maxEntries tokens, the default being
+// DefaultVerificationCacheMaxEntries. A ttl of 0 disables the cache.
+func WithVerificationCache(maxEntries int, ttl time.Duration) ParserOption {
+	return func(p *CognitoRSAParser) {
+		p.cacheMaxEntries = maxEntries
+		p.cacheTTL = ttl
+	}
+}
+
+// newVerificationCache returns the verification cache configured by the parser options, or nil if it is disabled
+func (p *CognitoRSAParser) newVerificationCache() *lru.Cache[string, cachedResult] {
+	if p.cacheTTL <= 0 {
+		return nil
+	}
+	return lru.New[string, cachedResult](p.cacheMaxEntries, lru.WithClock(p.now))
+}
+
+// cachedResult returns the cached result of verifying the token with the given cache key, if it is still valid
+func (p CognitoRSAParser) cachedResult(key string) (*ParseResult, bool) {
+	entry, ok := p.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+
+	// the key has been rotated since the token was verified
+	publicKey, ok := p.keySet().publicKey(entry.kid)
+	if !ok || !publicKey.(interface{ Equal(crypto.PublicKey) bool }).Equal(entry.key) {
+		p.cache.Remove(key)
+		return nil, false
+	}
+
+	return entry.result.clone(), true
+}
+
+// cacheResult caches the result of verifying the token with the given cache key, until the cache ttl elapses or the
+// token expires
+func (p CognitoRSAParser) cacheResult(key string, result *ParseResult, kid string, publicKey crypto.PublicKey) {
+	ttl := p.cacheTTL
+	if expiresAt, ok := result.Claims.ExpiresAt(); ok {
+		ttl = min(ttl, expiresAt.Sub(p.now()))
+	}
+	if ttl <= 0 {
+		return
+	}
+
+	p.cache.Add(key, cachedResult{result: *result.clone(), kid: kid, key: publicKey}, ttl)
+}
+
+// clone deep copies the result, so that changes made to a result returned by the parser, including to nested claim
+// values, do not affect the cached result
+func (r *ParseResult) clone() *ParseResult {
+	return &ParseResult{
+		EntityData: permsdk.EntityData{
+			UserID: r.EntityData.UserID,
+			Groups: slices.Clone(r.EntityData.Groups),
+		},
+		ClientID: r.ClientID,
+		Claims:   cloneClaims(r.Claims),
+	}
+}
+
+// cloneClaims deep copies the claims. Claims are decoded from JSON, so the only values that can be modified are
+// objects and arrays.
+func cloneClaims(claims Claims) Claims {
+	if claims == nil {
+		return nil
+	}
+	return cloneClaimValue(map[string]interface{}(claims)).(map[string]interface{})
+}
+
+func cloneClaimValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		clone := make(map[string]interface{}, len(v))
+		for name, element := range v {
+			clone[name] = cloneClaimValue(element)
+		}
+		return clone
+	case []interface{}:
+		clone := make([]interface{}, len(v))
+		for i, element := range v {
+			clone[i] = cloneClaimValue(element)
+		}
+		return clone
+	default:
+		return value
+	}
+}
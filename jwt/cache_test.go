@@ -0,0 +1,131 @@
+package jwt_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-authorisation/v2/jwt"
+	gojwt "github.com/golang-jwt/jwt/v4"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCognitoRSAParser_Parse_VerificationCache(t *testing.T) {
+	issuedAt := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	Convey("Given a parser with a verification cache and a token valid for one minute", t, func() {
+		now := issuedAt
+		token, publicKeys := signTestToken(t, gojwt.MapClaims{
+			"username":       expectedUser,
+			"cognito:groups": []string{"admin"},
+			"nbf":            issuedAt.Unix(),
+			"exp":            issuedAt.Add(time.Minute).Unix(),
+		})
+		p, err := jwt.NewCognitoRSAParser(publicKeys,
+			jwt.WithVerificationCache(10, 10*time.Minute),
+			jwt.WithLeeway(5*time.Minute),
+			jwt.WithClock(func() time.Time { return now }),
+		)
+		So(err, ShouldBeNil)
+
+		entityData, err := p.Parse(token)
+		So(err, ShouldBeNil)
+
+		Convey("When the token is parsed again before it expires", func() {
+			entityData.Groups[0] = "modified"
+			// a token that had not been verified would be rejected as not yet valid
+			now = issuedAt.Add(-time.Hour)
+			result, err := p.ParseWithClaims(token)
+
+			Convey("Then the cached result is returned, unaffected by changes made by the caller", func() {
+				So(err, ShouldBeNil)
+				So(result.EntityData.UserID, ShouldEqual, expectedUser)
+				So(result.EntityData.Groups, ShouldResemble, []string{"admin"})
+				So(result.Claims.Subject(), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When the token is parsed after it expires", func() {
+			// the token is accepted within the leeway, but not cached again
+			now = issuedAt.Add(2 * time.Minute)
+			_, err := p.Parse(token)
+			So(err, ShouldBeNil)
+
+			now = issuedAt.Add(-time.Hour)
+			_, err = p.Parse(token)
+
+			Convey("Then the token is verified again", func() {
+				So(err, ShouldEqual, jwt.ErrTokenNotYetValid)
+			})
+		})
+
+		Convey("When the key that verified the token is rotated", func() {
+			rotatedKey, err := rsa.GenerateKey(rand.Reader, 2048)
+			So(err, ShouldBeNil)
+			p.PublicKeys["test-kid"] = &rotatedKey.PublicKey
+			_, err = p.Parse(token)
+
+			Convey("Then the token is verified again using the new key", func() {
+				So(err, ShouldEqual, jwt.ErrInvalidSignature)
+			})
+		})
+
+		Convey("When the key that verified the token is removed", func() {
+			delete(p.PublicKeys, "test-kid")
+			p.PublicKeys["other-kid"] = &rsa.PublicKey{}
+			_, err := p.Parse(token)
+
+			Convey("Then the token is rejected", func() {
+				So(err.Error(), ShouldEqual, jwt.ErrJWTKeySet.Error())
+			})
+		})
+	})
+
+	Convey("Given a parser with a verification cache and a token with nested claims", t, func() {
+		token, publicKeys := signTestToken(t, gojwt.MapClaims{
+			"username":       expectedUser,
+			"cognito:groups": []string{"admin"},
+			"address":        map[string]interface{}{"country": "UK"},
+			"scopes":         []string{"datasets:read"},
+			"exp":            time.Now().Add(time.Hour).Unix(),
+		})
+		p, err := jwt.NewCognitoRSAParser(publicKeys, jwt.WithVerificationCache(10, time.Minute))
+		So(err, ShouldBeNil)
+
+		result, err := p.ParseWithClaims(token)
+		So(err, ShouldBeNil)
+
+		Convey("When the caller modifies the nested claims and the token is parsed again", func() {
+			result.Claims["address"].(map[string]interface{})["country"] = "modified"
+			result.Claims["scopes"].([]interface{})[0] = "modified"
+			cached, err := p.ParseWithClaims(token)
+
+			Convey("Then the cached claims are unaffected", func() {
+				So(err, ShouldBeNil)
+				So(cached.Claims["address"], ShouldResemble, map[string]interface{}{"country": "UK"})
+				So(cached.Claims["scopes"], ShouldResemble, []interface{}{"datasets:read"})
+			})
+		})
+	})
+
+	Convey("Given a parser without a verification cache", t, func() {
+		now := issuedAt
+		token, publicKeys := signTestToken(t, gojwt.MapClaims{
+			"username":       expectedUser,
+			"cognito:groups": []string{"admin"},
+			"nbf":            issuedAt.Unix(),
+		})
+		p, err := jwt.NewCognitoRSAParser(publicKeys, jwt.WithClock(func() time.Time { return now }))
+		So(err, ShouldBeNil)
+
+		_, err = p.Parse(token)
+		So(err, ShouldBeNil)
+
+		Convey("Then the token is verified every time it is parsed", func() {
+			now = issuedAt.Add(-time.Hour)
+			_, err := p.Parse(token)
+			So(err, ShouldEqual, jwt.ErrTokenNotYetValid)
+		})
+	})
+}
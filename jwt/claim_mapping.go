@@ -0,0 +1,133 @@
+package jwt
+
+import (
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Claims read by default from AWS Cognito tokens
+const (
+	CognitoUserIDClaim = "username"
+	CognitoGroupsClaim = "cognito:groups"
+)
+
+// GroupTransform maps a group name read from a token to the group name used in permissions policies. Groups for
+// which false is returned are ignored.
+type GroupTransform func(group string) (string, bool)
+
+// ClaimMapping describes how the user ID and groups of the entity data are read from the claims of a token, so that
+// tokens issued by identity providers other than Cognito can be used.
+type ClaimMapping struct {
+	// UserIDClaim is the claim containing the user ID, e.g. 'username', 'preferred_username' or 'sub'
+	UserIDClaim string
+	// GroupsClaim is the claim containing the list of groups, e.g. 'cognito:groups', 'groups' or 'roles'. A claim
+	// nested within an object is given as a dot separated path, e.g. 'realm_access.roles'.
+	GroupsClaim string
+	// GroupTransform, if set, is applied to each group name read from the token
+	GroupTransform GroupTransform
+	// GroupsRequired rejects tokens without the groups claim with ErrNoGroups. Otherwise, a token without the claim
+	// has no groups.
+	GroupsRequired bool
+}
+
+// DefaultClaimMapping returns the claim mapping for AWS Cognito tokens, which requires the 'username' and
+// 'cognito:groups' claims
+func DefaultClaimMapping() ClaimMapping {
+	return ClaimMapping{
+		UserIDClaim:    CognitoUserIDClaim,
+		GroupsClaim:    CognitoGroupsClaim,
+		GroupsRequired: true,
+	}
+}
+
+// TrimGroupPrefix returns a GroupTransform that removes the given prefix from group names, e.g. to map the Entra ID
+// role 'dp.publisher' to the 'publisher' group. If onlyPrefixed is true, groups without the prefix are ignored.
+func TrimGroupPrefix(prefix string, onlyPrefixed bool) GroupTransform {
+	return func(group string) (string, bool) {
+		trimmed, found := strings.CutPrefix(group, prefix)
+		if !found && onlyPrefixed {
+			return "", false
+		}
+		return trimmed, true
+	}
+}
+
+// userID returns the user ID claim of the token
+func (m ClaimMapping) userID(claims jwt.MapClaims) (string, bool) {
+	userID, ok := claims[m.userIDClaim()].(string)
+	return userID, ok && userID != ""
+}
+
+// hasUserID returns true if the token has a user ID claim, of any type
+func (m ClaimMapping) hasUserID(claims jwt.MapClaims) bool {
+	_, ok := claims[m.userIDClaim()]
+	return ok
+}
+
+// groups returns the transformed groups in the groups claim of the token, and false if the token does not have a
+// groups claim containing a list of groups
+func (m ClaimMapping) groups(claims jwt.MapClaims) ([]string, bool) {
+	var names []interface{}
+	switch value := lookupClaim(claims, m.groupsClaim()).(type) {
+	case []interface{}:
+		names = value
+	case string:
+		names = []interface{}{value}
+	default:
+		return nil, false
+	}
+
+	var groups []string
+	for _, group := range mapToStringArray(names) {
+		if m.GroupTransform != nil {
+			var ok bool
+			if group, ok = m.GroupTransform(group); !ok {
+				continue
+			}
+		}
+		if group != "" {
+			groups = append(groups, group)
+		}
+	}
+	return groups, true
+}
+
+// hasGroups returns true if the token has a groups claim, of any type
+func (m ClaimMapping) hasGroups(claims jwt.MapClaims) bool {
+	return lookupClaim(claims, m.groupsClaim()) != nil
+}
+
+func (m ClaimMapping) userIDClaim() string {
+	if m.UserIDClaim == "" {
+		return CognitoUserIDClaim
+	}
+	return m.UserIDClaim
+}
+
+func (m ClaimMapping) groupsClaim() string {
+	if m.GroupsClaim == "" {
+		return CognitoGroupsClaim
+	}
+	return m.GroupsClaim
+}
+
+// lookupClaim returns the value of the named claim. If there is no top level claim with the name, it is treated as
+// a dot separated path to a claim nested within objects.
+func lookupClaim(claims jwt.MapClaims, name string) interface{} {
+	if value, ok := claims[name]; ok {
+		return value
+	}
+
+	var value interface{} = map[string]interface{}(claims)
+	for _, key := range strings.Split(name, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		if value, ok = object[key]; !ok {
+			return nil
+		}
+	}
+	return value
+}
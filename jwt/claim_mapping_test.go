@@ -0,0 +1,122 @@
+package jwt_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-authorisation/v2/jwt"
+	gojwt "github.com/golang-jwt/jwt/v4"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCognitoRSAParser_Parse_ClaimMapping(t *testing.T) {
+	Convey("Given a Keycloak token with the user in preferred_username and roles nested in realm_access", t, func() {
+		token, publicKeys := signTestToken(t, gojwt.MapClaims{
+			"sub":                "f4e1b2c3",
+			"preferred_username": "janedoe",
+			"realm_access":       map[string]interface{}{"roles": []string{"dp-publisher", "offline_access"}},
+			"exp":                time.Now().Add(time.Hour).Unix(),
+		})
+
+		Convey("When it is parsed with a matching claim mapping that strips the group prefix", func() {
+			p, err := jwt.NewCognitoRSAParser(publicKeys, jwt.WithClaimMapping(jwt.ClaimMapping{
+				UserIDClaim:    "preferred_username",
+				GroupsClaim:    "realm_access.roles",
+				GroupTransform: jwt.TrimGroupPrefix("dp-", true),
+				GroupsRequired: true,
+			}))
+			So(err, ShouldBeNil)
+			entityData, err := p.Parse(token)
+
+			Convey("Then the user ID and the prefixed groups are returned", func() {
+				So(err, ShouldBeNil)
+				So(entityData.UserID, ShouldEqual, "janedoe")
+				So(entityData.Groups, ShouldResemble, []string{"publisher"})
+			})
+		})
+
+		Convey("When it is parsed with the default claim mapping", func() {
+			p, err := jwt.NewCognitoRSAParser(publicKeys)
+			So(err, ShouldBeNil)
+			_, err = p.Parse(token)
+
+			Convey("Then ErrNoUserID is returned", func() {
+				So(err, ShouldEqual, jwt.ErrNoUserID)
+			})
+		})
+	})
+
+	Convey("Given a token without a groups claim", t, func() {
+		token, publicKeys := signTestToken(t, gojwt.MapClaims{
+			"sub": "f4e1b2c3",
+			"exp": time.Now().Add(time.Hour).Unix(),
+		})
+
+		Convey("When it is parsed with a claim mapping where groups are optional", func() {
+			p, err := jwt.NewCognitoRSAParser(publicKeys, jwt.WithClaimMapping(jwt.ClaimMapping{UserIDClaim: "sub", GroupsClaim: "groups"}))
+			So(err, ShouldBeNil)
+			entityData, err := p.Parse(token)
+
+			Convey("Then the user is returned without any groups", func() {
+				So(err, ShouldBeNil)
+				So(entityData.UserID, ShouldEqual, "f4e1b2c3")
+				So(entityData.Groups, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When it is parsed with a claim mapping where groups are required", func() {
+			p, err := jwt.NewCognitoRSAParser(publicKeys, jwt.WithClaimMapping(jwt.ClaimMapping{UserIDClaim: "sub", GroupsClaim: "groups", GroupsRequired: true}))
+			So(err, ShouldBeNil)
+			_, err = p.Parse(token)
+
+			Convey("Then ErrNoGroups is returned", func() {
+				So(err, ShouldEqual, jwt.ErrNoGroups)
+			})
+		})
+	})
+
+	Convey("Given a token with a top level claim name containing dots", t, func() {
+		token, publicKeys := signTestToken(t, gojwt.MapClaims{
+			"username":                   expectedUser,
+			"https://example.com/groups": []string{"admin"},
+			"exp":                        time.Now().Add(time.Hour).Unix(),
+		})
+
+		Convey("When it is parsed with the claim name as the groups claim", func() {
+			p, err := jwt.NewCognitoRSAParser(publicKeys, jwt.WithClaimMapping(jwt.ClaimMapping{GroupsClaim: "https://example.com/groups"}))
+			So(err, ShouldBeNil)
+			entityData, err := p.Parse(token)
+
+			Convey("Then the top level claim is used", func() {
+				So(err, ShouldBeNil)
+				So(entityData.UserID, ShouldEqual, expectedUser)
+				So(entityData.Groups, ShouldResemble, []string{"admin"})
+			})
+		})
+	})
+}
+
+func TestTrimGroupPrefix(t *testing.T) {
+	Convey("Given a group prefix transform that keeps groups without the prefix", t, func() {
+		transform := jwt.TrimGroupPrefix("dp.", false)
+
+		Convey("Then the prefix is removed, and other groups are unchanged", func() {
+			group, ok := transform("dp.admin")
+			So(ok, ShouldBeTrue)
+			So(group, ShouldEqual, "admin")
+
+			group, ok = transform("other")
+			So(ok, ShouldBeTrue)
+			So(group, ShouldEqual, "other")
+		})
+	})
+
+	Convey("Given a group prefix transform that only keeps groups with the prefix", t, func() {
+		transform := jwt.TrimGroupPrefix("dp.", true)
+
+		Convey("Then groups without the prefix are ignored", func() {
+			_, ok := transform("other")
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,135 @@
+package jwt
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	permsdk "github.com/ONSdigital/dp-permissions-api/sdk"
+)
+
+// ParseResult is the result of verifying a JWT token: the entity data used to check permissions, and all the
+// verified claims of the token. A token issued to a service using the client credentials grant has the client ID of
+// the service, and empty entity data.
+type ParseResult struct {
+	EntityData permsdk.EntityData
+	ClientID   string
+	Claims     Claims
+}
+
+// Claims are the claims of a JWT token, with typed accessors for the standard and Cognito claims. Custom claims
+// can be read using the generic accessors, e.g. claims.String("custom:team").
+type Claims map[string]interface{}
+
+// Value returns the value of the named claim. If there is no top level claim with the name, it is treated as a dot
+// separated path to a claim nested within objects.
+func (c Claims) Value(name string) (interface{}, bool) {
+	value := lookupClaim(map[string]interface{}(c), name)
+	return value, value != nil
+}
+
+// String returns the value of the named string claim, and false if the claim is missing or not a string
+func (c Claims) String(name string) (string, bool) {
+	value, _ := c.Value(name)
+	s, ok := value.(string)
+	return s, ok
+}
+
+// Strings returns the values of the named claim, which may be a list of strings or a single string
+func (c Claims) Strings(name string) ([]string, bool) {
+	value, _ := c.Value(name)
+	switch v := value.(type) {
+	case string:
+		return []string{v}, true
+	case []interface{}:
+		return mapToStringArray(v), true
+	case []string:
+		return v, true
+	default:
+		return nil, false
+	}
+}
+
+// Time returns the value of the named NumericDate claim, i.e. a number of seconds since the Unix epoch
+func (c Claims) Time(name string) (time.Time, bool) {
+	t, ok, err := numericDate(map[string]interface{}(c), name)
+	return t, ok && err == nil
+}
+
+// Int64 returns the value of the named integer claim
+func (c Claims) Int64(name string) (int64, bool) {
+	value, _ := c.Value(name)
+	switch v := value.(type) {
+	case json.Number:
+		i, err := v.Int64()
+		return i, err == nil
+	case float64:
+		return int64(v), v == float64(int64(v))
+	default:
+		return 0, false
+	}
+}
+
+// Bool returns the value of the named boolean claim, e.g. 'email_verified'
+func (c Claims) Bool(name string) (value, ok bool) {
+	v, _ := c.Value(name)
+	value, ok = v.(bool)
+	return value, ok
+}
+
+// Subject returns the 'sub' claim
+func (c Claims) Subject() string {
+	s, _ := c.String("sub")
+	return s
+}
+
+// Issuer returns the 'iss' claim
+func (c Claims) Issuer() string {
+	s, _ := c.String("iss")
+	return s
+}
+
+// Audience returns the values of the 'aud' claim
+func (c Claims) Audience() []string {
+	audience, _ := c.Strings("aud")
+	return audience
+}
+
+// ClientID returns the 'client_id' claim of an access token
+func (c Claims) ClientID() string {
+	s, _ := c.String("client_id")
+	return s
+}
+
+// TokenUse returns the Cognito 'token_use' claim, i.e. 'access' or 'id'
+func (c Claims) TokenUse() string {
+	s, _ := c.String("token_use")
+	return s
+}
+
+// Email returns the 'email' claim
+func (c Claims) Email() string {
+	s, _ := c.String("email")
+	return s
+}
+
+// Scopes returns the space separated values of the 'scope' claim
+func (c Claims) Scopes() []string {
+	s, _ := c.String("scope")
+	return strings.Fields(s)
+}
+
+// ExpiresAt returns the time of the 'exp' claim, and false if the token does not expire
+func (c Claims) ExpiresAt() (time.Time, bool) {
+	return c.Time("exp")
+}
+
+// IssuedAt returns the time of the 'iat' claim
+func (c Claims) IssuedAt() (time.Time, bool) {
+	return c.Time("iat")
+}
+
+// AuthTime returns the time of the 'auth_time' claim, when the user authenticated
+func (c Claims) AuthTime() (time.Time, bool) {
+	return c.Time("auth_time")
+}
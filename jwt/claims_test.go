@@ -0,0 +1,77 @@
+package jwt_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-authorisation/v2/jwt"
+	gojwt "github.com/golang-jwt/jwt/v4"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCognitoRSAParser_ParseWithClaims(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	Convey("Given a signed token with standard and custom claims", t, func() {
+		token, publicKeys := signTestToken(t, gojwt.MapClaims{
+			"sub":            "aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee",
+			"username":       expectedUser,
+			"cognito:groups": []string{"admin", "publishing"},
+			"email":          "janedoe@example.com",
+			"email_verified": true,
+			"client_id":      "57cbishk4j24pabc1234567890",
+			"token_use":      "access",
+			"scope":          "aws.cognito.signin.user.admin dp-api/read",
+			"auth_time":      1562190524,
+			"exp":            expiresAt.Unix(),
+			"custom:team":    "digital",
+			"profile":        map[string]interface{}{"department": "dissemination"},
+		})
+		p, err := jwt.NewCognitoRSAParser(publicKeys)
+		So(err, ShouldBeNil)
+
+		Convey("When ParseWithClaims is called", func() {
+			result, err := p.ParseWithClaims(token)
+			So(err, ShouldBeNil)
+
+			Convey("Then the entity data is returned", func() {
+				So(result.EntityData.UserID, ShouldEqual, expectedUser)
+				So(result.EntityData.Groups, ShouldResemble, []string{"admin", "publishing"})
+			})
+
+			Convey("Then the standard claims are available from the typed accessors", func() {
+				claims := result.Claims
+				So(claims.Subject(), ShouldEqual, "aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee")
+				So(claims.Email(), ShouldEqual, "janedoe@example.com")
+				So(claims.ClientID(), ShouldEqual, "57cbishk4j24pabc1234567890")
+				So(claims.TokenUse(), ShouldEqual, jwt.TokenUseAccess)
+				So(claims.Scopes(), ShouldResemble, []string{"aws.cognito.signin.user.admin", "dp-api/read"})
+
+				exp, ok := claims.ExpiresAt()
+				So(ok, ShouldBeTrue)
+				So(exp.Equal(expiresAt), ShouldBeTrue)
+
+				authTime, ok := claims.AuthTime()
+				So(ok, ShouldBeTrue)
+				So(authTime.Unix(), ShouldEqual, 1562190524)
+
+				verified, ok := claims.Bool("email_verified")
+				So(ok, ShouldBeTrue)
+				So(verified, ShouldBeTrue)
+			})
+
+			Convey("Then custom and nested claims are available", func() {
+				team, ok := result.Claims.String("custom:team")
+				So(ok, ShouldBeTrue)
+				So(team, ShouldEqual, "digital")
+
+				department, ok := result.Claims.String("profile.department")
+				So(ok, ShouldBeTrue)
+				So(department, ShouldEqual, "dissemination")
+
+				_, ok = result.Claims.String("missing")
+				So(ok, ShouldBeFalse)
+			})
+		})
+	})
+}
@@ -0,0 +1,290 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/pkg/errors"
+)
+
+// Values of the JSON Web Key fields used when parsing a key set
+const (
+	KeyTypeRSA   = "RSA"
+	KeyTypeEC    = "EC"
+	KeyTypeOKP   = "OKP"
+	KeyUseSig    = "sig"
+	CurveP256    = "P-256"
+	CurveP384    = "P-384"
+	CurveP521    = "P-521"
+	CurveEd25519 = "Ed25519"
+)
+
+// maxRSAExponentLength is the maximum length in bytes of an RSA public exponent that fits in an int
+const maxRSAExponentLength = 4
+
+var (
+	ErrInvalidJSONWebKeySet = errors.New("invalid json web key set")
+	ErrAlgorithmNotAllowed  = errors.New("jwt signing algorithm is not allowed for the key")
+)
+
+// errUnsupportedKey is returned for keys of a type or curve that cannot be used to verify tokens. These keys are
+// ignored, so that a key set can contain keys for other purposes.
+var errUnsupportedKey = errors.New("unsupported key type")
+
+// JSONWebKey is a public key in a JSON Web Key Set, as defined by RFC 7517. Only the fields used to verify
+// JWT signatures are read.
+type JSONWebKey struct {
+	KeyType   string   `json:"kty"`
+	KeyID     string   `json:"kid,omitempty"`
+	Use       string   `json:"use,omitempty"`
+	Algorithm string   `json:"alg,omitempty"`
+	N         string   `json:"n,omitempty"`
+	E         string   `json:"e,omitempty"`
+	Curve     string   `json:"crv,omitempty"`
+	X         string   `json:"x,omitempty"`
+	Y         string   `json:"y,omitempty"`
+	X5c       []string `json:"x5c,omitempty"`
+}
+
+// JSONWebKeySet is a JSON Web Key Set document, such as the one published by a Cognito user pool
+// at https://cognito-idp.{region}.amazonaws.com/{userPoolId}/.well-known/jwks.json
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// ParseJSONWebKeySet unmarshals a JSON Web Key Set document
+func ParseJSONWebKeySet(data []byte) (*JSONWebKeySet, error) {
+	var document struct {
+		Keys *[]JSONWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidJSONWebKeySet, err)
+	}
+	if document.Keys == nil {
+		return nil, fmt.Errorf("%w: no keys field", ErrInvalidJSONWebKeySet)
+	}
+	return &JSONWebKeySet{Keys: *document.Keys}, nil
+}
+
+// IsJSONWebKeySet returns true if the given document is a JSON Web Key Set rather than a map of key IDs to
+// base64 encoded public keys
+func IsJSONWebKeySet(data []byte) bool {
+	var document map[string]json.RawMessage
+	if err := json.Unmarshal(data, &document); err != nil {
+		return false
+	}
+	keys, ok := document["keys"]
+	return ok && len(keys) > 0 && keys[0] == '['
+}
+
+// VerificationKeys returns the signing keys in the key set, mapped by key ID. Keys that are not signing keys, or
+// that are of an unsupported type, curve or algorithm, are ignored. Invalid keys, e.g. a malformed key, one with an
+// 'alg' value that does not match its type or one without a key ID, are logged and ignored, so that one bad key does
+// not prevent the others from being used. If two keys have the same key ID, tokens could not be matched to the key
+// that signed them, so both are logged and ignored. A key with an 'alg' value may only be used with that algorithm,
+// otherwise the default algorithms for the key type are allowed, see NewVerificationKey. An error is only returned
+// if keys were ignored as invalid and no usable keys remain.
+func (s *JSONWebKeySet) VerificationKeys() (map[string]VerificationKey, error) {
+	keys := map[string]VerificationKey{}
+	keyIDs := map[string]int{}
+	for i := range s.Keys {
+		keyIDs[s.Keys[i].KeyID]++
+	}
+
+	invalid := 0
+	for i := range s.Keys {
+		key := &s.Keys[i]
+		if key.KeyID == "" {
+			log.Warn(context.Background(), "ignoring json web key without a key id", log.Data{"index": i})
+			invalid++
+			continue
+		}
+		if keyIDs[key.KeyID] > 1 {
+			log.Warn(context.Background(), "ignoring json web key with a duplicate key id", log.Data{"kid": key.KeyID})
+			invalid++
+			continue
+		}
+
+		if key.Use != "" && key.Use != KeyUseSig {
+			continue
+		}
+		if key.Algorithm != "" && !isSupportedAlgorithm(key.Algorithm) {
+			continue
+		}
+
+		verificationKey, err := key.verificationKey()
+		if errors.Is(err, errUnsupportedKey) {
+			continue
+		}
+		if err != nil {
+			log.Warn(context.Background(), "ignoring invalid json web key", log.Data{"kid": key.KeyID, "error": err.Error()})
+			invalid++
+			continue
+		}
+		keys[key.KeyID] = verificationKey
+	}
+
+	if invalid > 0 && len(keys) == 0 {
+		return nil, fmt.Errorf("%w: no usable keys, %d invalid keys ignored", ErrInvalidJSONWebKeySet, invalid)
+	}
+	return keys, nil
+}
+
+// NewCognitoRSAParserFromJWKS creates a new instance of CognitoRSAParser using the signing keys in the given
+// JSON Web Key Set, see JSONWebKeySet.VerificationKeys.
+func NewCognitoRSAParserFromJWKS(keySet *JSONWebKeySet, opts ...ParserOption) (*CognitoRSAParser, error) {
+	keys, err := keySet.VerificationKeys()
+	if err != nil {
+		return nil, err
+	}
+	return NewCognitoRSAParserFromKeys(keys, opts...)
+}
+
+// verificationKey returns the public key, with the algorithms it may be used with
+func (k *JSONWebKey) verificationKey() (VerificationKey, error) {
+	publicKey, err := k.publicKey()
+	if err != nil {
+		return VerificationKey{}, err
+	}
+	var algorithms []string
+	if k.Algorithm != "" {
+		algorithms = []string{k.Algorithm}
+	}
+	return NewVerificationKey(publicKey, algorithms...)
+}
+
+// publicKey returns the public key from the key parameters, or from the first certificate in the 'x5c' chain.
+// If both are given they must contain the same key.
+func (k *JSONWebKey) publicKey() (crypto.PublicKey, error) {
+	var publicKey crypto.PublicKey
+	var err error
+
+	switch k.KeyType {
+	case KeyTypeRSA:
+		if k.N != "" || k.E != "" {
+			publicKey, err = k.rsaPublicKey()
+		}
+	case KeyTypeEC:
+		if k.X != "" || k.Y != "" {
+			publicKey, err = k.ecdsaPublicKey()
+		}
+	case KeyTypeOKP:
+		if k.X != "" {
+			publicKey, err = k.ed25519PublicKey()
+		}
+	default:
+		return nil, errUnsupportedKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(k.X5c) > 0 {
+		certificatePublicKey, err := k.certificatePublicKey()
+		if err != nil {
+			return nil, err
+		}
+		if publicKey != nil && !publicKey.(interface{ Equal(crypto.PublicKey) bool }).Equal(certificatePublicKey) {
+			return nil, errors.New("x5c certificate does not match the key parameters")
+		}
+		publicKey = certificatePublicKey
+	}
+
+	if publicKey == nil {
+		return nil, errors.New("no key parameters or x5c certificate")
+	}
+	return publicKey, nil
+}
+
+// rsaPublicKey returns the RSA public key from the 'n' and 'e' parameters
+func (k *JSONWebKey) rsaPublicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil || len(n) == 0 {
+		return nil, errors.New("invalid modulus")
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil || len(e) == 0 || len(e) > maxRSAExponentLength {
+		return nil, errors.New("invalid exponent")
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
+
+// ecdsaPublicKey returns the ECDSA public key from the 'crv', 'x' and 'y' parameters
+func (k *JSONWebKey) ecdsaPublicKey() (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	var ecdhCurve ecdh.Curve
+	switch k.Curve {
+	case CurveP256:
+		curve, ecdhCurve = elliptic.P256(), ecdh.P256()
+	case CurveP384:
+		curve, ecdhCurve = elliptic.P384(), ecdh.P384()
+	case CurveP521:
+		curve, ecdhCurve = elliptic.P521(), ecdh.P521()
+	default:
+		return nil, errUnsupportedKey
+	}
+
+	size := (curve.Params().BitSize + 7) / 8
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil || len(x) != size {
+		return nil, errors.New("invalid x coordinate")
+	}
+	y, err := base64.RawURLEncoding.DecodeString(k.Y)
+	if err != nil || len(y) != size {
+		return nil, errors.New("invalid y coordinate")
+	}
+
+	// check the point is on the curve, using its uncompressed encoding
+	point := append(append([]byte{4}, x...), y...)
+	if _, err := ecdhCurve.NewPublicKey(point); err != nil {
+		return nil, errors.New("point is not on the curve")
+	}
+
+	return &ecdsa.PublicKey{
+		Curve: curve,
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	}, nil
+}
+
+// ed25519PublicKey returns the Ed25519 public key from the 'crv' and 'x' parameters
+func (k *JSONWebKey) ed25519PublicKey() (ed25519.PublicKey, error) {
+	if k.Curve != CurveEd25519 {
+		return nil, errUnsupportedKey
+	}
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil || len(x) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid public key")
+	}
+	return ed25519.PublicKey(x), nil
+}
+
+// certificatePublicKey returns the public key of the first certificate in the 'x5c' chain
+func (k *JSONWebKey) certificatePublicKey() (crypto.PublicKey, error) {
+	der, err := base64.StdEncoding.DecodeString(k.X5c[0])
+	if err != nil {
+		return nil, err
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := algorithmsForKey(certificate.PublicKey); err != nil {
+		return nil, err
+	}
+	return certificate.PublicKey, nil
+}
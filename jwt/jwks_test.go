@@ -0,0 +1,170 @@
+package jwt_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-authorisation/v2/jwt"
+	gojwt "github.com/golang-jwt/jwt/v4"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseJSONWebKeySet(t *testing.T) {
+	Convey("Given a JSON Web Key Set document", t, func() {
+		document := []byte(`{"keys":[{"kty":"RSA","kid":"abc","use":"sig","alg":"RS256","n":"AQAB","e":"AQAB"}]}`)
+
+		Convey("Then it is identified as a key set and parsed", func() {
+			So(jwt.IsJSONWebKeySet(document), ShouldBeTrue)
+			keySet, err := jwt.ParseJSONWebKeySet(document)
+			So(err, ShouldBeNil)
+			So(keySet.Keys, ShouldHaveLength, 1)
+			So(keySet.Keys[0].KeyID, ShouldEqual, "abc")
+			So(keySet.Keys[0].Algorithm, ShouldEqual, "RS256")
+		})
+	})
+
+	Convey("Given an identity service key map", t, func() {
+		document := []byte(`{"abc":"MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEAu1SU1LfVLPHCozMxH2Mo4lgOEePzNm0tRgeLezV6ffAt0gunVTLw7onLRnrq0"}`)
+
+		Convey("Then it is not identified as a key set", func() {
+			So(jwt.IsJSONWebKeySet(document), ShouldBeFalse)
+			_, err := jwt.ParseJSONWebKeySet(document)
+			So(errors.Is(err, jwt.ErrInvalidJSONWebKeySet), ShouldBeTrue)
+		})
+	})
+}
+
+func TestNewCognitoRSAParserFromJWKS(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := signTestTokenWithKey(t, privateKey, gojwt.SigningMethodRS256, "test-kid")
+
+	Convey("Given a key set containing the signing key as 'n' and 'e' parameters", t, func() {
+		document := marshalTestJSONWebKeySet(t, &jwt.JSONWebKeySet{Keys: []jwt.JSONWebKey{newTestJSONWebKey(&privateKey.PublicKey, "test-kid", "RS256")}})
+		keySet, err := jwt.ParseJSONWebKeySet(document)
+		So(err, ShouldBeNil)
+		p, err := jwt.NewCognitoRSAParserFromJWKS(keySet)
+		So(err, ShouldBeNil)
+
+		Convey("Then a token signed with the key is verified", func() {
+			entityData, err := p.Parse(token)
+			So(err, ShouldBeNil)
+			So(entityData.UserID, ShouldEqual, expectedUser)
+		})
+	})
+
+	Convey("Given a key set containing the signing key as an x5c certificate", t, func() {
+		key := jwt.JSONWebKey{KeyType: jwt.KeyTypeRSA, KeyID: "test-kid", X5c: []string{newTestCertificate(t, privateKey)}}
+		p, err := jwt.NewCognitoRSAParserFromJWKS(&jwt.JSONWebKeySet{Keys: []jwt.JSONWebKey{key}})
+		So(err, ShouldBeNil)
+
+		Convey("Then a token signed with the key is verified", func() {
+			_, err := p.Parse(token)
+			So(err, ShouldBeNil)
+		})
+	})
+
+	Convey("Given a key set where the x5c certificate does not match the key parameters", t, func() {
+		otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		So(err, ShouldBeNil)
+		key := newTestJSONWebKey(&privateKey.PublicKey, "test-kid", "RS256")
+		key.X5c = []string{newTestCertificate(t, otherKey)}
+
+		otherJWK := newTestJSONWebKey(&otherKey.PublicKey, "other-kid", "RS256")
+
+		Convey("Then the key is not used to verify tokens", func() {
+			p, err := jwt.NewCognitoRSAParserFromJWKS(&jwt.JSONWebKeySet{Keys: []jwt.JSONWebKey{key, otherJWK}})
+			So(err, ShouldBeNil)
+			So(p.KeySet().KeyIDs(), ShouldResemble, []string{"other-kid"})
+		})
+
+		Convey("Then an error is returned if it is the only key", func() {
+			_, err := jwt.NewCognitoRSAParserFromJWKS(&jwt.JSONWebKeySet{Keys: []jwt.JSONWebKey{key}})
+			So(errors.Is(err, jwt.ErrInvalidJSONWebKeySet), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given a key set where the key is published for encryption rather than signing", t, func() {
+		key := newTestJSONWebKey(&privateKey.PublicKey, "test-kid", "")
+		key.Use = "enc"
+		p, err := jwt.NewCognitoRSAParserFromJWKS(&jwt.JSONWebKeySet{Keys: []jwt.JSONWebKey{key}})
+		So(err, ShouldBeNil)
+
+		Convey("Then the key is not used to verify tokens", func() {
+			_, err := p.Parse(token)
+			So(err, ShouldEqual, jwt.ErrPublickeysEmpty)
+		})
+	})
+
+	Convey("Given a key set where the key is published for a different algorithm", t, func() {
+		keySet := &jwt.JSONWebKeySet{Keys: []jwt.JSONWebKey{newTestJSONWebKey(&privateKey.PublicKey, "test-kid", "RS512")}}
+		p, err := jwt.NewCognitoRSAParserFromJWKS(keySet)
+		So(err, ShouldBeNil)
+
+		Convey("Then a token signed with RS256 is rejected", func() {
+			_, err := p.Parse(token)
+			So(errors.Is(err, jwt.ErrAlgorithmNotAllowed), ShouldBeTrue)
+		})
+	})
+}
+
+// signTestTokenWithKey signs a JWT for a user with the given key, signing method and key ID
+func signTestTokenWithKey(t *testing.T, privateKey interface{}, method gojwt.SigningMethod, kid string) string {
+	token := gojwt.NewWithClaims(method, gojwt.MapClaims{
+		"username":       expectedUser,
+		"cognito:groups": []string{"admin"},
+		"exp":            time.Now().Add(time.Hour).Unix(),
+	})
+	token.Header[jwt.Kid] = kid
+	signedToken, err := token.SignedString(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signedToken
+}
+
+// newTestJSONWebKey returns the JSON Web Key for an RSA public key
+func newTestJSONWebKey(publicKey *rsa.PublicKey, kid, alg string) jwt.JSONWebKey {
+	return jwt.JSONWebKey{
+		KeyType:   jwt.KeyTypeRSA,
+		KeyID:     kid,
+		Use:       jwt.KeyUseSig,
+		Algorithm: alg,
+		N:         base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+		E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+	}
+}
+
+// newTestCertificate returns a base64 encoded self-signed certificate for the key, as used in the x5c field
+func newTestCertificate(t *testing.T, privateKey *rsa.PrivateKey) string {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &privateKey.PublicKey, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(der)
+}
+
+// marshalTestJSONWebKeySet returns the JSON document for the key set
+func marshalTestJSONWebKeySet(t *testing.T, keySet *jwt.JSONWebKeySet) []byte {
+	document, err := json.Marshal(keySet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return document
+}
@@ -0,0 +1,111 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"fmt"
+	"slices"
+)
+
+// Signing algorithms supported by the parser, as named in the JWT 'alg' header
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmRS384 = "RS384"
+	AlgorithmRS512 = "RS512"
+	AlgorithmPS256 = "PS256"
+	AlgorithmPS384 = "PS384"
+	AlgorithmPS512 = "PS512"
+	AlgorithmES256 = "ES256"
+	AlgorithmES384 = "ES384"
+	AlgorithmES512 = "ES512"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// supportedAlgorithms are the algorithms that tokens can be signed with. Symmetric algorithms are never supported.
+var supportedAlgorithms = []string{
+	AlgorithmRS256, AlgorithmRS384, AlgorithmRS512,
+	AlgorithmPS256, AlgorithmPS384, AlgorithmPS512,
+	AlgorithmES256, AlgorithmES384, AlgorithmES512,
+	AlgorithmEdDSA,
+}
+
+// VerificationKey is a public key used to verify the signature of JWT tokens, along with the signing algorithms
+// the key may be used with. A token signed with any other algorithm is rejected, even if the signature would verify.
+type VerificationKey struct {
+	Key        crypto.PublicKey
+	Algorithms []string
+}
+
+// NewVerificationKey returns a VerificationKey for an RSA, ECDSA or Ed25519 public key. If no algorithms are given,
+// the key may be used with the default algorithms for its type: RS256, RS384 and RS512 for RSA keys, the algorithm
+// matching the curve for ECDSA keys, and EdDSA for Ed25519 keys.
+func NewVerificationKey(key crypto.PublicKey, algorithms ...string) (VerificationKey, error) {
+	compatible, defaults, err := algorithmsForKey(key)
+	if err != nil {
+		return VerificationKey{}, err
+	}
+	if len(algorithms) == 0 {
+		return VerificationKey{Key: key, Algorithms: defaults}, nil
+	}
+	for _, algorithm := range algorithms {
+		if !slices.Contains(compatible, algorithm) {
+			return VerificationKey{}, fmt.Errorf("%w: %s cannot be used with a %T", ErrAlgorithmNotAllowed, algorithm, key)
+		}
+	}
+	return VerificationKey{Key: key, Algorithms: slices.Clone(algorithms)}, nil
+}
+
+// ParseVerificationKeys parses a map of key IDs to base64 encoded public keys, as returned by the identity service.
+// Each key may be used with the default algorithms for its type.
+func ParseVerificationKeys(base64EncodedPublicKeys map[string]string) (map[string]VerificationKey, error) {
+	keys := make(map[string]VerificationKey, len(base64EncodedPublicKeys))
+	for kid, encodedPublicKey := range base64EncodedPublicKeys {
+		publicKey, err := parsePublicKey(encodedPublicKey)
+		if err != nil {
+			return nil, ErrFailedToParsePublicKey
+		}
+		if keys[kid], err = NewVerificationKey(publicKey); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// allows returns true if the key may be used to verify a token signed with the given algorithm
+func (k VerificationKey) allows(algorithm string) bool {
+	return slices.Contains(k.Algorithms, algorithm)
+}
+
+// isSupportedAlgorithm returns true if the parser can verify tokens signed with the given algorithm
+func isSupportedAlgorithm(algorithm string) bool {
+	return slices.Contains(supportedAlgorithms, algorithm)
+}
+
+// algorithmsForKey returns the algorithms that can be used with a public key, and those that are allowed by default
+func algorithmsForKey(key crypto.PublicKey) (compatible, defaults []string, err error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return []string{AlgorithmRS256, AlgorithmRS384, AlgorithmRS512, AlgorithmPS256, AlgorithmPS384, AlgorithmPS512},
+			[]string{AlgorithmRS256, AlgorithmRS384, AlgorithmRS512}, nil
+	case *ecdsa.PublicKey:
+		algorithm, ok := curveAlgorithms[k.Curve]
+		if !ok {
+			return nil, nil, ErrUnexpectedKeyType
+		}
+		return []string{algorithm}, []string{algorithm}, nil
+	case ed25519.PublicKey:
+		return []string{AlgorithmEdDSA}, []string{AlgorithmEdDSA}, nil
+	default:
+		return nil, nil, ErrUnexpectedKeyType
+	}
+}
+
+// curveAlgorithms maps the supported elliptic curves to the ECDSA algorithm that uses them
+var curveAlgorithms = map[elliptic.Curve]string{
+	elliptic.P256(): AlgorithmES256,
+	elliptic.P384(): AlgorithmES384,
+	elliptic.P521(): AlgorithmES512,
+}
@@ -0,0 +1,247 @@
+package jwt_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/ONSdigital/dp-authorisation/v2/jwt"
+	gojwt "github.com/golang-jwt/jwt/v4"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNewVerificationKey(t *testing.T) {
+	rsaKey, ecKey, edKey := newTestKeys(t)
+
+	Convey("Given keys without explicit algorithms", t, func() {
+		Convey("Then the default algorithms for each key type are allowed", func() {
+			key, err := jwt.NewVerificationKey(&rsaKey.PublicKey)
+			So(err, ShouldBeNil)
+			So(key.Algorithms, ShouldResemble, []string{jwt.AlgorithmRS256, jwt.AlgorithmRS384, jwt.AlgorithmRS512})
+
+			key, err = jwt.NewVerificationKey(&ecKey.PublicKey)
+			So(err, ShouldBeNil)
+			So(key.Algorithms, ShouldResemble, []string{jwt.AlgorithmES256})
+
+			key, err = jwt.NewVerificationKey(edKey.Public())
+			So(err, ShouldBeNil)
+			So(key.Algorithms, ShouldResemble, []string{jwt.AlgorithmEdDSA})
+		})
+	})
+
+	Convey("Given an algorithm that cannot be used with the key", t, func() {
+		Convey("Then an error is returned", func() {
+			_, err := jwt.NewVerificationKey(&ecKey.PublicKey, jwt.AlgorithmES384)
+			So(errors.Is(err, jwt.ErrAlgorithmNotAllowed), ShouldBeTrue)
+
+			_, err = jwt.NewVerificationKey(&rsaKey.PublicKey, "HS256")
+			So(errors.Is(err, jwt.ErrAlgorithmNotAllowed), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given a key of an unsupported type", t, func() {
+		Convey("Then an error is returned", func() {
+			_, err := jwt.NewVerificationKey([]byte("secret"))
+			So(err, ShouldEqual, jwt.ErrUnexpectedKeyType)
+		})
+	})
+}
+
+func TestCognitoRSAParser_Parse_SigningAlgorithms(t *testing.T) {
+	rsaKey, ecKey, edKey := newTestKeys(t)
+
+	Convey("Given a parser with RSA, ECDSA and Ed25519 keys", t, func() {
+		rsaVerificationKey, err := jwt.NewVerificationKey(&rsaKey.PublicKey, jwt.AlgorithmPS256)
+		So(err, ShouldBeNil)
+		p, err := jwt.NewCognitoRSAParserFromKeys(map[string]jwt.VerificationKey{
+			"rsa":   rsaVerificationKey,
+			"ec":    {Key: &ecKey.PublicKey},
+			"eddsa": {Key: edKey.Public()},
+		})
+		So(err, ShouldBeNil)
+
+		Convey("Then only the RSA key is in PublicKeys, and every key is in the key set", func() {
+			So(p.PublicKeys, ShouldHaveLength, 1)
+			So(p.PublicKeys["rsa"], ShouldEqual, &rsaKey.PublicKey)
+			So(p.KeySet().KeyIDs(), ShouldResemble, []string{"ec", "eddsa", "rsa"})
+		})
+
+		Convey("Then tokens signed with the allowed algorithm for each key are verified", func() {
+			for _, token := range []string{
+				signTestTokenWithKey(t, rsaKey, gojwt.SigningMethodPS256, "rsa"),
+				signTestTokenWithKey(t, ecKey, gojwt.SigningMethodES256, "ec"),
+				signTestTokenWithKey(t, edKey, gojwt.SigningMethodEdDSA, "eddsa"),
+			} {
+				entityData, err := p.Parse(token)
+				So(err, ShouldBeNil)
+				So(entityData.UserID, ShouldEqual, expectedUser)
+			}
+		})
+
+		Convey("Then a token signed with an algorithm that is not in the key's allowlist is rejected", func() {
+			_, err := p.Parse(signTestTokenWithKey(t, rsaKey, gojwt.SigningMethodRS256, "rsa"))
+			So(errors.Is(err, jwt.ErrAlgorithmNotAllowed), ShouldBeTrue)
+		})
+
+		Convey("Then a token claiming a different key's algorithm is rejected", func() {
+			_, err := p.Parse(signTestTokenWithKey(t, ecKey, gojwt.SigningMethodES256, "rsa"))
+			So(errors.Is(err, jwt.ErrAlgorithmNotAllowed), ShouldBeTrue)
+		})
+
+		Convey("Then a token signed with HMAC using a public key as the secret is rejected", func() {
+			publicKeyBytes, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+			So(err, ShouldBeNil)
+			_, err = p.Parse(signTestTokenWithKey(t, publicKeyBytes, gojwt.SigningMethodHS256, "rsa"))
+			So(errors.Is(err, jwt.ErrTokenUnsupportedEncryption), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given a parser created from a base64 encoded ECDSA public key", t, func() {
+		publicKeyBytes, err := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+		So(err, ShouldBeNil)
+		p, err := jwt.NewCognitoRSAParser(map[string]string{"ec": base64.StdEncoding.EncodeToString(publicKeyBytes)})
+		So(err, ShouldBeNil)
+
+		Convey("Then a token signed with ES256 is verified", func() {
+			_, err := p.Parse(signTestTokenWithKey(t, ecKey, gojwt.SigningMethodES256, "ec"))
+			So(err, ShouldBeNil)
+		})
+	})
+}
+
+func TestJSONWebKeySet_VerificationKeys(t *testing.T) {
+	_, ecKey, edKey := newTestKeys(t)
+
+	Convey("Given a key set containing ECDSA and Ed25519 keys", t, func() {
+		ecJWK := jwt.JSONWebKey{
+			KeyType:   jwt.KeyTypeEC,
+			KeyID:     "ec",
+			Algorithm: jwt.AlgorithmES256,
+			Curve:     jwt.CurveP256,
+			X:         base64.RawURLEncoding.EncodeToString(ecKey.X.FillBytes(make([]byte, 32))),
+			Y:         base64.RawURLEncoding.EncodeToString(ecKey.Y.FillBytes(make([]byte, 32))),
+		}
+		edJWK := jwt.JSONWebKey{
+			KeyType: jwt.KeyTypeOKP,
+			KeyID:   "eddsa",
+			Curve:   jwt.CurveEd25519,
+			X:       base64.RawURLEncoding.EncodeToString(edKey.Public().(ed25519.PublicKey)),
+		}
+		x25519JWK := jwt.JSONWebKey{KeyType: jwt.KeyTypeOKP, KeyID: "x25519", Curve: "X25519", X: edJWK.X}
+		keySet := &jwt.JSONWebKeySet{Keys: []jwt.JSONWebKey{ecJWK, edJWK, x25519JWK}}
+
+		Convey("Then the signing keys are returned with their allowed algorithms", func() {
+			keys, err := keySet.VerificationKeys()
+			So(err, ShouldBeNil)
+			So(keys, ShouldHaveLength, 2)
+			So(keys["ec"].Algorithms, ShouldResemble, []string{jwt.AlgorithmES256})
+			So(keys["eddsa"].Algorithms, ShouldResemble, []string{jwt.AlgorithmEdDSA})
+		})
+
+		Convey("Then tokens signed with the keys are verified", func() {
+			p, err := jwt.NewCognitoRSAParserFromJWKS(keySet)
+			So(err, ShouldBeNil)
+			_, err = p.Parse(signTestTokenWithKey(t, ecKey, gojwt.SigningMethodES256, "ec"))
+			So(err, ShouldBeNil)
+			_, err = p.Parse(signTestTokenWithKey(t, edKey, gojwt.SigningMethodEdDSA, "eddsa"))
+			So(err, ShouldBeNil)
+		})
+	})
+
+	validJWK := jwt.JSONWebKey{
+		KeyType: jwt.KeyTypeEC,
+		KeyID:   "valid",
+		Curve:   jwt.CurveP256,
+		X:       base64.RawURLEncoding.EncodeToString(ecKey.X.FillBytes(make([]byte, 32))),
+		Y:       base64.RawURLEncoding.EncodeToString(ecKey.Y.FillBytes(make([]byte, 32))),
+	}
+
+	Convey("Given an ECDSA key whose point is not on the curve", t, func() {
+		keySet := &jwt.JSONWebKeySet{Keys: []jwt.JSONWebKey{{
+			KeyType: jwt.KeyTypeEC,
+			KeyID:   "ec",
+			Curve:   jwt.CurveP256,
+			X:       base64.RawURLEncoding.EncodeToString(make([]byte, 32)),
+			Y:       base64.RawURLEncoding.EncodeToString(make([]byte, 32)),
+		}, validJWK}}
+
+		Convey("Then the invalid key is ignored and the other keys are returned", func() {
+			keys, err := keySet.VerificationKeys()
+			So(err, ShouldBeNil)
+			So(keys, ShouldHaveLength, 1)
+			So(keys, ShouldContainKey, "valid")
+		})
+	})
+
+	Convey("Given a key published with an algorithm that does not match its type", t, func() {
+		ecJWK := validJWK
+		ecJWK.KeyID = "ec"
+		ecJWK.Algorithm = jwt.AlgorithmRS256
+
+		Convey("Then the invalid key is ignored and the other keys are returned", func() {
+			keys, err := (&jwt.JSONWebKeySet{Keys: []jwt.JSONWebKey{ecJWK, validJWK}}).VerificationKeys()
+			So(err, ShouldBeNil)
+			So(keys, ShouldHaveLength, 1)
+			So(keys, ShouldContainKey, "valid")
+		})
+	})
+
+	Convey("Given a key without a key ID", t, func() {
+		noKeyID := validJWK
+		noKeyID.KeyID = ""
+
+		Convey("Then the key is ignored and the other keys are returned", func() {
+			keys, err := (&jwt.JSONWebKeySet{Keys: []jwt.JSONWebKey{validJWK, noKeyID}}).VerificationKeys()
+			So(err, ShouldBeNil)
+			So(keys, ShouldHaveLength, 1)
+			So(keys, ShouldContainKey, "valid")
+		})
+	})
+
+	Convey("Given two keys with the same key ID", t, func() {
+		duplicate := validJWK
+		duplicate.KeyID = "duplicate"
+		keySet := &jwt.JSONWebKeySet{Keys: []jwt.JSONWebKey{duplicate, validJWK, duplicate}}
+
+		Convey("Then both keys are ignored and the other keys are returned", func() {
+			keys, err := keySet.VerificationKeys()
+			So(err, ShouldBeNil)
+			So(keys, ShouldHaveLength, 1)
+			So(keys, ShouldContainKey, "valid")
+		})
+	})
+
+	Convey("Given a key set in which every key is invalid", t, func() {
+		noKeyID := validJWK
+		noKeyID.KeyID = ""
+		keySet := &jwt.JSONWebKeySet{Keys: []jwt.JSONWebKey{noKeyID, validJWK, validJWK}}
+
+		Convey("Then an error is returned", func() {
+			_, err := keySet.VerificationKeys()
+			So(errors.Is(err, jwt.ErrInvalidJSONWebKeySet), ShouldBeTrue)
+		})
+	})
+}
+
+// newTestKeys generates an RSA, a P-256 ECDSA and an Ed25519 private key
+func newTestKeys(t *testing.T) (*rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rsaKey, ecKey, edKey
+}
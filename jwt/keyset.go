@@ -0,0 +1,155 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"maps"
+	"slices"
+	"sync/atomic"
+)
+
+// emptyKeySet is returned by a key store that does not hold any keys
+var emptyKeySet = &KeySet{}
+
+// KeySet is a set of keys used to verify tokens, keyed on key ID. A key set is never modified, keys are refreshed by
+// replacing the whole set.
+type KeySet struct {
+	publicKeys map[string]crypto.PublicKey
+	algorithms map[string][]string
+
+	// rsaPublicKeys are the RSA keys of a parser created without a key provider, which are held in its PublicKeys
+	rsaPublicKeys map[string]*rsa.PublicKey
+}
+
+// NewKeySet returns a key set containing the given verification keys. A key without any algorithms may be used with
+// the default algorithms for its type, see NewVerificationKey.
+func NewKeySet(keys map[string]VerificationKey) (*KeySet, error) {
+	s := &KeySet{
+		publicKeys: make(map[string]crypto.PublicKey, len(keys)),
+		algorithms: make(map[string][]string, len(keys)),
+	}
+	for kid, key := range keys {
+		verificationKey, err := NewVerificationKey(key.Key, key.Algorithms...)
+		if err != nil {
+			return nil, err
+		}
+		s.publicKeys[kid] = verificationKey.Key
+		s.algorithms[kid] = verificationKey.Algorithms
+	}
+	return s, nil
+}
+
+// Len returns the number of keys in the set
+func (s *KeySet) Len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.publicKeys) + len(s.rsaPublicKeys)
+}
+
+// KeyIDs returns the sorted IDs of the keys in the set
+func (s *KeySet) KeyIDs() []string {
+	if s == nil {
+		return nil
+	}
+	kids := slices.Collect(maps.Keys(s.publicKeys))
+	for kid := range s.rsaPublicKeys {
+		if _, ok := s.publicKeys[kid]; !ok {
+			kids = append(kids, kid)
+		}
+	}
+	slices.Sort(kids)
+	return kids
+}
+
+// DiffKeyIDs returns the IDs of the keys in the current key set that were not in the previous set, and those that
+// have been removed from it
+func DiffKeyIDs(previous, current *KeySet) (added, removed []string) {
+	for _, kid := range current.KeyIDs() {
+		if _, ok := previous.publicKey(kid); !ok {
+			added = append(added, kid)
+		}
+	}
+	for _, kid := range previous.KeyIDs() {
+		if _, ok := current.publicKey(kid); !ok {
+			removed = append(removed, kid)
+		}
+	}
+	return added, removed
+}
+
+// publicKey returns the key with the given ID
+func (s *KeySet) publicKey(kid string) (crypto.PublicKey, bool) {
+	if s == nil {
+		return nil, false
+	}
+	if publicKey, ok := s.publicKeys[kid]; ok && publicKey != nil {
+		return publicKey, true
+	}
+	if publicKey := s.rsaPublicKeys[kid]; publicKey != nil {
+		return publicKey, true
+	}
+	return nil, false
+}
+
+// KeyProvider provides the keys used to verify tokens. A parser with a key provider calls KeySet each time it
+// verifies a token, so it must be fast and safe for concurrent use.
+type KeyProvider interface {
+	KeySet() *KeySet
+}
+
+// KeyStore is a KeyProvider holding a key set that can be replaced while tokens are being verified. The key set is
+// swapped atomically, without locking, so that each token is verified using either the old or the new keys. Parsers
+// sharing a key store all use the new keys as soon as they are swapped in. The zero value is an empty key store.
+type KeyStore struct {
+	keys atomic.Pointer[KeySet]
+}
+
+// NewKeyStore returns a key store holding the given key set
+func NewKeyStore(keys *KeySet) *KeyStore {
+	s := &KeyStore{}
+	s.keys.Store(keys)
+	return s
+}
+
+// KeySet returns the key set held by the store, which is empty if no keys have been stored
+func (s *KeyStore) KeySet() *KeySet {
+	if keys := s.keys.Load(); keys != nil {
+		return keys
+	}
+	return emptyKeySet
+}
+
+// Swap replaces the key set held by the store, returning the key set it replaced
+func (s *KeyStore) Swap(keys *KeySet) *KeySet {
+	if previous := s.keys.Swap(keys); previous != nil {
+		return previous
+	}
+	return emptyKeySet
+}
+
+// WithKeyProvider verifies tokens using the keys of the given provider, rather than the keys the parser was created
+// with. The provider is consulted each time a token is verified, so keys it replaces are used immediately.
+func WithKeyProvider(provider KeyProvider) ParserOption {
+	return func(p *CognitoRSAParser) {
+		p.keyProvider = provider
+	}
+}
+
+// KeySet returns the keys currently used to verify tokens. Unlike PublicKeys, which only holds RSA keys, it includes
+// keys of every type, along with the algorithms each key may be used with.
+func (p CognitoRSAParser) KeySet() *KeySet {
+	return p.keySet()
+}
+
+// keySet returns the keys used to verify tokens, from the parser's key provider if it has one, or else its PublicKeys
+// along with any keys of other types
+func (p CognitoRSAParser) keySet() *KeySet {
+	if p.keyProvider != nil {
+		if keys := p.keyProvider.KeySet(); keys != nil {
+			return keys
+		}
+		return emptyKeySet
+	}
+	return &KeySet{publicKeys: p.otherPublicKeys, algorithms: p.algorithms, rsaPublicKeys: p.PublicKeys}
+}
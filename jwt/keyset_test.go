@@ -0,0 +1,130 @@
+package jwt_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-authorisation/v2/jwt"
+	gojwt "github.com/golang-jwt/jwt/v4"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCognitoRSAParser_Parse_KeyProvider(t *testing.T) {
+	currentKey, rotatedKey := newTestECDSAKey(t), newTestECDSAKey(t)
+	currentKeys, err := jwt.NewKeySet(map[string]jwt.VerificationKey{"current": {Key: &currentKey.PublicKey}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotatedKeys, err := jwt.NewKeySet(map[string]jwt.VerificationKey{"rotated": {Key: &rotatedKey.PublicKey}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	currentToken := signTestTokenWithKey(t, currentKey, gojwt.SigningMethodES256, "current")
+	rotatedToken := signTestTokenWithKey(t, rotatedKey, gojwt.SigningMethodES256, "rotated")
+
+	Convey("Given two parsers sharing an empty key store", t, func() {
+		store := &jwt.KeyStore{}
+		p1, err := jwt.NewCognitoRSAParserFromKeys(nil, jwt.WithKeyProvider(store))
+		So(err, ShouldBeNil)
+		p2, err := jwt.NewCognitoRSAParserFromKeys(nil, jwt.WithKeyProvider(store))
+		So(err, ShouldBeNil)
+
+		Convey("Then tokens are rejected as there are no keys", func() {
+			_, err := p1.Parse(currentToken)
+			So(err, ShouldEqual, jwt.ErrPublickeysEmpty)
+		})
+
+		Convey("When keys are swapped into the store", func() {
+			previous := store.Swap(currentKeys)
+
+			Convey("Then both parsers verify tokens using the keys", func() {
+				So(previous.Len(), ShouldEqual, 0)
+				_, err := p1.Parse(currentToken)
+				So(err, ShouldBeNil)
+				_, err = p2.Parse(currentToken)
+				So(err, ShouldBeNil)
+				So(p2.KeySet().KeyIDs(), ShouldResemble, []string{"current"})
+			})
+
+			Convey("Then tokens signed with the replaced keys are rejected once the keys are rotated", func() {
+				So(store.Swap(rotatedKeys), ShouldEqual, currentKeys)
+				_, err := p1.Parse(currentToken)
+				So(err.Error(), ShouldEqual, jwt.ErrJWTKeySet.Error())
+				_, err = p2.Parse(rotatedToken)
+				So(err, ShouldBeNil)
+			})
+		})
+	})
+
+	Convey("Given a parser that refetches its keys into a shared key store", t, func() {
+		store := jwt.NewKeyStore(currentKeys)
+		refreshing, err := jwt.NewCognitoRSAParserFromKeys(nil,
+			jwt.WithKeyProvider(store),
+			jwt.WithKeyRefresh(func(ctx context.Context) (map[string]jwt.VerificationKey, error) {
+				return map[string]jwt.VerificationKey{"rotated": {Key: &rotatedKey.PublicKey}}, nil
+			}, time.Minute),
+		)
+		So(err, ShouldBeNil)
+		other, err := jwt.NewCognitoRSAParserFromKeys(nil, jwt.WithKeyProvider(store))
+		So(err, ShouldBeNil)
+
+		Convey("When a token signed with a new key is parsed", func() {
+			_, err := refreshing.Parse(rotatedToken)
+			So(err, ShouldBeNil)
+
+			Convey("Then the refetched keys are used by every parser sharing the store", func() {
+				So(store.KeySet().KeyIDs(), ShouldResemble, []string{"rotated"})
+				_, err := other.Parse(rotatedToken)
+				So(err, ShouldBeNil)
+			})
+		})
+	})
+
+	Convey("Given a parser whose keys are swapped while tokens are being verified", t, func() {
+		store := jwt.NewKeyStore(currentKeys)
+		p, err := jwt.NewCognitoRSAParserFromKeys(nil, jwt.WithKeyProvider(store))
+		So(err, ShouldBeNil)
+
+		var wg sync.WaitGroup
+		errs := make([]error, 20)
+		for i := range errs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, errs[i] = p.Parse(currentToken)
+				store.Swap(currentKeys)
+			}()
+		}
+		wg.Wait()
+
+		Convey("Then every token is verified", func() {
+			for _, err := range errs {
+				So(err, ShouldBeNil)
+			}
+		})
+	})
+}
+
+func TestDiffKeyIDs(t *testing.T) {
+	Convey("Given a key set that replaces another", t, func() {
+		key := newTestECDSAKey(t)
+		previous, err := jwt.NewKeySet(map[string]jwt.VerificationKey{"a": {Key: &key.PublicKey}, "b": {Key: &key.PublicKey}})
+		So(err, ShouldBeNil)
+		current, err := jwt.NewKeySet(map[string]jwt.VerificationKey{"b": {Key: &key.PublicKey}, "c": {Key: &key.PublicKey}})
+		So(err, ShouldBeNil)
+
+		Convey("Then the added and removed key IDs are returned", func() {
+			added, removed := jwt.DiffKeyIDs(previous, current)
+			So(added, ShouldResemble, []string{"c"})
+			So(removed, ShouldResemble, []string{"a"})
+		})
+
+		Convey("Then every key is added when there were no keys", func() {
+			added, removed := jwt.DiffKeyIDs(nil, current)
+			So(added, ShouldResemble, []string{"b", "c"})
+			So(removed, ShouldBeEmpty)
+		})
+	})
+}
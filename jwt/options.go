@@ -0,0 +1,62 @@
+package jwt
+
+import "time"
+
+// ParserOption configures how a CognitoRSAParser verifies tokens
+type ParserOption func(p *CognitoRSAParser)
+
+// WithIssuers only accepts tokens with one of the given 'iss' claims, e.g. the URL of a Cognito user pool:
+// https://cognito-idp.{region}.amazonaws.com/{userPoolId}
+func WithIssuers(issuers ...string) ParserOption {
+	return func(p *CognitoRSAParser) {
+		p.issuers = issuers
+	}
+}
+
+// WithClientIDs only accepts tokens issued to one of the given app clients. The 'client_id' claim of access tokens,
+// and the 'aud' claim of ID tokens, are checked.
+func WithClientIDs(clientIDs ...string) ParserOption {
+	return func(p *CognitoRSAParser) {
+		p.clientIDs = clientIDs
+	}
+}
+
+// WithTokenUse only accepts tokens with the given 'token_use' claim, i.e. 'access' or 'id'
+func WithTokenUse(tokenUse string) ParserOption {
+	return func(p *CognitoRSAParser) {
+		p.tokenUse = tokenUse
+	}
+}
+
+// WithLeeway allows for clock skew between the token issuer and the service when validating the 'exp', 'nbf' and
+// 'iat' claims. Tokens are accepted for up to the leeway after they expire, and before they become valid.
+func WithLeeway(leeway time.Duration) ParserOption {
+	return func(p *CognitoRSAParser) {
+		p.leeway = leeway
+	}
+}
+
+// WithMaxLifetime rejects tokens that are valid for longer than the given duration, measured from the 'iat' claim
+// to the 'exp' claim. Tokens without both claims are also rejected. A duration of 0 disables the check.
+func WithMaxLifetime(maxLifetime time.Duration) ParserOption {
+	return func(p *CognitoRSAParser) {
+		p.maxLifetime = maxLifetime
+	}
+}
+
+// WithClock sets the function used to get the current time when validating the time based claims
+func WithClock(now func() time.Time) ParserOption {
+	return func(p *CognitoRSAParser) {
+		if now != nil {
+			p.now = now
+		}
+	}
+}
+
+// WithClaimMapping sets how the user ID and groups are read from the claims of a token, see ClaimMapping. By default
+// the Cognito 'username' and 'cognito:groups' claims are used.
+func WithClaimMapping(mapping ClaimMapping) ParserOption {
+	return func(p *CognitoRSAParser) {
+		p.claimMapping = mapping
+	}
+}
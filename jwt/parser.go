@@ -1,12 +1,14 @@
 package jwt
 
 import (
+	"crypto"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/base64"
-	"encoding/json"
-	"strings"
+	"time"
 
+	"github.com/ONSdigital/dp-authorisation/v2/internal/lru"
+	"github.com/ONSdigital/dp-authorisation/v2/internal/tokenhash"
 	permsdk "github.com/ONSdigital/dp-permissions-api/sdk"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/pkg/errors"
@@ -24,7 +26,7 @@ var (
 	ErrTokenNotYetValid           = errors.New("jwt token is not yet valid")
 	ErrTokenMalformed             = errors.New("jwt token is malformed")
 	ErrTokenInvalid               = errors.New("jwt token is not valid") // more generic error to catch any other cases
-	ErrTokenUnsupportedEncryption = errors.New("only rsa encrypted jwt tokens are supported")
+	ErrTokenUnsupportedEncryption = errors.New("jwt token signing algorithm is not supported")
 	ErrNoUserID                   = errors.New("jwt token does not have a user id")
 	ErrFailedToParseClaims        = errors.New("failed to read claims from jwt token")
 	ErrNoGroups                   = errors.New("jwt token does not have any groups")
@@ -32,15 +34,42 @@ var (
 	ErrPublickeysEmpty            = errors.New("public keys map is empty")
 )
 
-// CognitoRSAParser parses JWT tokens that have an RSA encrypted signature, and contain AWS cognito specific claims.
+// CognitoRSAParser parses JWT tokens that contain AWS cognito specific claims. Despite the name, tokens signed using
+// RSA, ECDSA or Ed25519 keys are supported, each key being restricted to its allowed signing algorithms. PublicKeys
+// only holds the RSA keys, KeySet returns keys of every type.
 type CognitoRSAParser struct {
-	PublicKeys map[string]*rsa.PublicKey
-	jwtParser  *jwt.Parser
+	PublicKeys      map[string]*rsa.PublicKey
+	otherPublicKeys map[string]crypto.PublicKey
+	algorithms      map[string][]string
+	jwtParser       *jwt.Parser
+	issuers         []string
+	clientIDs       []string
+	tokenUse        string
+
+	leeway      time.Duration
+	maxLifetime time.Duration
+	now         func() time.Time
+
+	claimMapping ClaimMapping
+
+	cacheMaxEntries int
+	cacheTTL        time.Duration
+	cache           *lru.Cache[string, cachedResult]
+
+	keyRefresh            KeyRefreshFunc
+	keyRefreshMinInterval time.Duration
+	keyRefreshWait        time.Duration
+	keyRefreshTimeout     time.Duration
+	onKeyRotation         KeyRotationFunc
+	refresher             *keyRefresher
+
+	keyProvider KeyProvider
 }
 
-// NewCognitoRSAParser creates a new instance of CognitoRSAParser using the given public key value.
-func NewCognitoRSAParser(base64EncodedPublicKey map[string]string) (*CognitoRSAParser, error) {
-	PublicKeys := map[string]*rsa.PublicKey{}
+// NewCognitoRSAParser creates a new instance of CognitoRSAParser using the given public key value. Each key may be
+// used with the default algorithms for its type, see NewVerificationKey.
+func NewCognitoRSAParser(base64EncodedPublicKey map[string]string, opts ...ParserOption) (*CognitoRSAParser, error) {
+	PublicKeys := map[string]crypto.PublicKey{}
 
 	for kid, encodedPublicKey := range base64EncodedPublicKey {
 		publicKey, err := parsePublicKey(encodedPublicKey)
@@ -50,22 +79,94 @@ func NewCognitoRSAParser(base64EncodedPublicKey map[string]string) (*CognitoRSAP
 		PublicKeys[kid] = publicKey
 	}
 
-	jwtParser := &jwt.Parser{
-		UseJSONNumber: true,
+	return newCognitoRSAParser(PublicKeys, nil, opts), nil
+}
+
+// NewCognitoRSAParserFromKeys creates a new instance of CognitoRSAParser using the given verification keys, so that
+// each key is only used with the algorithms in its allowlist. A key without any algorithms may be used with the
+// default algorithms for its type.
+func NewCognitoRSAParserFromKeys(keys map[string]VerificationKey, opts ...ParserOption) (*CognitoRSAParser, error) {
+	verificationKeys, err := NewKeySet(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	return newCognitoRSAParser(verificationKeys.publicKeys, verificationKeys.algorithms, opts), nil
+}
+
+func newCognitoRSAParser(publicKeys map[string]crypto.PublicKey, algorithms map[string][]string, opts []ParserOption) *CognitoRSAParser {
+	rsaPublicKeys := map[string]*rsa.PublicKey{}
+	otherPublicKeys := map[string]crypto.PublicKey{}
+	for kid, publicKey := range publicKeys {
+		if rsaPublicKey, ok := publicKey.(*rsa.PublicKey); ok {
+			rsaPublicKeys[kid] = rsaPublicKey
+		} else {
+			otherPublicKeys[kid] = publicKey
+		}
 	}
 
-	return &CognitoRSAParser{
-		PublicKeys: PublicKeys,
-		jwtParser:  jwtParser,
-	}, nil
+	p := &CognitoRSAParser{
+		PublicKeys:      rsaPublicKeys,
+		otherPublicKeys: otherPublicKeys,
+		algorithms:      algorithms,
+		jwtParser: &jwt.Parser{
+			UseJSONNumber: true,
+			// the time based claims are validated by the parser, allowing for clock skew
+			SkipClaimsValidation: true,
+		},
+		now:          time.Now,
+		claimMapping: DefaultClaimMapping(),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	p.cache = p.newVerificationCache()
+	p.refresher = p.newKeyRefresher()
+	return p
 }
 
-// Parse and verify the given JWT token, and return the EntityData contained within the JWT (user ID and groups list)
+// Parse and verify the given JWT token, and return the EntityData contained within the JWT (user ID and groups list).
+// A token issued to a service using the client credentials grant does not identify a user, so ErrNoUserID is
+// returned for it, as it was before these tokens were supported. ParseWithClaims returns the client ID of the service.
 func (p CognitoRSAParser) Parse(tokenString string) (*permsdk.EntityData, error) {
-	if len(p.PublicKeys) == 0 {
-		return nil, ErrPublickeysEmpty
+	result, err := p.ParseWithClaims(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if result.ClientID != "" {
+		return nil, ErrNoUserID
+	}
+
+	return &result.EntityData, nil
+}
+
+// ParseWithClaims parses and verifies the given JWT token, returning the EntityData contained within the JWT along
+// with all of its verified claims
+func (p CognitoRSAParser) ParseWithClaims(tokenString string) (*ParseResult, error) {
+	keys := p.keySet()
+	if keys.Len() == 0 {
+		if keys = p.refreshKeySet(keys); keys.Len() == 0 {
+			return nil, ErrPublickeysEmpty
+		}
+	}
+
+	var cacheKey string
+	if p.cache != nil {
+		cacheKey = tokenhash.Key(tokenString)
+		if result, ok := p.cachedResult(cacheKey); ok {
+			return result, nil
+		}
 	}
-	token, err := p.jwtParser.Parse(tokenString, p.getKey)
+
+	// the key that verifies the signature is kept, so that a cached result can be discarded if the key is rotated
+	var kid string
+	var publicKey crypto.PublicKey
+	token, err := p.jwtParser.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		key, err := p.getKey(token, keys)
+		kid, _ = token.Header[Kid].(string)
+		publicKey = key
+		return key, err
+	})
 
 	if err != nil {
 		err = determineErrorType(err)
@@ -75,37 +176,66 @@ func (p CognitoRSAParser) Parse(tokenString string) (*permsdk.EntityData, error)
 		return nil, ErrTokenInvalid
 	}
 
-	entityData, err := getEntityData(token)
-	if err != nil {
+	if err = p.validateClaims(token); err != nil {
 		return nil, err
 	}
 
-	return entityData, nil
+	result, err := getParseResult(token, p.claimMapping)
+	if err != nil {
+		return nil, err
+	}
+	if p.cache != nil {
+		p.cacheResult(cacheKey, result, kid, publicKey)
+	}
+	return result, nil
 }
 
-// getEntityData takes a jwt token and reads its claims to determine the entity data (user ID and groups)
-func getEntityData(token *jwt.Token) (*permsdk.EntityData, error) {
+// getParseResult takes a jwt token and reads its claims to determine the entity data (user ID and groups), using the
+// given claim mapping. A token issued to a service using the client credentials grant has no entity data, the
+// service is identified by the client ID of the result.
+func getParseResult(token *jwt.Token, mapping ClaimMapping) (*ParseResult, error) {
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
 		return nil, ErrFailedToParseClaims
 	}
 
-	userID, ok := claims["username"].(string)
+	if clientID, ok := clientCredentialsClientID(claims, mapping); ok {
+		return &ParseResult{ClientID: clientID, Claims: Claims(claims)}, nil
+	}
+
+	userID, ok := mapping.userID(claims)
 	if !ok {
 		return nil, ErrNoUserID
 	}
 
-	jwtGroups, ok := claims["cognito:groups"].([]interface{})
-	if !ok {
+	groups, ok := mapping.groups(claims)
+	if !ok && mapping.GroupsRequired {
 		return nil, ErrNoGroups
 	}
 
-	groups := mapToStringArray(jwtGroups)
-	entityData := &permsdk.EntityData{
-		UserID: userID,
-		Groups: groups,
+	result := &ParseResult{
+		EntityData: permsdk.EntityData{
+			UserID: userID,
+			Groups: groups,
+		},
+		Claims: Claims(claims),
+	}
+	return result, nil
+}
+
+// clientCredentialsClientID returns the client ID of a token issued using the client credentials grant. Cognito
+// issues these tokens without a username or groups, and with the client ID as the subject.
+func clientCredentialsClientID(claims jwt.MapClaims, mapping ClaimMapping) (string, bool) {
+	if mapping.hasUserID(claims) || mapping.hasGroups(claims) {
+		return "", false
+	}
+
+	clientID, _ := claims["client_id"].(string)
+	subject, _ := claims["sub"].(string)
+	if clientID == "" || clientID != subject {
+		return "", false
 	}
-	return entityData, nil
+	return clientID, true
 }
 
 // determineErrorType attempts to cast an error to the JWT libraries error type,
@@ -122,6 +252,12 @@ func determineErrorType(err error) error {
 		if validationErr.Errors&(jwt.ValidationErrorExpired) != 0 {
 			return ErrTokenExpired
 		}
+		if validationErr.Errors&(jwt.ValidationErrorNotValidYet) != 0 {
+			return ErrTokenNotYetValid
+		}
+		if validationErr.Errors&(jwt.ValidationErrorIssuedAt) != 0 {
+			return ErrTokenUsedBeforeIssued
+		}
 	}
 	return err
 }
@@ -138,8 +274,9 @@ func mapToStringArray(jwtGroups []interface{}) []string {
 	return groups
 }
 
-// parsePublicKey takes the raw base64 encoded public key value and creates an instance of rsa.PublicKey
-func parsePublicKey(base64EncodedPublicKey string) (*rsa.PublicKey, error) {
+// parsePublicKey takes the raw base64 encoded public key value and creates an instance of rsa.PublicKey,
+// ecdsa.PublicKey or ed25519.PublicKey
+func parsePublicKey(base64EncodedPublicKey string) (crypto.PublicKey, error) {
 	pubKeyBytes, err := base64.StdEncoding.DecodeString(base64EncodedPublicKey)
 	if err != nil {
 		return nil, err
@@ -150,39 +287,49 @@ func parsePublicKey(base64EncodedPublicKey string) (*rsa.PublicKey, error) {
 		return nil, err
 	}
 
-	rsaPublicKey, ok := publicKey.(*rsa.PublicKey)
-	if !ok {
-		return nil, ErrUnexpectedKeyType
+	if _, _, err := algorithmsForKey(publicKey); err != nil {
+		return nil, err
 	}
 
-	return rsaPublicKey, nil
+	return publicKey, nil
 }
 
-// This function signature is required by the JWT library. The function is passed as a parameter to jwt.Parse
-func (p CognitoRSAParser) getKey(token *jwt.Token) (interface{}, error) {
-	// check for expected signing method on the token, before trying to verify it.
-	if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+// getKey returns the key from the key set used to verify the token. It is called by the JWT library from jwt.Parse.
+func (p CognitoRSAParser) getKey(token *jwt.Token, keys *KeySet) (crypto.PublicKey, error) {
+	// check for a supported signing method on the token, before trying to verify it.
+	if !isSupportedAlgorithm(token.Method.Alg()) {
 		return nil, ErrTokenUnsupportedEncryption
 	}
 
-	return p.getPublicSigningKey(token.Raw)
-}
-
-func (p CognitoRSAParser) getPublicSigningKey(token string) (*rsa.PublicKey, error) {
-	tokenHeader := strings.Split(token, ".")[0]
-	pubKeyBytes, err := base64.RawURLEncoding.DecodeString(tokenHeader)
+	kid, _ := token.Header[Kid].(string)
+	publicKey, algorithms, err := p.getPublicSigningKey(kid, keys)
 	if err != nil {
 		return nil, err
 	}
 
-	var decodedHeaders map[string]string
-	if json.Unmarshal(pubKeyBytes, &decodedHeaders) != nil {
-		return nil, err
+	// only allow the algorithms the key was published for, so that a token cannot choose how its signature is verified
+	key := VerificationKey{Key: publicKey, Algorithms: algorithms}
+	if key.Algorithms == nil {
+		if key, err = NewVerificationKey(publicKey); err != nil {
+			return nil, err
+		}
 	}
-
-	if p.PublicKeys[decodedHeaders[Kid]] == nil {
-		return nil, ErrJWTKeySet
+	if !key.allows(token.Method.Alg()) {
+		return nil, ErrAlgorithmNotAllowed
 	}
 
-	return p.PublicKeys[decodedHeaders[Kid]], nil
+	return publicKey, nil
+}
+
+// getPublicSigningKey returns the key with the given ID, and the algorithms it may be used with. If the key set does
+// not contain the key, the keys may have been rotated, so they are refetched if the parser is configured to.
+func (p CognitoRSAParser) getPublicSigningKey(kid string, keys *KeySet) (crypto.PublicKey, []string, error) {
+	publicKey, ok := keys.publicKey(kid)
+	if !ok {
+		keys = p.refreshKeySet(keys)
+		if publicKey, ok = keys.publicKey(kid); !ok {
+			return nil, nil, ErrJWTKeySet
+		}
+	}
+	return publicKey, keys.algorithms[kid], nil
 }
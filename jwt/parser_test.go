@@ -1,10 +1,16 @@
 package jwt_test
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
 	"testing"
+	"time"
 
 	"github.com/ONSdigital/dp-authorisation/v2/jwt"
 	permsdk "github.com/ONSdigital/dp-permissions-api/sdk"
+	gojwt "github.com/golang-jwt/jwt/v4"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
@@ -227,3 +233,87 @@ func TestCognitoRSAParser_Parse_UnkownTokenKeyID(t *testing.T) {
 		})
 	})
 }
+
+func TestCognitoRSAParser_Parse_ClientCredentialsToken(t *testing.T) {
+	Convey("Given a JWT token issued to a service using the client credentials grant", t, func() {
+		jwtToken, publicKeys := signTestToken(t, gojwt.MapClaims{
+			"sub":       "57cbishk4j24pabc1234567890",
+			"client_id": "57cbishk4j24pabc1234567890",
+			"token_use": "access",
+			"scope":     "dp-api/read",
+			"exp":       time.Now().Add(time.Hour).Unix(),
+		})
+		p, err := jwt.NewCognitoRSAParser(publicKeys)
+		So(err, ShouldBeNil)
+
+		Convey("When ParseWithClaims is called", func() {
+			result, err := p.ParseWithClaims(jwtToken)
+
+			Convey("Then the result identifies the service by its client ID, without any entity data", func() {
+				So(err, ShouldBeNil)
+				So(result.ClientID, ShouldEqual, "57cbishk4j24pabc1234567890")
+				So(result.EntityData.UserID, ShouldBeEmpty)
+				So(result.EntityData.Groups, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When Parse is called", func() {
+			entityData, err := p.Parse(jwtToken)
+
+			Convey("Then the token is not accepted as identifying a user", func() {
+				So(err, ShouldEqual, jwt.ErrNoUserID)
+				So(entityData, ShouldBeNil)
+			})
+		})
+	})
+}
+
+// signTestToken signs a JWT with the given claims using a new RSA key, returning the token and the public key map
+// used to verify it
+func signTestToken(t *testing.T, claims gojwt.MapClaims) (string, map[string]string) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKeyBytes, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := gojwt.NewWithClaims(gojwt.SigningMethodRS256, claims)
+	token.Header[jwt.Kid] = "test-kid"
+	signedToken, err := token.SignedString(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signedToken, map[string]string{"test-kid": base64.StdEncoding.EncodeToString(publicKeyBytes)}
+}
+
+func BenchmarkCognitoRSAParser_Parse(b *testing.B) {
+	p, err := jwt.NewCognitoRSAParser(testPublicKey)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := p.Parse(signedToken); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCognitoRSAParser_Parse_VerificationCache(b *testing.B) {
+	p, err := jwt.NewCognitoRSAParser(testPublicKey, jwt.WithVerificationCache(jwt.DefaultVerificationCacheMaxEntries, time.Minute))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := p.Parse(signedToken); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package jwt
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Default values used when refreshing the keys of a parser
+const (
+	DefaultKeyRefreshMinInterval = time.Minute
+	DefaultKeyRefreshWait        = 5 * time.Second
+	DefaultKeyRefreshTimeout     = 30 * time.Second
+)
+
+// KeyRefreshFunc fetches the current verification keys, e.g. from the identity service. If it returns no keys, the
+// parser's keys are not replaced, e.g. because the function has swapped the keys into the parser's key store itself.
+type KeyRefreshFunc func(ctx context.Context) (map[string]VerificationKey, error)
+
+// KeyRotationFunc is called with the IDs of the keys added and removed when refreshed keys replace the parser's keys
+type KeyRotationFunc func(added, removed []string)
+
+// WithKeyRefresh refetches the keys using the given function when a token is signed with a key the parser does not
+// have, e.g. after the identity provider has rotated its signing keys. The keys are fetched in the background, at
+// most once every minInterval so that tokens with made up key IDs cannot overload the identity provider, and
+// verification is retried once the new keys have been swapped in. The default min interval is
+// DefaultKeyRefreshMinInterval. A parser without any keys also refetches them.
+//
+// The refetched keys are swapped into the parser's key provider if it is a KeyStore, so that they are used by every
+// parser sharing the store. Otherwise the parser holds its keys in a key store of its own, and a key provider that
+// is not a KeyStore is only consulted for the initial keys.
+func WithKeyRefresh(refresh KeyRefreshFunc, minInterval time.Duration) ParserOption {
+	return func(p *CognitoRSAParser) {
+		p.keyRefresh = refresh
+		p.keyRefreshMinInterval = minInterval
+	}
+}
+
+// WithKeyRefreshWait sets how long verification of a token with an unknown key waits for the keys to be refetched,
+// before the token is rejected. The refetch continues in the background. The default is DefaultKeyRefreshWait.
+func WithKeyRefreshWait(wait time.Duration) ParserOption {
+	return func(p *CognitoRSAParser) {
+		p.keyRefreshWait = wait
+	}
+}
+
+// WithKeyRefreshTimeout sets how long a refetch of the keys may take before it is cancelled, so that a refetch that
+// never completes does not prevent the keys from being refetched again. The default is DefaultKeyRefreshTimeout.
+func WithKeyRefreshTimeout(timeout time.Duration) ParserOption {
+	return func(p *CognitoRSAParser) {
+		p.keyRefreshTimeout = timeout
+	}
+}
+
+// WithKeyRotationHandler sets a function that is called when refetched keys differ from the keys they replace,
+// e.g. to log or count key rotations. It is not called when a parser without any keys fetches them.
+func WithKeyRotationHandler(onRotation KeyRotationFunc) ParserOption {
+	return func(p *CognitoRSAParser) {
+		p.onKeyRotation = onRotation
+	}
+}
+
+// newKeyRefresher returns the key refresher configured by the parser options, or nil if keys are not refetched
+func (p *CognitoRSAParser) newKeyRefresher() *keyRefresher {
+	if p.keyRefresh == nil {
+		return nil
+	}
+	store, ok := p.keyProvider.(*KeyStore)
+	if !ok {
+		store = NewKeyStore(p.keySet())
+		p.keyProvider = store
+	}
+	r := &keyRefresher{
+		store:       store,
+		refresh:     p.keyRefresh,
+		minInterval: p.keyRefreshMinInterval,
+		wait:        p.keyRefreshWait,
+		timeout:     p.keyRefreshTimeout,
+		onRotation:  p.onKeyRotation,
+		now:         p.now,
+	}
+	if r.minInterval <= 0 {
+		r.minInterval = DefaultKeyRefreshMinInterval
+	}
+	if r.wait <= 0 {
+		r.wait = DefaultKeyRefreshWait
+	}
+	if r.timeout <= 0 {
+		r.timeout = DefaultKeyRefreshTimeout
+	}
+	return r
+}
+
+// keyRefresher refetches the keys of a parser, swapping them into the parser's key store
+type keyRefresher struct {
+	store       *KeyStore
+	refresh     KeyRefreshFunc
+	minInterval time.Duration
+	wait        time.Duration
+	timeout     time.Duration
+	onRotation  KeyRotationFunc
+	now         func() time.Time
+
+	mu          sync.Mutex
+	lastAttempt time.Time
+	inFlight    chan struct{}
+}
+
+// refreshAndWait starts a refetch of the keys, unless one is in progress or was attempted within the min interval,
+// and waits for the refetch to complete or for the wait to elapse
+func (r *keyRefresher) refreshAndWait() {
+	r.mu.Lock()
+	done := r.inFlight
+	if done == nil {
+		if !r.lastAttempt.IsZero() && r.now().Sub(r.lastAttempt) < r.minInterval {
+			r.mu.Unlock()
+			return
+		}
+		r.lastAttempt = r.now()
+		done = make(chan struct{})
+		r.inFlight = done
+		go r.run(done)
+	}
+	r.mu.Unlock()
+
+	timer := time.NewTimer(r.wait)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+	}
+}
+
+// run refetches the keys, swapping in the new key set if it contains any keys
+func (r *keyRefresher) run(done chan struct{}) {
+	defer func() {
+		r.mu.Lock()
+		r.inFlight = nil
+		r.mu.Unlock()
+		close(done)
+	}()
+
+	// the refetch is not tied to the request that started it, as it continues once that request has stopped waiting
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	keys, err := r.refresh(ctx)
+	if err != nil || len(keys) == 0 {
+		return
+	}
+	refreshed, err := NewKeySet(keys)
+	if err != nil {
+		return
+	}
+	previous := r.store.Swap(refreshed)
+
+	// keys fetched by a parser that did not have any have not been rotated
+	if r.onRotation != nil && previous.Len() > 0 {
+		if added, removed := DiffKeyIDs(previous, refreshed); len(added) > 0 || len(removed) > 0 {
+			r.onRotation(added, removed)
+		}
+	}
+}
+
+// refreshKeySet refetches the keys, returning the key set to use once the refetch has completed or timed out.
+// The given key set is returned if the parser does not refetch its keys.
+func (p CognitoRSAParser) refreshKeySet(keys *KeySet) *KeySet {
+	if p.refresher == nil {
+		return keys
+	}
+	p.refresher.refreshAndWait()
+	return p.keySet()
+}
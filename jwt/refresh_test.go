@@ -0,0 +1,186 @@
+package jwt_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-authorisation/v2/jwt"
+	gojwt "github.com/golang-jwt/jwt/v4"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCognitoRSAParser_Parse_KeyRefresh(t *testing.T) {
+	currentKey, rotatedKey := newTestECDSAKey(t), newTestECDSAKey(t)
+	currentKeys := map[string]jwt.VerificationKey{"current": {Key: &currentKey.PublicKey}}
+	rotatedKeys := map[string]jwt.VerificationKey{
+		"current": {Key: &currentKey.PublicKey},
+		"rotated": {Key: &rotatedKey.PublicKey},
+	}
+
+	Convey("Given a parser that refetches its keys at most once a minute", t, func() {
+		now := time.Now()
+		var refreshes atomic.Int32
+		var refreshErr error
+		var rotations [][]string
+		refresh := func(ctx context.Context) (map[string]jwt.VerificationKey, error) {
+			refreshes.Add(1)
+			return rotatedKeys, refreshErr
+		}
+
+		p, err := jwt.NewCognitoRSAParserFromKeys(currentKeys,
+			jwt.WithKeyRefresh(refresh, time.Minute),
+			jwt.WithKeyRotationHandler(func(added, removed []string) {
+				rotations = append(rotations, added, removed)
+			}),
+			jwt.WithClock(func() time.Time { return now }),
+		)
+		So(err, ShouldBeNil)
+
+		Convey("When a token signed with a known key is parsed", func() {
+			_, err := p.Parse(signTestTokenWithKey(t, currentKey, gojwt.SigningMethodES256, "current"))
+
+			Convey("Then the keys are not refetched", func() {
+				So(err, ShouldBeNil)
+				So(refreshes.Load(), ShouldEqual, 0)
+			})
+		})
+
+		Convey("When a token signed with a new key is parsed", func() {
+			entityData, err := p.Parse(signTestTokenWithKey(t, rotatedKey, gojwt.SigningMethodES256, "rotated"))
+
+			Convey("Then the keys are refetched and the token is verified using the new key", func() {
+				So(err, ShouldBeNil)
+				So(entityData.UserID, ShouldEqual, expectedUser)
+				So(refreshes.Load(), ShouldEqual, 1)
+			})
+
+			Convey("Then the rotation is reported", func() {
+				So(rotations, ShouldResemble, [][]string{{"rotated"}, nil})
+			})
+
+			Convey("Then tokens signed with the existing key are still verified", func() {
+				_, err := p.Parse(signTestTokenWithKey(t, currentKey, gojwt.SigningMethodES256, "current"))
+				So(err, ShouldBeNil)
+			})
+		})
+
+		Convey("When tokens with unknown key IDs are parsed repeatedly", func() {
+			_, err := p.Parse(signTestTokenWithKey(t, rotatedKey, gojwt.SigningMethodES256, "unknown"))
+			So(err.Error(), ShouldEqual, jwt.ErrJWTKeySet.Error())
+			_, err = p.Parse(signTestTokenWithKey(t, rotatedKey, gojwt.SigningMethodES256, "unknown"))
+			So(err.Error(), ShouldEqual, jwt.ErrJWTKeySet.Error())
+
+			Convey("Then the keys are refetched once within the min interval", func() {
+				So(refreshes.Load(), ShouldEqual, 1)
+			})
+
+			Convey("Then the keys are refetched again once the min interval has elapsed", func() {
+				now = now.Add(2 * time.Minute)
+				_, err := p.Parse(signTestTokenWithKey(t, rotatedKey, gojwt.SigningMethodES256, "unknown"))
+				So(err.Error(), ShouldEqual, jwt.ErrJWTKeySet.Error())
+				So(refreshes.Load(), ShouldEqual, 2)
+			})
+		})
+
+		Convey("When the keys cannot be refetched", func() {
+			refreshErr = errors.New("identity service unavailable")
+			_, err := p.Parse(signTestTokenWithKey(t, rotatedKey, gojwt.SigningMethodES256, "rotated"))
+
+			Convey("Then the token is rejected and the existing keys are kept", func() {
+				So(err.Error(), ShouldEqual, jwt.ErrJWTKeySet.Error())
+				So(rotations, ShouldBeEmpty)
+				_, err := p.Parse(signTestTokenWithKey(t, currentKey, gojwt.SigningMethodES256, "current"))
+				So(err, ShouldBeNil)
+			})
+		})
+
+		Convey("When tokens signed with a new key are parsed concurrently", func() {
+			token := signTestTokenWithKey(t, rotatedKey, gojwt.SigningMethodES256, "rotated")
+			var wg sync.WaitGroup
+			errs := make([]error, 10)
+			for i := range errs {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, errs[i] = p.Parse(token)
+				}()
+			}
+			wg.Wait()
+
+			Convey("Then the keys are refetched once and every token is verified", func() {
+				So(refreshes.Load(), ShouldEqual, 1)
+				for _, err := range errs {
+					So(err, ShouldBeNil)
+				}
+			})
+		})
+	})
+
+	Convey("Given a parser without any keys that refetches its keys", t, func() {
+		p, err := jwt.NewCognitoRSAParserFromKeys(nil, jwt.WithKeyRefresh(func(ctx context.Context) (map[string]jwt.VerificationKey, error) {
+			return rotatedKeys, nil
+		}, time.Minute))
+		So(err, ShouldBeNil)
+
+		Convey("Then the keys are fetched when a token is parsed", func() {
+			_, err := p.Parse(signTestTokenWithKey(t, rotatedKey, gojwt.SigningMethodES256, "rotated"))
+			So(err, ShouldBeNil)
+		})
+	})
+
+	Convey("Given a parser whose refetch of its keys does not complete", t, func() {
+		var refreshes atomic.Int32
+		refresh := func(ctx context.Context) (map[string]jwt.VerificationKey, error) {
+			if refreshes.Add(1) == 1 {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+			return rotatedKeys, nil
+		}
+		p, err := jwt.NewCognitoRSAParserFromKeys(currentKeys,
+			jwt.WithKeyRefresh(refresh, time.Millisecond),
+			jwt.WithKeyRefreshWait(time.Second),
+			jwt.WithKeyRefreshTimeout(10*time.Millisecond),
+		)
+		So(err, ShouldBeNil)
+		token := signTestTokenWithKey(t, rotatedKey, gojwt.SigningMethodES256, "rotated")
+
+		Convey("When a token signed with a new key is parsed twice", func() {
+			_, firstErr := p.Parse(token)
+			time.Sleep(5 * time.Millisecond)
+			_, secondErr := p.Parse(token)
+
+			Convey("Then the first refetch is cancelled and the second verifies the token", func() {
+				So(firstErr.Error(), ShouldEqual, jwt.ErrJWTKeySet.Error())
+				So(secondErr, ShouldBeNil)
+				So(refreshes.Load(), ShouldEqual, 2)
+			})
+		})
+	})
+
+	Convey("Given a parser that does not refetch its keys", t, func() {
+		p, err := jwt.NewCognitoRSAParserFromKeys(currentKeys)
+		So(err, ShouldBeNil)
+
+		Convey("Then a token signed with a new key is rejected", func() {
+			_, err := p.Parse(signTestTokenWithKey(t, rotatedKey, gojwt.SigningMethodES256, "rotated"))
+			So(err.Error(), ShouldEqual, jwt.ErrJWTKeySet.Error())
+		})
+	})
+}
+
+// newTestECDSAKey generates a P-256 ECDSA private key
+func newTestECDSAKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
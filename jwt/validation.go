@@ -0,0 +1,135 @@
+package jwt
+
+import (
+	"encoding/json"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+)
+
+// Values of the Cognito 'token_use' claim
+const (
+	TokenUseAccess = "access"
+	TokenUseID     = "id"
+)
+
+var (
+	ErrInvalidIssuer         = errors.New("jwt token was not issued by a trusted issuer")
+	ErrInvalidAudience       = errors.New("jwt token was not issued to an allowed client")
+	ErrInvalidTokenUse       = errors.New("jwt token has an unexpected token_use")
+	ErrTokenUsedBeforeIssued = errors.New("jwt token was issued in the future")
+	ErrTokenLifetimeTooLong  = errors.New("jwt token lifetime exceeds the maximum allowed")
+)
+
+// validateClaims checks the time based claims, issuer, client and token use of a verified token against those the
+// parser accepts
+func (p CognitoRSAParser) validateClaims(token *jwt.Token) error {
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return ErrFailedToParseClaims
+	}
+
+	if err := p.validateTimes(claims); err != nil {
+		return err
+	}
+
+	if len(p.issuers) > 0 {
+		issuer, _ := claims["iss"].(string)
+		if !slices.Contains(p.issuers, issuer) {
+			return ErrInvalidIssuer
+		}
+	}
+
+	if len(p.clientIDs) > 0 && !p.hasAllowedClient(claims) {
+		return ErrInvalidAudience
+	}
+
+	if p.tokenUse != "" {
+		tokenUse, _ := claims["token_use"].(string)
+		if tokenUse != p.tokenUse {
+			return ErrInvalidTokenUse
+		}
+	}
+
+	return nil
+}
+
+// hasAllowedClient returns true if the 'client_id' claim, or one of the 'aud' claim values, is an allowed client ID
+func (p CognitoRSAParser) hasAllowedClient(claims jwt.MapClaims) bool {
+	if clientID, ok := claims["client_id"].(string); ok && slices.Contains(p.clientIDs, clientID) {
+		return true
+	}
+
+	switch audience := claims["aud"].(type) {
+	case string:
+		return slices.Contains(p.clientIDs, audience)
+	case []interface{}:
+		for _, value := range audience {
+			if clientID, ok := value.(string); ok && slices.Contains(p.clientIDs, clientID) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validateTimes checks the 'exp', 'nbf' and 'iat' claims, allowing for the configured leeway, and the token lifetime
+func (p CognitoRSAParser) validateTimes(claims jwt.MapClaims) error {
+	now := p.now()
+
+	expiresAt, hasExpiresAt, err := numericDate(claims, "exp")
+	if err != nil {
+		return err
+	}
+	notBefore, hasNotBefore, err := numericDate(claims, "nbf")
+	if err != nil {
+		return err
+	}
+	issuedAt, hasIssuedAt, err := numericDate(claims, "iat")
+	if err != nil {
+		return err
+	}
+
+	if hasExpiresAt && now.After(expiresAt.Add(p.leeway)) {
+		return ErrTokenExpired
+	}
+	if hasNotBefore && now.Add(p.leeway).Before(notBefore) {
+		return ErrTokenNotYetValid
+	}
+	if hasIssuedAt && now.Add(p.leeway).Before(issuedAt) {
+		return ErrTokenUsedBeforeIssued
+	}
+
+	if p.maxLifetime > 0 {
+		if !hasExpiresAt || !hasIssuedAt || expiresAt.Sub(issuedAt) > p.maxLifetime {
+			return ErrTokenLifetimeTooLong
+		}
+	}
+
+	return nil
+}
+
+// numericDate returns the time of a NumericDate claim, i.e. a number of seconds since the Unix epoch, and false if
+// the token does not have the claim
+func numericDate(claims jwt.MapClaims, name string) (time.Time, bool, error) {
+	var seconds float64
+	switch value := claims[name].(type) {
+	case nil:
+		return time.Time{}, false, nil
+	case json.Number:
+		var err error
+		if seconds, err = value.Float64(); err != nil {
+			return time.Time{}, false, ErrFailedToParseClaims
+		}
+	case float64:
+		seconds = value
+	default:
+		return time.Time{}, false, ErrFailedToParseClaims
+	}
+
+	whole, fraction := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(fraction*float64(time.Second))), true, nil
+}
@@ -0,0 +1,161 @@
+package jwt_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-authorisation/v2/jwt"
+	gojwt "github.com/golang-jwt/jwt/v4"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const (
+	testIssuer   = "https://cognito-idp.eu-west-2.amazonaws.com/eu-west-2_example"
+	testClientID = "57cbishk4j24pabc1234567890"
+)
+
+func TestCognitoRSAParser_Parse_ValidateClaims(t *testing.T) {
+	newToken := func(claims gojwt.MapClaims) (string, map[string]string) {
+		tokenClaims := gojwt.MapClaims{
+			"username":       expectedUser,
+			"cognito:groups": []string{"admin"},
+			"exp":            time.Now().Add(time.Hour).Unix(),
+		}
+		for name, value := range claims {
+			tokenClaims[name] = value
+		}
+		return signTestToken(t, tokenClaims)
+	}
+	opts := []jwt.ParserOption{
+		jwt.WithIssuers("https://issuer.example", testIssuer),
+		jwt.WithClientIDs(testClientID),
+		jwt.WithTokenUse(jwt.TokenUseAccess),
+	}
+
+	Convey("Given a parser that requires an issuer, client ID and token use", t, func() {
+		Convey("When an access token with the expected claims is parsed", func() {
+			token, publicKeys := newToken(gojwt.MapClaims{"iss": testIssuer, "client_id": testClientID, "token_use": "access"})
+			p, err := jwt.NewCognitoRSAParser(publicKeys, opts...)
+			So(err, ShouldBeNil)
+			entityData, err := p.Parse(token)
+
+			Convey("Then the token is accepted", func() {
+				So(err, ShouldBeNil)
+				So(entityData.UserID, ShouldEqual, expectedUser)
+			})
+		})
+
+		Convey("When a token from another issuer is parsed", func() {
+			token, publicKeys := newToken(gojwt.MapClaims{"iss": "https://other.example", "client_id": testClientID, "token_use": "access"})
+			p, err := jwt.NewCognitoRSAParser(publicKeys, opts...)
+			So(err, ShouldBeNil)
+			_, err = p.Parse(token)
+
+			Convey("Then ErrInvalidIssuer is returned", func() {
+				So(err, ShouldEqual, jwt.ErrInvalidIssuer)
+			})
+		})
+
+		Convey("When a token issued to another app client is parsed", func() {
+			token, publicKeys := newToken(gojwt.MapClaims{"iss": testIssuer, "client_id": "other-client", "token_use": "access"})
+			p, err := jwt.NewCognitoRSAParser(publicKeys, opts...)
+			So(err, ShouldBeNil)
+			_, err = p.Parse(token)
+
+			Convey("Then ErrInvalidAudience is returned", func() {
+				So(err, ShouldEqual, jwt.ErrInvalidAudience)
+			})
+		})
+
+		Convey("When an ID token is parsed", func() {
+			token, publicKeys := newToken(gojwt.MapClaims{"iss": testIssuer, "aud": testClientID, "token_use": "id"})
+			p, err := jwt.NewCognitoRSAParser(publicKeys, opts...)
+			So(err, ShouldBeNil)
+			_, err = p.Parse(token)
+
+			Convey("Then ErrInvalidTokenUse is returned", func() {
+				So(err, ShouldEqual, jwt.ErrInvalidTokenUse)
+			})
+		})
+	})
+
+	Convey("Given a parser that accepts ID tokens for a client ID", t, func() {
+		Convey("When an ID token with the client ID in its audience is parsed", func() {
+			token, publicKeys := newToken(gojwt.MapClaims{"aud": []string{"other-client", testClientID}, "token_use": "id"})
+			p, err := jwt.NewCognitoRSAParser(publicKeys, jwt.WithClientIDs(testClientID), jwt.WithTokenUse(jwt.TokenUseID))
+			So(err, ShouldBeNil)
+			_, err = p.Parse(token)
+
+			Convey("Then the token is accepted", func() {
+				So(err, ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestCognitoRSAParser_Parse_ValidateTimes(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	parse := func(claims gojwt.MapClaims, opts ...jwt.ParserOption) error {
+		claims["username"] = expectedUser
+		claims["cognito:groups"] = []string{"admin"}
+		token, publicKeys := signTestToken(t, claims)
+		p, err := jwt.NewCognitoRSAParser(publicKeys, append([]jwt.ParserOption{jwt.WithClock(clock)}, opts...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = p.Parse(token)
+		return err
+	}
+
+	Convey("Given a parser with a leeway of 30 seconds", t, func() {
+		leeway := jwt.WithLeeway(30 * time.Second)
+
+		Convey("Then a token that expired within the leeway is accepted", func() {
+			So(parse(gojwt.MapClaims{"exp": now.Add(-20 * time.Second).Unix()}, leeway), ShouldBeNil)
+		})
+
+		Convey("Then a token that expired before the leeway is rejected with ErrTokenExpired", func() {
+			So(parse(gojwt.MapClaims{"exp": now.Add(-time.Minute).Unix()}, leeway), ShouldEqual, jwt.ErrTokenExpired)
+		})
+
+		Convey("Then a token that becomes valid within the leeway is accepted", func() {
+			So(parse(gojwt.MapClaims{"nbf": now.Add(20 * time.Second).Unix(), "iat": now.Add(20 * time.Second).Unix()}, leeway), ShouldBeNil)
+		})
+
+		Convey("Then a token that becomes valid after the leeway is rejected with ErrTokenNotYetValid", func() {
+			So(parse(gojwt.MapClaims{"nbf": now.Add(time.Minute).Unix()}, leeway), ShouldEqual, jwt.ErrTokenNotYetValid)
+		})
+
+		Convey("Then a token issued after the leeway is rejected with ErrTokenUsedBeforeIssued", func() {
+			So(parse(gojwt.MapClaims{"iat": now.Add(time.Minute).Unix()}, leeway), ShouldEqual, jwt.ErrTokenUsedBeforeIssued)
+		})
+	})
+
+	Convey("Given a parser without a leeway", t, func() {
+		Convey("Then a token that has just expired is rejected", func() {
+			So(parse(gojwt.MapClaims{"exp": now.Add(-time.Second).Unix()}), ShouldEqual, jwt.ErrTokenExpired)
+		})
+
+		Convey("Then a token with a time claim that is not a number is rejected", func() {
+			So(parse(gojwt.MapClaims{"exp": "tomorrow"}), ShouldEqual, jwt.ErrFailedToParseClaims)
+		})
+	})
+
+	Convey("Given a parser with a maximum token lifetime of one day", t, func() {
+		maxLifetime := jwt.WithMaxLifetime(24 * time.Hour)
+
+		Convey("Then a token valid for one hour is accepted", func() {
+			So(parse(gojwt.MapClaims{"iat": now.Unix(), "exp": now.Add(time.Hour).Unix()}, maxLifetime), ShouldBeNil)
+		})
+
+		Convey("Then a token valid for a year is rejected with ErrTokenLifetimeTooLong", func() {
+			So(parse(gojwt.MapClaims{"iat": now.Unix(), "exp": now.AddDate(1, 0, 0).Unix()}, maxLifetime), ShouldEqual, jwt.ErrTokenLifetimeTooLong)
+		})
+
+		Convey("Then a token without an expiry is rejected with ErrTokenLifetimeTooLong", func() {
+			So(parse(gojwt.MapClaims{"iat": now.Unix()}, maxLifetime), ShouldEqual, jwt.ErrTokenLifetimeTooLong)
+		})
+	})
+}
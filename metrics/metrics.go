@@ -0,0 +1,212 @@
+// Package metrics provides Prometheus instrumentation for the authorisation middleware, permissions cache and
+// identity clients. The collectors are registered with a registry supplied by the service, so there is no global state.
+package metrics
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "dp_authorisation"
+
+// Outcome label values
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Metrics holds the authorisation collectors. All methods are safe to call on a nil *Metrics,
+// so instrumented code does not need to check whether metrics have been configured.
+type Metrics struct {
+	decisions           *prometheus.CounterVec
+	jwtParseDuration    prometheus.Histogram
+	jwtParseFailures    *prometheus.CounterVec
+	bundleFetchDuration prometheus.Histogram
+	bundleFetchFailures prometheus.Counter
+	bundleSize          prometheus.Gauge
+	jwksRefreshes       *prometheus.CounterVec
+	jwksRotations       prometheus.Counter
+	zebedeeDuration     *prometheus.HistogramVec
+	tokenUsage          *prometheus.CounterVec
+
+	// bundleUpdated is the unix time in nanoseconds of the last successful bundle update
+	bundleUpdated atomic.Int64
+}
+
+// New creates the authorisation collectors and registers them with the given registerer,
+// e.g. the registry served by the service's /metrics endpoint.
+func New(registerer prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		decisions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "decisions_total",
+			Help:      "Authorisation decisions made by the middleware, by permission, enforcement mode, outcome and reason.",
+		}, []string{"permission", "mode", "outcome", "reason"}),
+		jwtParseDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "jwt_parse_duration_seconds",
+			Help:      "Time taken to parse and verify JWT access tokens.",
+			Buckets:   []float64{0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05},
+		}),
+		jwtParseFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "jwt_parse_failures_total",
+			Help:      "JWT access tokens that failed verification, by reason.",
+		}, []string{"reason"}),
+		bundleFetchDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "permissions_bundle_fetch_duration_seconds",
+			Help:      "Time taken to fetch the permissions bundle from the permissions API.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+		bundleFetchFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "permissions_bundle_fetch_failures_total",
+			Help:      "Failed attempts to fetch the permissions bundle from the permissions API.",
+		}),
+		bundleSize: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "permissions_bundle_permissions",
+			Help:      "Number of permissions in the cached permissions bundle.",
+		}),
+		jwksRefreshes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "jwks_refreshes_total",
+			Help:      "Attempts to refresh the JWT verification keys from the identity API, by outcome.",
+		}, []string{"outcome"}),
+		jwksRotations: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "jwks_rotations_total",
+			Help:      "Changes to the JWT verification key IDs observed when refetching the keys for a token signed with an unknown key.",
+		}),
+		zebedeeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "zebedee_identity_duration_seconds",
+			Help:      "Time taken to check token identities with Zebedee, by outcome.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"outcome"}),
+		tokenUsage: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "token_usage_total",
+			Help:      "Requests authenticated by the middleware, by token type, caller, permission and route. The caller is only set for services.",
+		}, []string{"token_type", "caller", "permission", "route"}),
+	}
+
+	bundleAge := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "permissions_bundle_age_seconds",
+		Help:      "Time since the permissions bundle was last updated successfully, or 0 if it has never been updated.",
+	}, m.bundleAge)
+
+	collectors := []prometheus.Collector{
+		m.decisions,
+		m.jwtParseDuration,
+		m.jwtParseFailures,
+		m.bundleFetchDuration,
+		m.bundleFetchFailures,
+		m.bundleSize,
+		bundleAge,
+		m.jwksRefreshes,
+		m.jwksRotations,
+		m.zebedeeDuration,
+		m.tokenUsage,
+	}
+	for _, collector := range collectors {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// RecordDecision counts an authorisation decision. A decision for several permissions is labelled
+// with the comma separated list of permissions.
+func (m *Metrics) RecordDecision(permissions []string, mode, outcome, reason string) {
+	if m == nil {
+		return
+	}
+	m.decisions.WithLabelValues(strings.Join(permissions, ","), mode, outcome, reason).Inc()
+}
+
+// ObserveJWTParse records the time taken to parse a JWT, counting the failure reason if it could not be verified
+func (m *Metrics) ObserveJWTParse(duration time.Duration, failureReason string) {
+	if m == nil {
+		return
+	}
+	m.jwtParseDuration.Observe(duration.Seconds())
+	if failureReason != "" {
+		m.jwtParseFailures.WithLabelValues(failureReason).Inc()
+	}
+}
+
+// ObserveBundleFetch records the time taken to fetch the permissions bundle, counting it as a failure if err is not nil
+func (m *Metrics) ObserveBundleFetch(duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.bundleFetchDuration.Observe(duration.Seconds())
+	if err != nil {
+		m.bundleFetchFailures.Inc()
+	}
+}
+
+// SetBundle records the size of a newly cached permissions bundle and the time it was updated
+func (m *Metrics) SetBundle(permissions int, updated time.Time) {
+	if m == nil {
+		return
+	}
+	m.bundleSize.Set(float64(permissions))
+	m.bundleUpdated.Store(updated.UnixNano())
+}
+
+// RecordJWKSRefresh counts an attempt to refresh the JWT verification keys, labelled with OutcomeSuccess or OutcomeFailure
+func (m *Metrics) RecordJWKSRefresh(err error) {
+	if m == nil {
+		return
+	}
+	m.jwksRefreshes.WithLabelValues(outcome(err)).Inc()
+}
+
+// RecordJWKSRotation counts a change to the JWT verification key IDs, e.g. when the identity provider rotates its keys
+func (m *Metrics) RecordJWKSRotation() {
+	if m == nil {
+		return
+	}
+	m.jwksRotations.Inc()
+}
+
+// ObserveZebedeeCall records the time taken to check a token identity with Zebedee
+func (m *Metrics) ObserveZebedeeCall(duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.zebedeeDuration.WithLabelValues(outcome(err)).Observe(duration.Seconds())
+}
+
+// RecordTokenUsage counts a request authenticated using the given type of token. A request for several
+// permissions is labelled with the comma separated list of permissions.
+func (m *Metrics) RecordTokenUsage(tokenType, caller string, permissions []string, route string) {
+	if m == nil {
+		return
+	}
+	m.tokenUsage.WithLabelValues(tokenType, caller, strings.Join(permissions, ","), route).Inc()
+}
+
+func (m *Metrics) bundleAge() float64 {
+	updated := m.bundleUpdated.Load()
+	if updated == 0 {
+		return 0
+	}
+	return time.Since(time.Unix(0, updated)).Seconds()
+}
+
+func outcome(err error) string {
+	if err != nil {
+		return OutcomeFailure
+	}
+	return OutcomeSuccess
+}
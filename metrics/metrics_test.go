@@ -0,0 +1,160 @@
+package metrics_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-authorisation/v2/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNew(t *testing.T) {
+	Convey("Given a registry", t, func() {
+		registry := prometheus.NewRegistry()
+
+		Convey("When the metrics are created", func() {
+			m, err := metrics.New(registry)
+
+			Convey("Then the collectors are registered without error", func() {
+				So(err, ShouldBeNil)
+				So(m, ShouldNotBeNil)
+				count, err := testutil.GatherAndCount(registry)
+				So(err, ShouldBeNil)
+				So(count, ShouldBeGreaterThan, 0)
+			})
+
+			Convey("Then creating the metrics again with the same registry returns an error", func() {
+				_, err := metrics.New(registry)
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestMetrics_Record(t *testing.T) {
+	Convey("Given metrics registered with a registry", t, func() {
+		registry := prometheus.NewRegistry()
+		m, err := metrics.New(registry)
+		So(err, ShouldBeNil)
+
+		Convey("When decisions are recorded", func() {
+			m.RecordDecision([]string{"datasets:read"}, "enforce", "allow", "policy_matched")
+			m.RecordDecision([]string{"datasets:read"}, "enforce", "allow", "policy_matched")
+			m.RecordDecision([]string{"datasets:edit", "datasets:publish"}, "shadow", "deny", "no_matching_policy")
+
+			Convey("Then they are counted by permission, mode, outcome and reason", func() {
+				expected := `
+# HELP dp_authorisation_decisions_total Authorisation decisions made by the middleware, by permission, enforcement mode, outcome and reason.
+# TYPE dp_authorisation_decisions_total counter
+dp_authorisation_decisions_total{mode="enforce",outcome="allow",permission="datasets:read",reason="policy_matched"} 2
+dp_authorisation_decisions_total{mode="shadow",outcome="deny",permission="datasets:edit,datasets:publish",reason="no_matching_policy"} 1
+`
+				So(testutil.GatherAndCompare(registry, strings.NewReader(expected), "dp_authorisation_decisions_total"), ShouldBeNil)
+			})
+		})
+
+		Convey("When token usage is recorded", func() {
+			m.RecordTokenUsage("zebedee_service", "services/dp-dataset-api", []string{"datasets:read"}, "GET /datasets")
+			m.RecordTokenUsage("jwt", "", []string{"datasets:read"}, "GET /datasets")
+
+			Convey("Then requests are counted by token type, caller, permission and route", func() {
+				expected := `
+# HELP dp_authorisation_token_usage_total Requests authenticated by the middleware, by token type, caller, permission and route. The caller is only set for services.
+# TYPE dp_authorisation_token_usage_total counter
+dp_authorisation_token_usage_total{caller="",permission="datasets:read",route="GET /datasets",token_type="jwt"} 1
+dp_authorisation_token_usage_total{caller="services/dp-dataset-api",permission="datasets:read",route="GET /datasets",token_type="zebedee_service"} 1
+`
+				So(testutil.GatherAndCompare(registry, strings.NewReader(expected), "dp_authorisation_token_usage_total"), ShouldBeNil)
+			})
+		})
+
+		Convey("When JWT parses are observed", func() {
+			m.ObserveJWTParse(time.Millisecond, "")
+			m.ObserveJWTParse(time.Millisecond, "token_expired")
+
+			Convey("Then only the failure is counted by reason", func() {
+				expected := `
+# HELP dp_authorisation_jwt_parse_failures_total JWT access tokens that failed verification, by reason.
+# TYPE dp_authorisation_jwt_parse_failures_total counter
+dp_authorisation_jwt_parse_failures_total{reason="token_expired"} 1
+`
+				So(testutil.GatherAndCompare(registry, strings.NewReader(expected), "dp_authorisation_jwt_parse_failures_total"), ShouldBeNil)
+			})
+		})
+
+		Convey("When a bundle fetch fails and a JWKS refresh succeeds", func() {
+			m.ObserveBundleFetch(time.Millisecond, errors.New("permissions API unavailable"))
+			m.RecordJWKSRefresh(nil)
+
+			Convey("Then the failure and refresh are counted", func() {
+				expected := `
+# HELP dp_authorisation_permissions_bundle_fetch_failures_total Failed attempts to fetch the permissions bundle from the permissions API.
+# TYPE dp_authorisation_permissions_bundle_fetch_failures_total counter
+dp_authorisation_permissions_bundle_fetch_failures_total 1
+# HELP dp_authorisation_jwks_refreshes_total Attempts to refresh the JWT verification keys from the identity API, by outcome.
+# TYPE dp_authorisation_jwks_refreshes_total counter
+dp_authorisation_jwks_refreshes_total{outcome="success"} 1
+`
+				So(testutil.GatherAndCompare(registry, strings.NewReader(expected),
+					"dp_authorisation_permissions_bundle_fetch_failures_total", "dp_authorisation_jwks_refreshes_total"), ShouldBeNil)
+			})
+		})
+
+		Convey("When a JWKS rotation is recorded", func() {
+			m.RecordJWKSRotation()
+
+			Convey("Then the rotation is counted", func() {
+				expected := `
+# HELP dp_authorisation_jwks_rotations_total Changes to the JWT verification key IDs observed when refetching the keys for a token signed with an unknown key.
+# TYPE dp_authorisation_jwks_rotations_total counter
+dp_authorisation_jwks_rotations_total 1
+`
+				So(testutil.GatherAndCompare(registry, strings.NewReader(expected), "dp_authorisation_jwks_rotations_total"), ShouldBeNil)
+			})
+		})
+
+		Convey("When a bundle is set", func() {
+			m.SetBundle(3, time.Now().Add(-time.Minute))
+
+			Convey("Then the bundle size and age are reported", func() {
+				expected := `
+# HELP dp_authorisation_permissions_bundle_permissions Number of permissions in the cached permissions bundle.
+# TYPE dp_authorisation_permissions_bundle_permissions gauge
+dp_authorisation_permissions_bundle_permissions 3
+`
+				So(testutil.GatherAndCompare(registry, strings.NewReader(expected), "dp_authorisation_permissions_bundle_permissions"), ShouldBeNil)
+
+				families, err := registry.Gather()
+				So(err, ShouldBeNil)
+				for _, family := range families {
+					if family.GetName() == "dp_authorisation_permissions_bundle_age_seconds" {
+						So(family.GetMetric()[0].GetGauge().GetValue(), ShouldBeGreaterThanOrEqualTo, 60)
+					}
+				}
+			})
+		})
+	})
+}
+
+func TestMetrics_Nil(t *testing.T) {
+	Convey("Given nil metrics", t, func() {
+		var m *metrics.Metrics
+
+		Convey("Then recording metrics does not panic", func() {
+			So(func() {
+				m.RecordDecision([]string{"datasets:read"}, "enforce", "allow", "policy_matched")
+				m.ObserveJWTParse(time.Millisecond, "token_expired")
+				m.ObserveBundleFetch(time.Millisecond, nil)
+				m.SetBundle(1, time.Now())
+				m.RecordJWKSRefresh(nil)
+				m.RecordJWKSRotation()
+				m.ObserveZebedeeCall(time.Millisecond, nil)
+				m.RecordTokenUsage("jwt", "", []string{"datasets:read"}, "GET /datasets")
+			}, ShouldNotPanic)
+		})
+	})
+}
@@ -5,9 +5,13 @@ import (
 	"sync"
 	"time"
 
+	"github.com/ONSdigital/dp-authorisation/v2/internal/tracing"
+	"github.com/ONSdigital/dp-authorisation/v2/metrics"
 	health "github.com/ONSdigital/dp-healthcheck/healthcheck"
 	permsdk "github.com/ONSdigital/dp-permissions-api/sdk"
 	"github.com/ONSdigital/log.go/v2/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Compiler check to ensure CachingStore implements the Store interface.
@@ -22,15 +26,38 @@ type CachingStore struct {
 	lastUpdated          time.Time
 	lastUpdateSuccessful bool
 	mutex                sync.Mutex
+	metrics              *metrics.Metrics
+	tracerProvider       trace.TracerProvider
+}
+
+// CachingStoreOption configures optional behaviour of a CachingStore
+type CachingStoreOption func(c *CachingStore)
+
+// WithStoreMetrics sets the metrics used to record bundle fetch latency and failures, and the bundle age and size
+func WithStoreMetrics(m *metrics.Metrics) CachingStoreOption {
+	return func(c *CachingStore) {
+		c.metrics = m
+	}
+}
+
+// WithStoreTracerProvider sets the provider used to create a span for each cache update, the global provider is used by default
+func WithStoreTracerProvider(tracerProvider trace.TracerProvider) CachingStoreOption {
+	return func(c *CachingStore) {
+		c.tracerProvider = tracerProvider
+	}
 }
 
 // NewCachingStore constructs a new instance of CachingStore
-func NewCachingStore(underlyingStore Store) *CachingStore {
-	return &CachingStore{
+func NewCachingStore(underlyingStore Store, opts ...CachingStoreOption) *CachingStore {
+	c := &CachingStore{
 		underlyingStore:    underlyingStore,
 		closing:            make(chan struct{}),
 		cacheUpdaterClosed: make(chan struct{}),
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 // GetPermissionsBundle returns the cached permission data, or an error if it's not cached.
@@ -47,7 +74,12 @@ func (c *CachingStore) GetPermissionsBundle(_ context.Context, _ permsdk.Headers
 
 // Update the permissions cache data, by calling the underlying permissions store
 func (c *CachingStore) Update(ctx context.Context, maxCacheTime time.Duration) (permsdk.Bundle, error) {
+	ctx, span := tracing.Tracer(c.tracerProvider).Start(ctx, "permissions.UpdateCache")
+	start := time.Now()
 	bundle, err := c.underlyingStore.GetPermissionsBundle(ctx, permsdk.Headers{})
+	c.metrics.ObserveBundleFetch(time.Since(start), err)
+	span.SetAttributes(attribute.Int("permissions.bundle_size", len(bundle)))
+	tracing.EndSpan(span, err)
 
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
@@ -62,6 +94,9 @@ func (c *CachingStore) Update(ctx context.Context, maxCacheTime time.Duration) (
 		c.cachedBundle = bundle
 	}
 	c.lastUpdated = time.Now()
+	if err == nil {
+		c.metrics.SetBundle(len(bundle), c.lastUpdated)
+	}
 
 	return bundle, err
 }
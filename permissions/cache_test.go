@@ -3,13 +3,17 @@ package permissions_test
 import (
 	"context"
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/ONSdigital/dp-authorisation/v2/metrics"
 	"github.com/ONSdigital/dp-authorisation/v2/permissions"
 	"github.com/ONSdigital/dp-authorisation/v2/permissions/mock"
 	"github.com/ONSdigital/dp-healthcheck/healthcheck"
 	permsdk "github.com/ONSdigital/dp-permissions-api/sdk"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
@@ -67,6 +71,38 @@ func TestCachingStore_Update_UnderlyingStoreErr(t *testing.T) {
 	})
 }
 
+func TestCachingStore_Update_WithStoreMetrics(t *testing.T) {
+	ctx := context.Background()
+	bundle := permsdk.Bundle{"users.add": {}, "legacy.read": {}}
+	underlyingStore := &mock.StoreMock{
+		GetPermissionsBundleFunc: func(ctx context.Context, headers permsdk.Headers) (permsdk.Bundle, error) {
+			return bundle, nil
+		},
+	}
+
+	Convey("Given a CachingStore with metrics", t, func() {
+		registry := prometheus.NewRegistry()
+		authMetrics, err := metrics.New(registry)
+		So(err, ShouldBeNil)
+		store := permissions.NewCachingStore(underlyingStore, permissions.WithStoreMetrics(authMetrics))
+
+		Convey("When Update is called", func() {
+			_, err := store.Update(ctx, maxCacheTime)
+			So(err, ShouldBeNil)
+
+			Convey("Then the fetch latency and bundle size are recorded", func() {
+				So(testutil.CollectAndCount(registry, "dp_authorisation_permissions_bundle_fetch_duration_seconds"), ShouldEqual, 1)
+				expected := `
+# HELP dp_authorisation_permissions_bundle_permissions Number of permissions in the cached permissions bundle.
+# TYPE dp_authorisation_permissions_bundle_permissions gauge
+dp_authorisation_permissions_bundle_permissions 2
+`
+				So(testutil.GatherAndCompare(registry, strings.NewReader(expected), "dp_authorisation_permissions_bundle_permissions"), ShouldBeNil)
+			})
+		})
+	})
+}
+
 func TestCachingStore_GetPermissionsBundle(t *testing.T) {
 	expectedBundle := permsdk.Bundle{}
 	ctx := context.Background()
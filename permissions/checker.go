@@ -1,7 +1,8 @@
 // Package permissions provides library functions to determine if a user/service has a particular permission.
 //
 // A user can be identified by the user ID, or the groups that it belongs to. A service 'user' is identified by a service ID.
-// Users, groups and services are treated the same for permissions purposes, so have the common name Entities.
+// Users, groups and services are treated the same for permissions purposes, so have the common name Entities. The
+// entity IDs used in policies are 'users/<user ID>', 'groups/<group>' and 'services/<service ID>', see NewContextWithService.
 //
 // Entities are associated with permissions/roles via policies. For an entity to have a permission, there must be at
 // least one policy that applies to that entity and permission. Policies can also have conditions that need to be met
@@ -13,21 +14,60 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ONSdigital/dp-authorisation/v2/audit"
+	"github.com/ONSdigital/dp-authorisation/v2/internal/tracing"
+	"github.com/ONSdigital/dp-authorisation/v2/metrics"
 	health "github.com/ONSdigital/dp-healthcheck/healthcheck"
+	dphttp "github.com/ONSdigital/dp-net/v3/http"
+	"github.com/ONSdigital/dp-net/v3/request"
 	permsdk "github.com/ONSdigital/dp-permissions-api/sdk"
 	"github.com/ONSdigital/log.go/v2/log"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Checker reads permission data and verifies that a user has a permission
 type Checker struct {
-	cache Cache
+	cache          Cache
+	auditSink      audit.Sink
+	metrics        *metrics.Metrics
+	tracerProvider trace.TracerProvider
+}
+
+// CheckerOption configures optional behaviour of a Checker
+type CheckerOption func(c *Checker)
+
+// WithAuditSink sets the sink that the checker records its decisions to. When the checker is called
+// by the authorisation middleware, the decision is recorded by the middleware instead.
+func WithAuditSink(auditSink audit.Sink) CheckerOption {
+	return func(c *Checker) {
+		c.auditSink = auditSink
+	}
+}
+
+// WithMetrics sets the metrics recorded by the CachingStore created by NewChecker
+func WithMetrics(m *metrics.Metrics) CheckerOption {
+	return func(c *Checker) {
+		c.metrics = m
+	}
+}
+
+// WithTracerProvider sets the provider used to trace the cache updates of the CachingStore created by NewChecker.
+// Requests to the permissions API propagate the trace context of the update.
+func WithTracerProvider(tracerProvider trace.TracerProvider) CheckerOption {
+	return func(c *Checker) {
+		c.tracerProvider = tracerProvider
+	}
 }
 
 // NewCheckerForStore creates a new Checker instance.
-func NewCheckerForStore(cache Cache) *Checker {
-	return &Checker{
+func NewCheckerForStore(cache Cache, opts ...CheckerOption) *Checker {
+	c := &Checker{
 		cache: cache,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 // NewChecker creates a new Checker instance that uses the permissions API client, wrapped in a CachingStore
@@ -35,12 +75,20 @@ func NewChecker(
 	ctx context.Context,
 	permissionsAPIHost string,
 	cacheUpdateInterval,
-	maxCacheTime time.Duration) *Checker {
-	apiClient := permsdk.NewClient(permissionsAPIHost)
-	cachingStore := NewCachingStore(apiClient)
+	maxCacheTime time.Duration,
+	opts ...CheckerOption) *Checker {
+	checker := NewCheckerForStore(nil, opts...)
+
+	httpClient := dphttp.NewClientWithTransport(tracing.NewTransport(dphttp.DefaultTransport))
+	apiClient := permsdk.NewClientWithClienter(permissionsAPIHost, httpClient)
+	cachingStore := NewCachingStore(apiClient,
+		WithStoreMetrics(checker.metrics),
+		WithStoreTracerProvider(checker.tracerProvider),
+	)
 	cachingStore.StartCacheUpdater(ctx, cacheUpdateInterval, maxCacheTime)
+	checker.cache = cachingStore
 
-	return NewCheckerForStore(cachingStore)
+	return checker
 }
 
 // HasPermission returns true if one of the given entities has the given permission.
@@ -53,8 +101,36 @@ func (c Checker) HasPermission(
 	entityData permsdk.EntityData,
 	permission string,
 	attributes map[string]string) (bool, error) {
-	entities := mapEntityDataToEntities(entityData)
-	return c.hasPermission(ctx, entities, permission, attributes)
+	entities := mapEntityDataToEntities(ctx, entityData)
+	result, err := c.hasPermission(ctx, entities, permission, attributes)
+	c.recordDecision(ctx, entityData, []string{permission}, attributes, result, err)
+	return result.granted, err
+}
+
+// HasAnyPermission returns true if one of the given entities has at least one of the given permissions.
+// All permissions are evaluated against the same snapshot of permissions data. An empty list of permissions is never granted.
+func (c Checker) HasAnyPermission(
+	ctx context.Context,
+	entityData permsdk.EntityData,
+	permissions []string,
+	attributes map[string]string) (bool, error) {
+	entities := mapEntityDataToEntities(ctx, entityData)
+	result, err := c.hasAnyPermission(ctx, entities, permissions, attributes)
+	c.recordDecision(ctx, entityData, permissions, attributes, result, err)
+	return result.granted, err
+}
+
+// HasAllPermissions returns true if the given entities have every one of the given permissions.
+// All permissions are evaluated against the same snapshot of permissions data. An empty list of permissions is never granted.
+func (c Checker) HasAllPermissions(
+	ctx context.Context,
+	entityData permsdk.EntityData,
+	permissions []string,
+	attributes map[string]string) (bool, error) {
+	entities := mapEntityDataToEntities(ctx, entityData)
+	result, err := c.hasAllPermissions(ctx, entities, permissions, attributes)
+	c.recordDecision(ctx, entityData, permissions, attributes, result, err)
+	return result.granted, err
 }
 
 // Close resources used by the checker.
@@ -66,36 +142,87 @@ func (c Checker) HealthCheck(ctx context.Context, state *health.CheckState) erro
 	return c.cache.HealthCheck(ctx, state)
 }
 
-func mapEntityDataToEntities(entityData permsdk.EntityData) []string {
-	var entities []string
+// evaluation is the result of evaluating one or more permissions against the permissions bundle
+type evaluation struct {
+	granted   bool
+	policyIDs []string
+	reason    string
+}
 
-	if entityData.UserID != "" {
-		entities = append(entities, "users/"+entityData.UserID)
+func (c Checker) hasPermission(
+	ctx context.Context,
+	entities []string,
+	permission string,
+	attributes map[string]string) (evaluation, error) {
+	permissionsBundle, err := c.cache.GetPermissionsBundle(ctx, permsdk.Headers{})
+	if err != nil {
+		return evaluation{reason: audit.ReasonPermissionsUnavailable}, err
+	}
+
+	return evaluatePermission(ctx, permissionsBundle, entities, permission, attributes), nil
+}
+
+func (c Checker) hasAnyPermission(
+	ctx context.Context,
+	entities []string,
+	permissions []string,
+	attributes map[string]string) (evaluation, error) {
+	if len(permissions) == 0 {
+		return evaluation{reason: audit.ReasonNoPermissionsRequested}, nil
 	}
-	for _, group := range entityData.Groups {
-		if group != "" {
-			entities = append(entities, "groups/"+group)
+
+	permissionsBundle, err := c.cache.GetPermissionsBundle(ctx, permsdk.Headers{})
+	if err != nil {
+		return evaluation{reason: audit.ReasonPermissionsUnavailable}, err
+	}
+
+	for _, permission := range permissions {
+		if result := evaluatePermission(ctx, permissionsBundle, entities, permission, attributes); result.granted {
+			return result, nil
 		}
 	}
 
-	return entities
+	return evaluation{reason: audit.ReasonNoMatchingPolicy}, nil
 }
 
-func (c Checker) hasPermission(
+func (c Checker) hasAllPermissions(
 	ctx context.Context,
 	entities []string,
-	permission string,
-	attributes map[string]string) (bool, error) {
-	logData := &log.Data{"permission": permission}
+	permissions []string,
+	attributes map[string]string) (evaluation, error) {
+	if len(permissions) == 0 {
+		return evaluation{reason: audit.ReasonNoPermissionsRequested}, nil
+	}
+
 	permissionsBundle, err := c.cache.GetPermissionsBundle(ctx, permsdk.Headers{})
 	if err != nil {
-		return false, err
+		return evaluation{reason: audit.ReasonPermissionsUnavailable}, err
+	}
+
+	var policyIDs []string
+	for _, permission := range permissions {
+		result := evaluatePermission(ctx, permissionsBundle, entities, permission, attributes)
+		if !result.granted {
+			return result, nil
+		}
+		policyIDs = append(policyIDs, result.policyIDs...)
 	}
 
+	return evaluation{granted: true, policyIDs: policyIDs, reason: audit.ReasonPolicyMatched}, nil
+}
+
+// evaluatePermission determines whether one of the given entities has the given permission in the permissions bundle.
+func evaluatePermission(
+	ctx context.Context,
+	permissionsBundle permsdk.Bundle,
+	entities []string,
+	permission string,
+	attributes map[string]string) evaluation {
+	logData := &log.Data{"permission": permission}
 	entityLookup, ok := permissionsBundle[permission]
 	if !ok {
 		log.Warn(ctx, "permission not found in permissions bundle", logData)
-		return false, nil
+		return evaluation{reason: audit.ReasonPermissionNotFound}
 	}
 
 	for _, entity := range entities {
@@ -104,26 +231,60 @@ func (c Checker) hasPermission(
 			continue
 		}
 
-		if aPolicyApplies(policies, attributes) {
-			return true, nil
+		if policy, ok := applicablePolicy(policies, attributes); ok {
+			return evaluation{granted: true, policyIDs: []string{policy.ID}, reason: audit.ReasonPolicyMatched}
 		}
 	}
 
-	return false, nil
+	return evaluation{reason: audit.ReasonNoMatchingPolicy}
 }
 
-func aPolicyApplies(policies []permsdk.Policy, attributes map[string]string) bool {
-	if len(policies) == 0 {
-		return false
-	}
-
+// applicablePolicy returns the first of the given policies that applies to the attributes
+func applicablePolicy(policies []permsdk.Policy, attributes map[string]string) (permsdk.Policy, bool) {
 	for _, policy := range policies {
 		if conditionIsMet(policy.Condition, attributes) {
-			return true
+			return policy, true
 		}
 	}
 
-	return false
+	return permsdk.Policy{}, false
+}
+
+// recordDecision adds the result of a check to the decision being built by the caller if there is one in the
+// context, otherwise it records a new decision to the audit sink.
+func (c Checker) recordDecision(
+	ctx context.Context,
+	entityData permsdk.EntityData,
+	permissions []string,
+	attributes map[string]string,
+	result evaluation,
+	err error) {
+	outcome := audit.OutcomeDeny
+	if result.granted && err == nil {
+		outcome = audit.OutcomeAllow
+	}
+
+	if decision, ok := audit.DecisionFromContext(ctx); ok {
+		decision.PolicyIDs = result.policyIDs
+		decision.Outcome = outcome
+		decision.Reason = result.reason
+		return
+	}
+
+	if c.auditSink == nil {
+		return
+	}
+	c.auditSink.Record(ctx, audit.Decision{
+		Timestamp:   time.Now().UTC(),
+		RequestID:   request.GetRequestId(ctx),
+		UserID:      entityData.UserID,
+		Groups:      entityData.Groups,
+		Permissions: permissions,
+		Attributes:  attributes,
+		PolicyIDs:   result.policyIDs,
+		Outcome:     outcome,
+		Reason:      result.reason,
+	})
 }
 
 func conditionIsMet(condition permsdk.Condition, attributes map[string]string) bool {
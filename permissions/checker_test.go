@@ -4,6 +4,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/ONSdigital/dp-authorisation/v2/audit"
 	"github.com/ONSdigital/dp-authorisation/v2/permissions"
 	"github.com/ONSdigital/dp-authorisation/v2/permissions/mock"
 	"github.com/ONSdigital/dp-healthcheck/healthcheck"
@@ -69,6 +70,12 @@ var permissionsBundle = permsdk.Bundle{
 				},
 			},
 		},
+		"services/dp-files-api": {
+			permsdk.Policy{
+				ID:        "policy8",
+				Condition: permsdk.Condition{},
+			},
+		},
 	},
 }
 
@@ -300,6 +307,233 @@ func TestChecker_HasPermission_MultipleConditionsChecked(t *testing.T) {
 	})
 }
 
+func TestChecker_HasPermission_ServiceEntity(t *testing.T) {
+	ctx := context.Background()
+	store := newMockCache()
+	checker := permissions.NewCheckerForStore(store)
+	attributes := map[string]string{}
+
+	Convey("Given a permissions bundle that grants a permission to a service", t, func() {
+		Convey("When HasPermission is called for the authenticated service", func() {
+			serviceCtx := permissions.NewContextWithService(ctx, "dp-files-api")
+			hasPermission, err := checker.HasPermission(serviceCtx, permissions.NewServiceEntityData("dp-files-api"), "some_service.write", attributes)
+
+			Convey("Then the permission is granted", func() {
+				So(err, ShouldBeNil)
+				So(hasPermission, ShouldBeTrue)
+			})
+		})
+
+		Convey("When HasPermission is called for a user with the same ID as the service", func() {
+			hasPermission, err := checker.HasPermission(ctx, permsdk.EntityData{UserID: "dp-files-api"}, "some_service.write", attributes)
+
+			Convey("Then the permission is not granted", func() {
+				So(err, ShouldBeNil)
+				So(hasPermission, ShouldBeFalse)
+			})
+		})
+
+		Convey("When HasPermission is called for a user whose ID is the service entity ID", func() {
+			hasPermission, err := checker.HasPermission(ctx, permissions.NewServiceEntityData("dp-files-api"), "some_service.write", attributes)
+
+			Convey("Then the user is not treated as the service and the permission is not granted", func() {
+				So(err, ShouldBeNil)
+				So(hasPermission, ShouldBeFalse)
+			})
+		})
+	})
+}
+
+func TestServiceFromContext(t *testing.T) {
+	Convey("Given a context that identifies the caller as a service", t, func() {
+		ctx := permissions.NewContextWithService(context.Background(), "dp-files-api")
+
+		Convey("Then the service ID is returned", func() {
+			serviceID, ok := permissions.ServiceFromContext(ctx)
+			So(ok, ShouldBeTrue)
+			So(serviceID, ShouldEqual, "dp-files-api")
+		})
+	})
+
+	Convey("Given a context without a service", t, func() {
+		Convey("Then no service ID is returned", func() {
+			_, ok := permissions.ServiceFromContext(context.Background())
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
+
+func TestChecker_HasAnyPermission(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Given a viewer user and a checker with a mock store", t, func() {
+		store := newMockCache()
+		checker := permissions.NewCheckerForStore(store)
+		entityData := permsdk.EntityData{
+			Groups: []string{"viewer"},
+		}
+		attributes := map[string]string{"collection_id": "collection765"}
+
+		Convey("When HasAnyPermission is called with one permission the viewer has", func() {
+			hasPermission, err := checker.HasAnyPermission(ctx, entityData, []string{"legacy.write", "legacy.read"}, attributes)
+
+			Convey("Then the result is true", func() {
+				So(err, ShouldBeNil)
+				So(hasPermission, ShouldBeTrue)
+			})
+
+			Convey("Then the permissions bundle is only read once", func() {
+				So(store.GetPermissionsBundleCalls(), ShouldHaveLength, 1)
+			})
+		})
+
+		Convey("When HasAnyPermission is called with no permissions the viewer has", func() {
+			hasPermission, err := checker.HasAnyPermission(ctx, entityData, []string{"legacy.write", "users.add"}, attributes)
+
+			Convey("Then the result is false", func() {
+				So(err, ShouldBeNil)
+				So(hasPermission, ShouldBeFalse)
+			})
+		})
+
+		Convey("When HasAnyPermission is called with an empty list of permissions", func() {
+			hasPermission, err := checker.HasAnyPermission(ctx, entityData, []string{}, attributes)
+
+			Convey("Then the result is false", func() {
+				So(err, ShouldBeNil)
+				So(hasPermission, ShouldBeFalse)
+			})
+		})
+	})
+
+	Convey("Given a checker with a store that returns an error", t, func() {
+		store := &mock.CacheMock{
+			GetPermissionsBundleFunc: func(ctx context.Context, headers permsdk.Headers) (permsdk.Bundle, error) {
+				return nil, permsdk.ErrNotCached
+			},
+		}
+		checker := permissions.NewCheckerForStore(store)
+
+		Convey("When HasAnyPermission is called", func() {
+			hasPermission, err := checker.HasAnyPermission(ctx, permsdk.EntityData{Groups: []string{"admin"}}, []string{"users.add"}, nil)
+
+			Convey("Then the error is returned", func() {
+				So(err, ShouldEqual, permsdk.ErrNotCached)
+				So(hasPermission, ShouldBeFalse)
+			})
+		})
+	})
+}
+
+func TestChecker_HasAllPermissions(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Given a publisher user and a checker with a mock store", t, func() {
+		store := newMockCache()
+		checker := permissions.NewCheckerForStore(store)
+		entityData := permsdk.EntityData{
+			Groups: []string{"publisher"},
+		}
+
+		Convey("When HasAllPermissions is called with permissions the publisher has", func() {
+			hasPermission, err := checker.HasAllPermissions(ctx, entityData, []string{"legacy.read", "legacy.write"}, nil)
+
+			Convey("Then the result is true", func() {
+				So(err, ShouldBeNil)
+				So(hasPermission, ShouldBeTrue)
+			})
+
+			Convey("Then the permissions bundle is only read once", func() {
+				So(store.GetPermissionsBundleCalls(), ShouldHaveLength, 1)
+			})
+		})
+
+		Convey("When HasAllPermissions is called with a permission the publisher does not have", func() {
+			hasPermission, err := checker.HasAllPermissions(ctx, entityData, []string{"legacy.read", "users.add"}, nil)
+
+			Convey("Then the result is false", func() {
+				So(err, ShouldBeNil)
+				So(hasPermission, ShouldBeFalse)
+			})
+		})
+
+		Convey("When HasAllPermissions is called with an empty list of permissions", func() {
+			hasPermission, err := checker.HasAllPermissions(ctx, entityData, nil, nil)
+
+			Convey("Then the result is false", func() {
+				So(err, ShouldBeNil)
+				So(hasPermission, ShouldBeFalse)
+			})
+		})
+	})
+}
+
+func TestChecker_WithAuditSink(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Given a checker with an audit sink", t, func() {
+		sink := audit.NewMemorySink(10)
+		checker := permissions.NewCheckerForStore(newMockCache(), permissions.WithAuditSink(sink))
+		entityData := permsdk.EntityData{
+			UserID: "bilbo",
+			Groups: []string{"publisher"},
+		}
+
+		Convey("When a permission is granted", func() {
+			_, err := checker.HasPermission(ctx, entityData, "legacy.read", map[string]string{"collection_id": "collection765"})
+			So(err, ShouldBeNil)
+
+			Convey("Then an allow decision is recorded with the matched policy", func() {
+				decisions := sink.Decisions()
+				So(decisions, ShouldHaveLength, 1)
+				So(decisions[0].Outcome, ShouldEqual, audit.OutcomeAllow)
+				So(decisions[0].Reason, ShouldEqual, audit.ReasonPolicyMatched)
+				So(decisions[0].PolicyIDs, ShouldResemble, []string{"policy4"})
+				So(decisions[0].UserID, ShouldEqual, "bilbo")
+				So(decisions[0].Groups, ShouldResemble, []string{"publisher"})
+				So(decisions[0].Permissions, ShouldResemble, []string{"legacy.read"})
+				So(decisions[0].Attributes, ShouldResemble, map[string]string{"collection_id": "collection765"})
+				So(decisions[0].Timestamp.IsZero(), ShouldBeFalse)
+			})
+		})
+
+		Convey("When all of a list of permissions are granted", func() {
+			_, err := checker.HasAllPermissions(ctx, entityData, []string{"legacy.read", "legacy.write"}, nil)
+			So(err, ShouldBeNil)
+
+			Convey("Then the policy matched for each permission is recorded", func() {
+				So(sink.Decisions()[0].PolicyIDs, ShouldResemble, []string{"policy4", "policy6"})
+			})
+		})
+
+		Convey("When a permission that is not in the bundle is checked", func() {
+			_, err := checker.HasPermission(ctx, entityData, "unknown.permission", nil)
+			So(err, ShouldBeNil)
+
+			Convey("Then a deny decision is recorded with the reason", func() {
+				decisions := sink.Decisions()
+				So(decisions, ShouldHaveLength, 1)
+				So(decisions[0].Outcome, ShouldEqual, audit.OutcomeDeny)
+				So(decisions[0].Reason, ShouldEqual, audit.ReasonPermissionNotFound)
+				So(decisions[0].PolicyIDs, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When the context holds a decision being built by the caller", func() {
+			decision := &audit.Decision{RequestID: "request-123"}
+			_, err := checker.HasAnyPermission(audit.NewContextWithDecision(ctx, decision), entityData, []string{"users.add", "legacy.write"}, nil)
+			So(err, ShouldBeNil)
+
+			Convey("Then the result is added to that decision instead of being recorded", func() {
+				So(sink.Decisions(), ShouldBeEmpty)
+				So(decision.Outcome, ShouldEqual, audit.OutcomeAllow)
+				So(decision.PolicyIDs, ShouldResemble, []string{"policy6"})
+				So(decision.Reason, ShouldEqual, audit.ReasonPolicyMatched)
+			})
+		})
+	})
+}
+
 func TestChecker_Close(t *testing.T) {
 	ctx := context.Background()
 
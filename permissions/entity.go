@@ -0,0 +1,60 @@
+package permissions
+
+import (
+	"context"
+
+	permsdk "github.com/ONSdigital/dp-permissions-api/sdk"
+)
+
+// Prefixes of the entity IDs used in permissions policies
+const (
+	UserEntityPrefix    = "users/"
+	GroupEntityPrefix   = "groups/"
+	ServiceEntityPrefix = "services/"
+)
+
+// serviceContextKey is the key of the authenticated service ID in a context
+type serviceContextKey struct{}
+
+// NewServiceEntityData returns the EntityData for the service with the given ID. The UserID of the returned value is
+// the service entity ID, e.g. 'services/dp-dataset-api', for use in logs and audit records. It does not make the
+// checker treat the caller as a service, see NewContextWithService.
+func NewServiceEntityData(serviceID string) permsdk.EntityData {
+	return permsdk.EntityData{UserID: ServiceEntityPrefix + serviceID}
+}
+
+// NewContextWithService returns a copy of the given context that identifies the caller as the service with the given
+// ID, so that the checker resolves the caller to the 'services/<service ID>' entity. Without it, the UserID of the
+// entity data is always resolved to a user, so that a user whose ID looks like a service entity ID cannot be granted
+// the service's permissions. Only call it once the service has been authenticated, e.g. using a Zebedee service token.
+func NewContextWithService(ctx context.Context, serviceID string) context.Context {
+	return context.WithValue(ctx, serviceContextKey{}, serviceID)
+}
+
+// ServiceFromContext returns the ID of the authenticated service held in the given context, and false if the caller
+// has not been identified as a service
+func ServiceFromContext(ctx context.Context) (string, bool) {
+	serviceID, ok := ctx.Value(serviceContextKey{}).(string)
+	return serviceID, ok
+}
+
+// mapEntityDataToEntities returns the IDs of the user or service, and groups, identified by the entity data. The
+// caller is a service only if the context says so.
+func mapEntityDataToEntities(ctx context.Context, entityData permsdk.EntityData) []string {
+	var entities []string
+
+	if serviceID, ok := ServiceFromContext(ctx); ok {
+		if serviceID != "" {
+			entities = append(entities, ServiceEntityPrefix+serviceID)
+		}
+	} else if entityData.UserID != "" {
+		entities = append(entities, UserEntityPrefix+entityData.UserID)
+	}
+	for _, group := range entityData.Groups {
+		if group != "" {
+			entities = append(entities, GroupEntityPrefix+group)
+		}
+	}
+
+	return entities
+}
@@ -0,0 +1,186 @@
+package zebedeeclient
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/dp-authorisation/v2/internal/lru"
+	"github.com/ONSdigital/dp-authorisation/v2/internal/tokenhash"
+	dprequest "github.com/ONSdigital/dp-net/v3/request"
+)
+
+// Default values used by CachingZebedeeClient
+const (
+	DefaultCacheTTL         = time.Minute
+	DefaultCacheNegativeTTL = 5 * time.Second
+	DefaultCacheMaxEntries  = lru.DefaultMaxEntries
+)
+
+// errCheckIncomplete is returned to callers sharing an identity check that did not complete
+var errCheckIncomplete = errors.New("token identity check did not complete")
+
+// TokenIdentityChecker checks the identity of Zebedee service and user tokens, as implemented by ZebedeeClient
+type TokenIdentityChecker interface {
+	CheckTokenIdentity(ctx context.Context, token string) (*dprequest.IdentityResponse, error)
+	CheckUserTokenIdentity(ctx context.Context, token string) (*dprequest.IdentityResponse, error)
+}
+
+// CachingZebedeeClient decorates a TokenIdentityChecker, caching the identity of each token so that Zebedee is
+// not called for every request. Identities are cached for the ttl and failed checks for the negative ttl. As
+// the identity client does not distinguish a rejected token from Zebedee being unavailable, all failures are
+// cached for the negative ttl, other than those caused by the request context being cancelled. Concurrent
+// checks of the same token share a single call to Zebedee.
+//
+// Tokens are not held by the cache, entries are keyed on a SHA-256 hash of the token. Service and user tokens
+// are cached separately, so a token is never accepted as a different type to the one it was checked as.
+type CachingZebedeeClient struct {
+	client      TokenIdentityChecker
+	ttl         time.Duration
+	negativeTTL time.Duration
+	cache       *lru.Cache[string, identityResult]
+
+	mu    sync.Mutex
+	calls map[string]*identityCall
+}
+
+// CacheOption configures optional behaviour of CachingZebedeeClient
+type CacheOption func(*cacheOptions)
+
+type cacheOptions struct {
+	negativeTTL time.Duration
+	maxEntries  int
+	now         func() time.Time
+}
+
+// WithNegativeTTL sets how long failed identity checks are cached for, a ttl of 0 disables negative caching.
+// The default is DefaultCacheNegativeTTL.
+func WithNegativeTTL(negativeTTL time.Duration) CacheOption {
+	return func(o *cacheOptions) {
+		o.negativeTTL = negativeTTL
+	}
+}
+
+// WithMaxEntries sets the maximum number of tokens cached, the least recently used token is evicted when the
+// cache is full. The default is DefaultCacheMaxEntries.
+func WithMaxEntries(maxEntries int) CacheOption {
+	return func(o *cacheOptions) {
+		o.maxEntries = maxEntries
+	}
+}
+
+// WithCacheClock sets the function used to get the current time when expiring entries, time.Now is used by default
+func WithCacheClock(now func() time.Time) CacheOption {
+	return func(o *cacheOptions) {
+		o.now = now
+	}
+}
+
+// NewCachingZebedeeClient creates a CachingZebedeeClient that caches identities from the given client for the given ttl
+func NewCachingZebedeeClient(client TokenIdentityChecker, ttl time.Duration, opts ...CacheOption) *CachingZebedeeClient {
+	o := &cacheOptions{
+		negativeTTL: DefaultCacheNegativeTTL,
+		maxEntries:  DefaultCacheMaxEntries,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return &CachingZebedeeClient{
+		client:      client,
+		ttl:         ttl,
+		negativeTTL: o.negativeTTL,
+		cache:       lru.New[string, identityResult](o.maxEntries, lru.WithClock(o.now)),
+		calls:       make(map[string]*identityCall),
+	}
+}
+
+// identityResult is the outcome of an identity check
+type identityResult struct {
+	identity *dprequest.IdentityResponse
+	err      error
+}
+
+// response returns the result, copying the identity so that callers cannot modify the cached value
+func (r identityResult) response() (*dprequest.IdentityResponse, error) {
+	if r.identity == nil {
+		return nil, r.err
+	}
+	identityResponse := *r.identity
+	return &identityResponse, r.err
+}
+
+// identityCall is an identity check in progress, shared by concurrent checks of the same token
+type identityCall struct {
+	done   chan struct{}
+	result identityResult
+}
+
+// CheckTokenIdentity returns the cached identity for the service token, checking it with the underlying client if it is not cached
+func (c *CachingZebedeeClient) CheckTokenIdentity(ctx context.Context, token string) (*dprequest.IdentityResponse, error) {
+	return c.checkTokenIdentity(ctx, serviceKeyPrefix+tokenhash.Key(token), token, c.client.CheckTokenIdentity)
+}
+
+// CheckUserTokenIdentity returns the cached identity for the user token, checking it with the underlying client if it is not cached
+func (c *CachingZebedeeClient) CheckUserTokenIdentity(ctx context.Context, token string) (*dprequest.IdentityResponse, error) {
+	return c.checkTokenIdentity(ctx, userKeyPrefix+tokenhash.Key(token), token, c.client.CheckUserTokenIdentity)
+}
+
+// checkFunc checks the identity of a token of a particular type
+type checkFunc func(ctx context.Context, token string) (*dprequest.IdentityResponse, error)
+
+func (c *CachingZebedeeClient) checkTokenIdentity(ctx context.Context, key, token string, check checkFunc) (*dprequest.IdentityResponse, error) {
+	if result, ok := c.cache.Get(key); ok {
+		return result.response()
+	}
+
+	c.mu.Lock()
+	if call, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.result.response()
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	// the result is replaced once the check completes, callers waiting on a check that panics get an error
+	call := &identityCall{done: make(chan struct{}), result: identityResult{err: errCheckIncomplete}}
+	c.calls[key] = call
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.calls, key)
+		c.mu.Unlock()
+		close(call.done)
+	}()
+
+	// the result is shared with other callers, so the check is not cancelled with the request that started it
+	identityResponse, err := check(context.WithoutCancel(ctx), token)
+	call.result = identityResult{identity: identityResponse, err: err}
+	c.store(key, call.result)
+
+	return call.result.response()
+}
+
+// store caches the result for the appropriate ttl
+func (c *CachingZebedeeClient) store(key string, result identityResult) {
+	switch {
+	case result.err == nil:
+		if c.ttl > 0 {
+			c.cache.Add(key, result, c.ttl)
+		}
+	case errors.Is(result.err, context.Canceled), errors.Is(result.err, context.DeadlineExceeded):
+		// the token was not checked, so the failure says nothing about it
+	case c.negativeTTL > 0:
+		c.cache.Add(key, result, c.negativeTTL)
+	}
+}
+
+// Prefixes of the cache keys for each token type
+const (
+	serviceKeyPrefix = "service:"
+	userKeyPrefix    = "user:"
+)
@@ -0,0 +1,169 @@
+package zebedeeclient_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-authorisation/v2/zebedeeclient"
+	dprequest "github.com/ONSdigital/dp-net/v3/request"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type identityCheckerFunc func(ctx context.Context, token string) (*dprequest.IdentityResponse, error)
+
+func (f identityCheckerFunc) CheckTokenIdentity(ctx context.Context, token string) (*dprequest.IdentityResponse, error) {
+	return f(ctx, token)
+}
+
+func (f identityCheckerFunc) CheckUserTokenIdentity(ctx context.Context, token string) (*dprequest.IdentityResponse, error) {
+	return f(ctx, token)
+}
+
+func TestCachingZebedeeClient(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Given a caching client wrapping a client that identifies valid tokens", t, func() {
+		now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		var calls atomic.Int32
+		client := identityCheckerFunc(func(ctx context.Context, token string) (*dprequest.IdentityResponse, error) {
+			calls.Add(1)
+			if token != "valid-token" {
+				return nil, errors.New("invalid token")
+			}
+			return &dprequest.IdentityResponse{Identifier: "service-a"}, nil
+		})
+		cachingClient := zebedeeclient.NewCachingZebedeeClient(client, time.Minute,
+			zebedeeclient.WithNegativeTTL(5*time.Second),
+			zebedeeclient.WithCacheClock(func() time.Time { return now }),
+		)
+
+		Convey("When a valid token is checked twice", func() {
+			first, err := cachingClient.CheckTokenIdentity(ctx, "valid-token")
+			So(err, ShouldBeNil)
+			first.Identifier = "modified"
+			second, err := cachingClient.CheckTokenIdentity(ctx, "valid-token")
+			So(err, ShouldBeNil)
+
+			Convey("Then the underlying client is called once and the cached identity is returned", func() {
+				So(calls.Load(), ShouldEqual, 1)
+				So(second.Identifier, ShouldEqual, "service-a")
+			})
+
+			Convey("And the underlying client is called again once the ttl has passed", func() {
+				now = now.Add(time.Minute)
+				_, err := cachingClient.CheckTokenIdentity(ctx, "valid-token")
+				So(err, ShouldBeNil)
+				So(calls.Load(), ShouldEqual, 2)
+			})
+		})
+
+		Convey("When a valid token is checked as a service token and as a user token", func() {
+			_, err := cachingClient.CheckTokenIdentity(ctx, "valid-token")
+			So(err, ShouldBeNil)
+			_, err = cachingClient.CheckUserTokenIdentity(ctx, "valid-token")
+			So(err, ShouldBeNil)
+
+			Convey("Then each token type is cached separately", func() {
+				So(calls.Load(), ShouldEqual, 2)
+			})
+		})
+
+		Convey("When an invalid token is checked twice", func() {
+			_, err := cachingClient.CheckTokenIdentity(ctx, "invalid-token")
+			So(err, ShouldNotBeNil)
+			_, err = cachingClient.CheckTokenIdentity(ctx, "invalid-token")
+
+			Convey("Then the failure is cached", func() {
+				So(err, ShouldNotBeNil)
+				So(calls.Load(), ShouldEqual, 1)
+			})
+
+			Convey("And the token is checked again once the negative ttl has passed", func() {
+				now = now.Add(5 * time.Second)
+				_, err := cachingClient.CheckTokenIdentity(ctx, "invalid-token")
+				So(err, ShouldNotBeNil)
+				So(calls.Load(), ShouldEqual, 2)
+			})
+		})
+	})
+
+	Convey("Given a caching client wrapping a client that fails with a cancelled context", t, func() {
+		var calls atomic.Int32
+		client := identityCheckerFunc(func(ctx context.Context, token string) (*dprequest.IdentityResponse, error) {
+			calls.Add(1)
+			return nil, context.Canceled
+		})
+		cachingClient := zebedeeclient.NewCachingZebedeeClient(client, time.Minute)
+
+		Convey("When a token is checked twice", func() {
+			cachingClient.CheckTokenIdentity(ctx, "token")
+			cachingClient.CheckTokenIdentity(ctx, "token")
+
+			Convey("Then the failure is not cached", func() {
+				So(calls.Load(), ShouldEqual, 2)
+			})
+		})
+	})
+
+	Convey("Given a caching client wrapping a slow client", t, func() {
+		var calls atomic.Int32
+		release := make(chan struct{})
+		client := identityCheckerFunc(func(ctx context.Context, token string) (*dprequest.IdentityResponse, error) {
+			calls.Add(1)
+			<-release
+			return &dprequest.IdentityResponse{Identifier: "service-a"}, nil
+		})
+		cachingClient := zebedeeclient.NewCachingZebedeeClient(client, time.Minute)
+
+		Convey("When the same token is checked concurrently", func() {
+			var wg sync.WaitGroup
+			identifiers := make([]string, 5)
+			for i := range identifiers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					identityResponse, err := cachingClient.CheckTokenIdentity(ctx, "token")
+					if err == nil {
+						identifiers[i] = identityResponse.Identifier
+					}
+				}()
+			}
+			time.Sleep(20 * time.Millisecond)
+			close(release)
+			wg.Wait()
+
+			Convey("Then the underlying client is called once and all callers get the identity", func() {
+				So(calls.Load(), ShouldEqual, 1)
+				for _, identifier := range identifiers {
+					So(identifier, ShouldEqual, "service-a")
+				}
+			})
+		})
+	})
+
+	Convey("Given a caching client wrapping a client that panics on its first call", t, func() {
+		var calls atomic.Int32
+		client := identityCheckerFunc(func(ctx context.Context, token string) (*dprequest.IdentityResponse, error) {
+			if calls.Add(1) == 1 {
+				panic("identity check failed")
+			}
+			return &dprequest.IdentityResponse{Identifier: "service-a"}, nil
+		})
+		cachingClient := zebedeeclient.NewCachingZebedeeClient(client, time.Minute)
+
+		Convey("When a token is checked after the check panics", func() {
+			So(func() { _, _ = cachingClient.CheckTokenIdentity(ctx, "token") }, ShouldPanic)
+			identityResponse, err := cachingClient.CheckTokenIdentity(ctx, "token")
+
+			Convey("Then the token is checked again rather than waiting on the failed check", func() {
+				So(err, ShouldBeNil)
+				So(identityResponse.Identifier, ShouldEqual, "service-a")
+				So(calls.Load(), ShouldEqual, 2)
+			})
+		})
+	})
+}
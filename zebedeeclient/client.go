@@ -2,9 +2,14 @@ package zebedeeclient
 
 import (
 	"context"
+	"time"
 
 	"github.com/ONSdigital/dp-api-clients-go/v2/identity"
+	"github.com/ONSdigital/dp-authorisation/v2/internal/tracing"
+	"github.com/ONSdigital/dp-authorisation/v2/metrics"
 	dprequest "github.com/ONSdigital/dp-net/v3/request"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // IdentityClient interface contains one method
@@ -13,8 +18,11 @@ type IdentityClient interface {
 }
 
 // ZebedeeClient contains zebedee client handler
+//   - TracerProvider - used to create a span for each identity check, the global provider is used if nil
 type ZebedeeClient struct {
-	Client IdentityClient
+	Client         IdentityClient
+	Metrics        *metrics.Metrics
+	TracerProvider trace.TracerProvider
 }
 
 // NewZebedeeIdentity creates a new zebedee identity client
@@ -26,5 +34,21 @@ func NewZebedeeClient(zebedeeURL string) *ZebedeeClient {
 
 // CheckTokenIdentity calls dp-api-clients-go/identity to check service token
 func (z ZebedeeClient) CheckTokenIdentity(ctx context.Context, token string) (*dprequest.IdentityResponse, error) {
-	return z.Client.CheckTokenIdentity(ctx, token, identity.TokenTypeService)
+	return z.checkTokenIdentity(ctx, token, identity.TokenTypeService)
+}
+
+// CheckUserTokenIdentity calls dp-api-clients-go/identity to check a Florence user token
+func (z ZebedeeClient) CheckUserTokenIdentity(ctx context.Context, token string) (*dprequest.IdentityResponse, error) {
+	return z.checkTokenIdentity(ctx, token, identity.TokenTypeUser)
+}
+
+func (z ZebedeeClient) checkTokenIdentity(ctx context.Context, token string, tokenType identity.TokenType) (*dprequest.IdentityResponse, error) {
+	ctx, span := tracing.Tracer(z.TracerProvider).Start(ctx, "zebedee.CheckTokenIdentity",
+		trace.WithAttributes(attribute.String("zebedee.token_type", tokenType.String())),
+	)
+	start := time.Now()
+	identityResponse, err := z.Client.CheckTokenIdentity(ctx, token, tokenType)
+	z.Metrics.ObserveZebedeeCall(time.Since(start), err)
+	tracing.EndSpan(span, err)
+	return identityResponse, err
 }
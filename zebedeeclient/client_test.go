@@ -37,3 +37,41 @@ func TestZebedeeClient(t *testing.T) {
 		})
 	})
 }
+
+type tokenTypeClient struct {
+	tokenType identity.TokenType
+}
+
+func (c *tokenTypeClient) CheckTokenIdentity(_ context.Context, _ string, tokenType identity.TokenType) (*dprequest.IdentityResponse, error) {
+	c.tokenType = tokenType
+	return &dprequest.IdentityResponse{Identifier: "publisher@ons.gov.uk"}, nil
+}
+
+func TestZebedeeClient_CheckUserTokenIdentity(t *testing.T) {
+	ctx := context.Background()
+	Convey("Given a zebedee client instance", t, func() {
+		identityClient := &tokenTypeClient{}
+		zc := zebedeeclient.ZebedeeClient{
+			Client: identityClient,
+		}
+
+		Convey("When a user token is checked", func() {
+			r, err := zc.CheckUserTokenIdentity(ctx, "florence-token")
+
+			Convey("Then the token is checked as a user token", func() {
+				So(err, ShouldBeNil)
+				So(r.Identifier, ShouldEqual, "publisher@ons.gov.uk")
+				So(identityClient.tokenType, ShouldEqual, identity.TokenTypeUser)
+			})
+		})
+
+		Convey("When a service token is checked", func() {
+			_, err := zc.CheckTokenIdentity(ctx, "service-token")
+
+			Convey("Then the token is checked as a service token", func() {
+				So(err, ShouldBeNil)
+				So(identityClient.tokenType, ShouldEqual, identity.TokenTypeService)
+			})
+		})
+	})
+}